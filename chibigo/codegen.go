@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 )
 
 func println(format string, args ...interface{}) {
@@ -14,12 +15,20 @@ func println(format string, args ...interface{}) {
 
 var depth int
 
-var argreg8 = [...]string{"dil", "sil", "dl", "cl", "r8b", "r9b"}
 var argreg64 = [...]string{"rdi", "rsi", "rdx", "rcx", "r8", "r9"}
 var current_fn *Obj
 
 var counter = count()
 
+// Types which have type descriptors, and pairs of a type and an
+// interface which have itabs.
+var typeDescs []*Type
+var itabs [][2]*Type
+
+// Methods called through stubs adjusting the receiver.
+var derefMethods []*Method
+var boundMethods []*Node
+
 func count() func() int {
 	i := 0
 	return func() int {
@@ -38,6 +47,41 @@ func pop(arg string) {
 	depth--
 }
 
+func pushf() {
+	println("  sub rsp, 8")
+	println("  movsd [rsp], xmm0")
+	depth++
+}
+
+func popf(reg string) {
+	println("  movsd %s, [rsp]", reg)
+	println("  add rsp, 8")
+	depth--
+}
+
+// Returns the name of the lower `size` bytes of a 64-bit register.
+
+func regName(reg string, size int) string {
+	names := map[string][3]string{
+		"rax": {"al", "ax", "eax"},
+		"rdi": {"dil", "di", "edi"},
+		"rsi": {"sil", "si", "esi"},
+		"rdx": {"dl", "dx", "edx"},
+		"rcx": {"cl", "cx", "ecx"},
+		"r8":  {"r8b", "r8w", "r8d"},
+		"r9":  {"r9b", "r9w", "r9d"},
+	}
+	switch size {
+	case 1:
+		return names[reg][0]
+	case 2:
+		return names[reg][1]
+	case 4:
+		return names[reg][2]
+	}
+	return reg
+}
+
 func cmp(cmd string) {
 	println("  cmp rax, rdi")
 	println("  %s al", cmd)
@@ -51,23 +95,75 @@ func alignTo(n int, align int) int {
 	return (n + align - 1) / align * align
 }
 
+// Allocate `size` zero-cleared bytes on the heap and set the address
+// to rax.
+
+func genAlloc(size int) {
+	if depth%2 == 1 {
+		println("  sub rsp, 8")
+	}
+	println("  mov rdi, 1")
+	println("  mov rsi, %d", size)
+	println("  call calloc")
+	if depth%2 == 1 {
+		println("  add rsp, 8")
+	}
+}
+
+// Move a loop variable on the heap to a new location, so that each
+// iteration of a "for" statement has its own variable.
+
+func renewVar(vr *Obj) {
+	if !vr.isBoxed {
+		return
+	}
+	genAlloc(vr.ty.size)
+	println("  mov rdi, rax")
+	println("  mov rsi, %d[rbp]", vr.offset)
+	println("  mov rcx, %d", vr.ty.size)
+	println("  rep movsb")
+	println("  mov %d[rbp], rax", vr.offset)
+}
+
+// Compute the address of a variable.
+
+func genVarAddr(vr *Obj) {
+	if vr.isBoxed {
+		// Local variable on the heap
+		println("  mov rax, %d[rbp]", vr.offset)
+	} else if vr.isLocal == true {
+		// Local variable
+		println("  lea rax, %d[rbp]", vr.offset)
+	} else {
+		// Global variable
+		println("  lea rax, [rip + %s]", vr.name)
+	}
+}
+
 // Compute the absolute address of a given node.
 // It's an error if a given node does not reside in memory.
 
 func genAddr(node *Node) {
 	switch node.kind {
 	case ND_VAR:
-		if node.vr.isLocal == true {
-			// Local variable
-			println("  lea rax, %d[rbp]", node.vr.offset)
-		} else {
-			// Global variable
-			println("  lea rax, [rip + %s]", node.vr.name)
-		}
+		genVarAddr(node.vr)
 		return
 	case ND_DEREF:
 		genExpr(node.lhs)
 		return
+	case ND_COMMA:
+		genExpr(node.lhs)
+		genAddr(node.rhs)
+		return
+	case ND_MEMBER:
+		genAddr(node.lhs)
+		println("  add rax, %d", node.member.offset)
+		return
+	case ND_FUNCALL, ND_IFACE, ND_TYPEASSERT:
+		if node.retBuffer != nil {
+			genExpr(node)
+			return
+		}
 	}
 
 	errorTok(node.tok, "not an lvalue")
@@ -76,13 +172,74 @@ func genAddr(node *Node) {
 // Load a value from where %rax is pointing to.
 
 func load(ty *Type) {
-	if ty != nil && ty.kind == TY_ARRAY {
+	if ty != nil && isAggregate(ty) {
+		// If it is an array or a struct, do not attempt to load a
+		// value to the register because in general we can't load
+		// an entire value to a register. As a result, the result
+		// of an evaluation of such a value is its address.
+		return
+	}
+	if isFlonum(ty) {
+		loadFloat(ty, "xmm0", "rax")
 		return
 	}
-	if ty != nil && ty.size == 1 {
-		println("  movsx rax, byte ptr [rax]")
+	loadReg(ty, "rax", "rax")
+}
+
+// Load a floating-point number of the type `ty` at `addr` to the xmm
+// register `reg`.
+
+func loadFloat(ty *Type, reg string, addr string) {
+	if ty.kind == TY_FLOAT32 {
+		println("  movss %s, dword ptr [%s]", reg, addr)
 	} else {
-		println("  mov rax, [rax]")
+		println("  movsd %s, qword ptr [%s]", reg, addr)
+	}
+}
+
+// Load a scalar of the type `ty` at `addr` to the 64-bit register
+// `reg`. A value narrower than 64 bits is sign-extended if the type
+// is signed and zero-extended otherwise.
+
+func loadReg(ty *Type, reg string, addr string) {
+	switch {
+	case ty.size == 1 && ty.isUnsigned:
+		println("  movzx %s, byte ptr [%s]", reg, addr)
+	case ty.size == 1:
+		println("  movsx %s, byte ptr [%s]", reg, addr)
+	case ty.size == 2 && ty.isUnsigned:
+		println("  movzx %s, word ptr [%s]", reg, addr)
+	case ty.size == 2:
+		println("  movsx %s, word ptr [%s]", reg, addr)
+	case ty.size == 4 && ty.isUnsigned:
+		println("  mov %s, dword ptr [%s]", regName(reg, 4), addr)
+	case ty.size == 4:
+		println("  movsxd %s, dword ptr [%s]", reg, addr)
+	default:
+		println("  mov %s, [%s]", reg, addr)
+	}
+}
+
+// Truncate rax to the width of the integer type `ty`, so that an
+// arithmetic operation wraps around on overflow.
+
+func cast(ty *Type) {
+	if !isInteger(ty) {
+		return
+	}
+	switch {
+	case ty.size == 1 && ty.isUnsigned:
+		println("  movzx eax, al")
+	case ty.size == 1:
+		println("  movsx rax, al")
+	case ty.size == 2 && ty.isUnsigned:
+		println("  movzx eax, ax")
+	case ty.size == 2:
+		println("  movsx rax, ax")
+	case ty.size == 4 && ty.isUnsigned:
+		println("  mov eax, eax")
+	case ty.size == 4:
+		println("  movsxd rax, eax")
 	}
 }
 
@@ -90,21 +247,182 @@ func load(ty *Type) {
 
 func store(ty *Type) {
 	pop("rdi")
-	if ty != nil && ty.size == 1 {
-		println("  mov [rdi], al")
+	if ty != nil && isAggregate(ty) {
+		println("  mov rsi, rax")
+		println("  mov rcx, %d", ty.size)
+		println("  rep movsb")
+		return
+	}
+	if isFlonum(ty) {
+		storeFloat(ty, "xmm0")
+		return
+	}
+	storeReg(ty, "rax")
+}
+
+// Store a scalar in a register to where %rdi is pointing to.
+
+func storeReg(ty *Type, reg string) {
+	println("  mov [rdi], %s", regName(reg, ty.size))
+}
+
+// Store a floating-point number in the xmm register `reg` to where
+// %rdi is pointing to.
+
+func storeFloat(ty *Type, reg string) {
+	if ty.kind == TY_FLOAT32 {
+		println("  movss dword ptr [rdi], %s", reg)
 	} else {
-		println("  mov [rdi], rax")
+		println("  movsd qword ptr [rdi], %s", reg)
+	}
+}
+
+// Convert a number of the type `from` to `to` if either of them is a
+// floating-point type. An integer is in rax, and a floating-point
+// number is in xmm0. A floating-point number is converted to an
+// integer by truncating it toward zero.
+
+func convertFloat(from *Type, to *Type) {
+	if !isFlonum(from) && !isFlonum(to) {
+		return
+	}
+
+	// Convert the value to float64 first.
+	if from.kind == TY_FLOAT32 {
+		println("  cvtss2sd xmm0, xmm0")
+	}
+	if isInteger(from) {
+		if from.isUnsigned && from.size == 8 {
+			// Halve a value which doesn't fit in int64 keeping its
+			// lowest bit, and double the result.
+			c := counter()
+			println("  test rax, rax")
+			println("  js .L.utof.%d", c)
+			println("  cvtsi2sd xmm0, rax")
+			println("  jmp .L.utof.end.%d", c)
+			println(".L.utof.%d:", c)
+			println("  mov rdi, rax")
+			println("  and eax, 1")
+			println("  shr rdi, 1")
+			println("  or rdi, rax")
+			println("  cvtsi2sd xmm0, rdi")
+			println("  addsd xmm0, xmm0")
+			println(".L.utof.end.%d:", c)
+		} else {
+			println("  cvtsi2sd xmm0, rax")
+		}
+	}
+
+	if isInteger(to) {
+		if to.isUnsigned && to.size == 8 {
+			// A value which doesn't fit in int64 is converted after
+			// subtracting 2^63, which is added back to the result.
+			c := counter()
+			println("  mov rax, 0x%x", math.Float64bits(1<<63))
+			println("  movq xmm1, rax")
+			println("  ucomisd xmm0, xmm1")
+			println("  jae .L.ftou.%d", c)
+			println("  cvttsd2si rax, xmm0")
+			println("  jmp .L.ftou.end.%d", c)
+			println(".L.ftou.%d:", c)
+			println("  subsd xmm0, xmm1")
+			println("  cvttsd2si rax, xmm0")
+			println("  btc rax, 63")
+			println(".L.ftou.end.%d:", c)
+		} else {
+			println("  cvttsd2si rax, xmm0")
+		}
+		return
+	}
+	if to.kind == TY_FLOAT32 {
+		println("  cvtsd2ss xmm0, xmm0")
 	}
 }
 
 func genExpr(node *Node) {
 	switch node.kind {
 	case ND_NUM:
+		if node.ty != nil && node.ty.kind == TY_FLOAT32 {
+			f, _ := floatOf(node).Float32()
+			println("  mov eax, 0x%x", math.Float32bits(f))
+			println("  movq xmm0, rax")
+			return
+		}
+		if node.ty != nil && isFlonum(node.ty) {
+			f, _ := floatOf(node).Float64()
+			if math.IsInf(f, 0) {
+				errorTok(node.tok, "constant %s overflows float64", constString(node))
+			}
+			println("  mov rax, 0x%x", math.Float64bits(f))
+			println("  movq xmm0, rax")
+			return
+		}
+		if isUntyped(node.ty) && !representable(constOf(node), tyInt) {
+			errorTok(node.tok, "constant %s overflows int", constOf(node))
+		}
 		println("  mov rax, %d", node.val)
 		return
 	case ND_NEG:
 		genExpr(node.lhs)
+		if node.ty.kind == TY_FLOAT32 {
+			// Flip the sign bit.
+			println("  mov eax, 0x80000000")
+			println("  movq xmm1, rax")
+			println("  xorps xmm0, xmm1")
+			return
+		}
+		if isFlonum(node.ty) {
+			println("  mov rax, 0x8000000000000000")
+			println("  movq xmm1, rax")
+			println("  xorpd xmm0, xmm1")
+			return
+		}
 		println("  neg rax")
+		cast(node.ty)
+		return
+	case ND_NOT:
+		genExpr(node.lhs)
+		println("  cmp rax, 0")
+		println("  sete al")
+		println("  movzx rax, al")
+		return
+	case ND_BITNOT:
+		genExpr(node.lhs)
+		println("  not rax")
+		cast(node.ty)
+		return
+	case ND_CAST:
+		genExpr(node.lhs)
+		convertFloat(node.lhs.ty, node.ty)
+		cast(node.ty)
+		return
+	case ND_LOGAND:
+		c := counter()
+		genExpr(node.lhs)
+		println("  cmp rax, 0")
+		println("  je .L.false.%d", c)
+		genExpr(node.rhs)
+		println("  cmp rax, 0")
+		println("  je .L.false.%d", c)
+		println("  mov rax, 1")
+		println("  jmp .L.end.%d", c)
+		println(".L.false.%d:", c)
+		println("  mov rax, 0")
+		println(".L.end.%d:", c)
+		return
+	case ND_LOGOR:
+		c := counter()
+		genExpr(node.lhs)
+		println("  cmp rax, 0")
+		println("  jne .L.true.%d", c)
+		genExpr(node.rhs)
+		println("  cmp rax, 0")
+		println("  jne .L.true.%d", c)
+		println("  mov rax, 0")
+		println("  jmp .L.end.%d", c)
+		println(".L.true.%d:", c)
+		println("  mov rax, 1")
+		println(".L.end.%d:", c)
 		return
 	case ND_VAR:
 		genAddr(node)
@@ -114,6 +432,27 @@ func genExpr(node *Node) {
 		genExpr(node.lhs)
 		load(node.ty)
 		return
+	case ND_MEMBER:
+		genAddr(node)
+		load(node.ty)
+		return
+	case ND_COMMA:
+		genExpr(node.lhs)
+		genExpr(node.rhs)
+		return
+	case ND_MEMZERO:
+		genAddr(node.lhs)
+		println("  mov rdi, rax")
+		println("  mov rcx, %d", node.lhs.ty.size)
+		println("  mov al, 0")
+		println("  rep stosb")
+		return
+	case ND_DECL:
+		if node.vr.isBoxed {
+			genAlloc(node.vr.ty.size)
+			println("  mov %d[rbp], rax", node.vr.offset)
+		}
+		return
 	case ND_ADDR:
 		genAddr(node.lhs)
 		return
@@ -123,39 +462,187 @@ func genExpr(node *Node) {
 		genExpr(node.rhs)
 		store(node.ty)
 		return
+	case ND_IFACE:
+		genIface(node)
+		return
+	case ND_CLOSURE:
+		genClosure(node)
+		return
+	case ND_TYPEASSERT:
+		genTypeAssert(node)
+		return
 	case ND_FUNCALL:
-		nargs := 0
+		// The callee of an indirect call is evaluated before the
+		// arguments.
+		if node.lhs != nil {
+			genExpr(node.lhs)
+			push()
+		}
+		var args []*Node
 		for arg := node.args; arg != nil; arg = arg.next {
 			genExpr(arg)
-			push()
-			nargs++
+			if isFlonum(arg.ty) {
+				pushf()
+			} else {
+				push()
+			}
+			args = append(args, arg)
+		}
+
+		// A buffer for an aggregate result is passed as a hidden
+		// first argument. Floating-point arguments are passed in
+		// xmm0-7, and the others in general-purpose registers.
+		reg := 0
+		if node.funcTy != nil && returnsViaPointer(node.funcTy.returnTy) {
+			reg = 1
 		}
-		for i := nargs - 1; i >= 0; i-- {
-			pop(argreg64[i])
+		regs := make([]string, len(args))
+		gp, fp := reg, 0
+		for i, arg := range args {
+			if isFlonum(arg.ty) && fp < 8 {
+				regs[i] = fmt.Sprintf("xmm%d", fp)
+				fp++
+			} else if !isFlonum(arg.ty) && gp < len(argreg64) {
+				regs[i] = argreg64[gp]
+				gp++
+			} else {
+				errorTok(node.tok, "too many arguments")
+			}
+		}
+		for i := len(args) - 1; i >= 0; i-- {
+			if isFlonum(args[i].ty) {
+				popf(regs[i])
+			} else {
+				pop(regs[i])
+			}
+		}
+		if node.lhs != nil {
+			pop("rax")
+			if node.lhs.ty.kind == TY_FUNC {
+				println("  mov r10, rax")
+				println("  mov rax, [rax]")
+			}
+			println("  mov r11, rax")
+		}
+		if reg == 1 {
+			println("  lea rdi, %d[rbp]", node.retBuffer.offset)
+		}
+
+		// The stack pointer must be aligned to 16 bytes at a call.
+		if depth%2 == 1 {
+			println("  sub rsp, 8")
+		}
+		// A variadic function takes the number of vector registers
+		// used in al.
+		println("  mov rax, %d", fp)
+		if node.lhs != nil {
+			println("  call r11")
+		} else {
+			println("  call %s", node.funcname)
+		}
+		if depth%2 == 1 {
+			println("  add rsp, 8")
+		}
+
+		// A pair of scalars is returned in rax and rdx.
+		if node.funcTy != nil && returnsInRegs(node.funcTy.returnTy) {
+			mem := node.funcTy.returnTy.members
+			println("  lea rdi, %d[rbp]", node.retBuffer.offset+mem.offset)
+			storeReg(mem.ty, "rax")
+			mem = mem.next
+			println("  lea rdi, %d[rbp]", node.retBuffer.offset+mem.offset)
+			storeReg(mem.ty, "rdx")
+			println("  lea rax, %d[rbp]", node.retBuffer.offset)
 		}
-		println("  mov rax, 0")
-		println("  call %s", node.funcname)
 		return
 	}
 
-	genExpr(node.rhs)
-	push()
+	if isFlonum(node.lhs.ty) {
+		genFloatBinary(node)
+		return
+	}
+
 	genExpr(node.lhs)
-	pop("rdi")
+	push()
+	genExpr(node.rhs)
+	println("  mov rdi, rax")
+	pop("rax")
 
 	switch node.kind {
 	case ND_ADD:
 		println("  add rax, rdi")
+		cast(node.ty)
 		return
 	case ND_SUB:
 		println("  sub rax, rdi")
+		cast(node.ty)
 		return
 	case ND_MUL:
 		println("  imul rax, rdi")
+		cast(node.ty)
 		return
-	case ND_DIV:
-		println("  cqo")
-		println("  idiv rdi")
+	case ND_DIV, ND_MOD:
+		if node.ty.isUnsigned {
+			println("  mov rdx, 0")
+			println("  div rdi")
+		} else {
+			// idiv traps on the most negative number divided by -1,
+			// so x / -1 is computed as -x, which wraps, and x % -1 is 0.
+			c := counter()
+			println("  cmp rdi, -1")
+			println("  jne .L.idiv.%d", c)
+			println("  neg rax")
+			println("  mov rdx, 0")
+			println("  jmp .L.idiv.end.%d", c)
+			println(".L.idiv.%d:", c)
+			println("  cqo")
+			println("  idiv rdi")
+			println(".L.idiv.end.%d:", c)
+		}
+		if node.kind == ND_MOD {
+			println("  mov rax, rdx")
+		}
+		cast(node.ty)
+		return
+	case ND_BITAND:
+		println("  and rax, rdi")
+		return
+	case ND_BITOR:
+		println("  or rax, rdi")
+		return
+	case ND_BITXOR:
+		println("  xor rax, rdi")
+		return
+	case ND_ANDNOT:
+		println("  not rdi")
+		println("  and rax, rdi")
+		return
+	case ND_SHL:
+		// Unlike x86, Go doesn't mask the shift count. Shifting by
+		// the width or more yields 0.
+		println("  mov rcx, rdi")
+		println("  shl rax, cl")
+		println("  mov rdx, 0")
+		println("  cmp rdi, 63")
+		println("  cmova rax, rdx")
+		cast(node.ty)
+		return
+	case ND_SHR:
+		if node.ty.isUnsigned {
+			println("  mov rcx, rdi")
+			println("  shr rax, cl")
+			println("  mov rdx, 0")
+			println("  cmp rdi, 63")
+			println("  cmova rax, rdx")
+			return
+		}
+		// A signed value is shifted arithmetically, so shifting by
+		// the width or more fills it with the sign bit.
+		println("  mov rdx, 63")
+		println("  cmp rdi, 63")
+		println("  cmova rdi, rdx")
+		println("  mov rcx, rdi")
+		println("  sar rax, cl")
 		return
 	case ND_EQ:
 		cmp("sete")
@@ -164,10 +651,18 @@ func genExpr(node *Node) {
 		cmp("setne")
 		return
 	case ND_LT:
-		cmp("setl")
+		if node.lhs.ty.isUnsigned {
+			cmp("setb")
+		} else {
+			cmp("setl")
+		}
 		return
 	case ND_LE:
-		cmp("setle")
+		if node.lhs.ty.isUnsigned {
+			cmp("setbe")
+		} else {
+			cmp("setle")
+		}
 		return
 	}
 
@@ -175,10 +670,71 @@ func genExpr(node *Node) {
 	return
 }
 
+// Evaluates a binary operator on floating-point numbers. The result
+// of an arithmetic operator is in xmm0, and that of a comparison in
+// rax. A comparison with NaN is false except for !=.
+
+func genFloatBinary(node *Node) {
+	genExpr(node.lhs)
+	pushf()
+	genExpr(node.rhs)
+	println("  movsd xmm1, xmm0")
+	popf("xmm0")
+
+	sz := "sd"
+	if node.lhs.ty.kind == TY_FLOAT32 {
+		sz = "ss"
+	}
+
+	switch node.kind {
+	case ND_ADD:
+		println("  add%s xmm0, xmm1", sz)
+		return
+	case ND_SUB:
+		println("  sub%s xmm0, xmm1", sz)
+		return
+	case ND_MUL:
+		println("  mul%s xmm0, xmm1", sz)
+		return
+	case ND_DIV:
+		println("  div%s xmm0, xmm1", sz)
+		return
+	case ND_EQ:
+		println("  ucomi%s xmm0, xmm1", sz)
+		println("  sete al")
+		println("  setnp dl")
+		println("  and al, dl")
+		println("  movzx rax, al")
+		return
+	case ND_NE:
+		println("  ucomi%s xmm0, xmm1", sz)
+		println("  setne al")
+		println("  setp dl")
+		println("  or al, dl")
+		println("  movzx rax, al")
+		return
+	case ND_LT:
+		println("  ucomi%s xmm1, xmm0", sz)
+		println("  seta al")
+		println("  movzx rax, al")
+		return
+	case ND_LE:
+		println("  ucomi%s xmm1, xmm0", sz)
+		println("  setae al")
+		println("  movzx rax, al")
+		return
+	}
+
+	errorTok(node.tok, "invalid expression")
+}
+
 func genStmt(node *Node) {
 	switch node.kind {
 	case ND_IF:
 		c := counter()
+		if node.init != nil {
+			genStmt(node.init)
+		}
 		genExpr(node.cond)
 		println("  cmp rax, 0")
 		println("  je  .L.else.%d", c)
@@ -193,30 +749,99 @@ func genStmt(node *Node) {
 	case ND_FOR:
 		c := counter()
 		if node.init != nil {
-			genExpr(node.init)
+			genStmt(node.init)
 		}
 		println(".L.begin.%d:", c)
 		if node.cond != nil {
 			genExpr(node.cond)
 			println("  cmp rax, 0")
-			println("  je  .L.end.%d", c)
+			println("  je  %s", node.brkLabel)
 		}
 		genStmt(node.then)
+		println("%s:", node.contLabel)
+		for _, vr := range node.loopVars {
+			renewVar(vr)
+		}
 		if node.inc != nil {
-			genExpr(node.inc)
+			genStmt(node.inc)
 		}
 		println("  jmp .L.begin.%d", c)
-		println(".L.end.%d:", c)
+		println("%s:", node.brkLabel)
 		return
 	case ND_BLOCK:
 		for n := node.body; n != nil; n = n.next {
 			genStmt(n)
 		}
 		return
+	case ND_SWITCH:
+		if node.init != nil {
+			genStmt(node.init)
+		}
+		if node.lhs != nil {
+			genExpr(node.lhs)
+		}
+
+		fallback := node.brkLabel
+		if node.defaultCase != nil {
+			fallback = node.defaultCase.uniqueLabel
+		}
+		if lo, hi, ok := jumpTable(node); ok {
+			genJumpTable(node, lo, hi, fallback)
+		} else {
+			for n := node.body; n != nil; n = n.next {
+				if n == node.defaultCase {
+					continue
+				}
+				genExpr(n.cond)
+				println("  cmp rax, 0")
+				println("  jne %s", n.uniqueLabel)
+			}
+			println("  jmp %s", fallback)
+		}
+
+		for n := node.body; n != nil; n = n.next {
+			println("%s:", n.uniqueLabel)
+			for s := n.body; s != nil; s = s.next {
+				genStmt(s)
+			}
+			println("  jmp %s", node.brkLabel)
+		}
+		println("%s:", node.brkLabel)
+		return
+	case ND_GOTO:
+		println("  jmp %s", node.uniqueLabel)
+		return
+	case ND_LABEL:
+		println("%s:", node.uniqueLabel)
+		genStmt(node.lhs)
+		return
 	case ND_RETURN:
-		genExpr(node.lhs)
+		for n := node.body; n != nil; n = n.next {
+			genStmt(n)
+		}
+		if node.lhs != nil {
+			genExpr(node.lhs)
+		}
 		println("  jmp .L.return.%s", current_fn.name)
 		return
+	case ND_DEFER:
+		// runtime_deferproc(closure, frame, pc), where pc is the
+		// code resuming the function after a recovered panic.
+		for n := node.body; n != nil; n = n.next {
+			genStmt(n)
+		}
+		genExpr(node.lhs)
+		println("  mov rdi, rax")
+		println("  mov rsi, rbp")
+		println("  lea rdx, [rip+.L.recover.%s]", current_fn.name)
+		if depth%2 == 1 {
+			println("  sub rsp, 8")
+		}
+		println("  call runtime_deferproc")
+		if depth%2 == 1 {
+			println("  add rsp, 8")
+		}
+		return
 	case ND_EXPR_STMT:
 		genExpr(node.lhs)
 		return
@@ -226,6 +851,297 @@ func genStmt(node *Node) {
 	return
 }
 
+// Converts the value of `node.lhs` to an interface value. A value
+// other than a pointer is copied to the heap.
+
+func genIface(node *Node) {
+	src := node.lhs.ty
+	buf := node.retBuffer.offset
+	genExpr(node.lhs)
+
+	if src == tyNil {
+		println("  mov qword ptr %d[rbp], 0", buf)
+		println("  mov qword ptr %d[rbp], 0", buf+8)
+		println("  lea rax, %d[rbp]", buf)
+		return
+	}
+
+	if src.kind == TY_INTERFACE {
+		// A nil interface value converts to nil. Otherwise, look up
+		// the itab for the dynamic type, which always succeeds.
+		c := counter()
+		println("  mov rdx, [rax+8]")
+		println("  mov rsi, [rax]")
+		println("  cmp rsi, 0")
+		println("  je .L.iface.%d", c)
+		println("  mov rsi, [rsi]")
+		findItab(node.ty, ".L.iface.panic")
+		println(".L.iface.%d:", c)
+		println("  mov %d[rbp], rsi", buf)
+		println("  mov %d[rbp], rdx", buf+8)
+		println("  lea rax, %d[rbp]", buf)
+		return
+	}
+
+	if isFlonum(src) {
+		println("  movq rax, xmm0")
+	}
+	if src.kind != TY_PTR {
+		push()
+		if depth%2 == 1 {
+			println("  sub rsp, 8")
+		}
+		println("  mov rdi, 1")
+		println("  mov rsi, %d", src.size)
+		println("  call calloc")
+		if depth%2 == 1 {
+			println("  add rsp, 8")
+		}
+		pop("rsi")
+		println("  mov rdi, rax")
+		if isAggregate(src) {
+			println("  mov rcx, %d", src.size)
+			println("  rep movsb")
+		} else {
+			storeReg(src, "rsi")
+		}
+	}
+	println("  mov %d[rbp], rax", buf+8)
+	println("  lea rax, [rip+%s]", itabLabel(src, node.ty))
+	println("  mov %d[rbp], rax", buf)
+	println("  lea rax, %d[rbp]", buf)
+}
+
+// Creates a closure on the heap. The value of `node.lhs`, if any, is
+// copied to the context. The context of a function literal holds the
+// pointers to the variables it captures.
+
+func genClosure(node *Node) {
+	code := node.funcname
+	size := 8
+	if node.method != nil {
+		code = methodCode(node)
+	}
+	if node.vr != nil && len(node.vr.captures) > 0 {
+		genAlloc(size + len(node.vr.captures)*8)
+		println("  lea rdi, [rip+%s]", code)
+		println("  mov [rax], rdi")
+		for i, vr := range node.vr.captures {
+			println("  mov rdi, %d[rbp]", vr.outer.offset)
+			println("  mov [rax+%d], rdi", 8+i*8)
+		}
+		return
+	}
+	if node.lhs != nil {
+		genExpr(node.lhs)
+		if isFlonum(node.lhs.ty) {
+			println("  movq rax, xmm0")
+		}
+		push()
+		size += node.lhs.ty.size
+	}
+
+	genAlloc(size)
+	println("  lea rdi, [rip+%s]", code)
+	println("  mov [rax], rdi")
+
+	if node.lhs != nil {
+		pop("rsi")
+		println("  lea rdi, [rax+8]")
+		if isAggregate(node.lhs.ty) {
+			println("  mov rcx, %d", node.lhs.ty.size)
+			println("  rep movsb")
+		} else {
+			storeReg(node.lhs.ty, "rsi")
+		}
+	}
+}
+
+// Returns the code of a method value or a method expression.
+
+func methodCode(node *Node) string {
+	m := node.method
+	if node.lhs != nil {
+		for i, b := range boundMethods {
+			if b.method == m && identical(b.lhs.ty, node.lhs.ty) {
+				return fmt.Sprintf(".L.bound.%d", i)
+			}
+		}
+		boundMethods = append(boundMethods, node)
+		return fmt.Sprintf(".L.bound.%d", len(boundMethods)-1)
+	}
+
+	// The receiver of (*T).M is a pointer even if M has a value
+	// receiver.
+	if node.ty.params.kind == TY_PTR && !m.ptrRecv {
+		return derefStub(m)
+	}
+	return m.fn.name
+}
+
+// Returns the label of the code calling the method `m` with the
+// receiver pointed to by the first argument. An aggregate receiver
+// is passed by its address in the first place.
+
+func derefStub(m *Method) string {
+	if m.ptrRecv || isAggregate(m.fn.ty.params) {
+		return m.fn.name
+	}
+	if !hasMethod(derefMethods, m) {
+		derefMethods = append(derefMethods, m)
+	}
+	return ".L.deref." + m.fn.name
+}
+
+// Looks up the itab of the interface `iface` for the type descriptor
+// in rsi, and jumps to `fail` if the type does not implement the
+// interface. Sets the itab to rsi. rax and rdx are preserved.
+
+func findItab(iface *Type, fail string) {
+	c := counter()
+	println("  mov rsi, [rsi]")
+	println("  lea rdi, [rip+%s]", typeDesc(iface))
+	println(".L.lookup.%d:", c)
+	println("  mov rcx, [rsi]")
+	println("  cmp rcx, 0")
+	println("  je %s", fail)
+	println("  cmp rcx, rdi")
+	println("  je .L.lookup.end.%d", c)
+	println("  add rsi, 16")
+	println("  jmp .L.lookup.%d", c)
+	println(".L.lookup.end.%d:", c)
+	println("  mov rsi, [rsi+8]")
+}
+
+// Evaluates a type assertion. If the type assertion yields a pair of
+// the value and a boolean, the pair is stored to the buffer of the
+// node. Otherwise, a failing type assertion panics.
+
+func genTypeAssert(node *Node) {
+	c := counter()
+	target := node.target
+	fail := fmt.Sprintf(".L.assert.fail.%d", c)
+	commaOk := node.ty.kind == TY_TUPLE
+
+	genExpr(node.lhs)
+	println("  mov rdx, [rax+8]")
+	println("  mov rsi, [rax]")
+	println("  cmp rsi, 0")
+	println("  je %s", fail)
+	println("  mov rsi, [rsi]")
+
+	if target.kind == TY_INTERFACE {
+		findItab(target, fail)
+		buf := node.retBuffer.offset
+		println("  mov %d[rbp], rsi", buf)
+		println("  mov %d[rbp], rdx", buf+8)
+		println("  lea rax, %d[rbp]", buf)
+	} else {
+		println("  lea rdi, [rip+%s]", typeDesc(target))
+		println("  cmp rsi, rdi")
+		println("  jne %s", fail)
+		println("  mov rax, rdx")
+		if target.kind != TY_PTR {
+			load(target)
+		}
+		if commaOk {
+			println("  lea rdi, %d[rbp]", node.retBuffer.offset)
+			if isAggregate(target) {
+				println("  mov rsi, rax")
+				println("  mov rcx, %d", target.size)
+				println("  rep movsb")
+			} else if isFlonum(target) {
+				storeFloat(target, "xmm0")
+			} else {
+				storeReg(target, "rax")
+			}
+		}
+	}
+
+	if !commaOk {
+		println("  jmp .L.assert.end.%d", c)
+		println("%s:", fail)
+		println("  jmp .L.iface.panic")
+		println(".L.assert.end.%d:", c)
+		return
+	}
+
+	ok := node.retBuffer.offset + node.ty.members.next.offset
+	println("  mov byte ptr %d[rbp], 1", ok)
+	println("  jmp .L.assert.end.%d", c)
+	println("%s:", fail)
+	println("  lea rdi, %d[rbp]", node.retBuffer.offset)
+	println("  mov rcx, %d", target.size)
+	println("  mov al, 0")
+	println("  rep stosb")
+	println("  mov byte ptr %d[rbp], 0", ok)
+	println(".L.assert.end.%d:", c)
+	println("  lea rax, %d[rbp]", node.retBuffer.offset)
+}
+
+// Returns the range of case values if a switch statement can be
+// compiled to a jump table, which is the case if the tag is an integer
+// and there are at least four cases whose values are dense integer
+// constants.
+
+func jumpTable(node *Node) (int, int, bool) {
+	if node.lhs == nil || !isInteger(node.lhs.ty) {
+		return 0, 0, false
+	}
+
+	lo, hi, count := 0, 0, 0
+	for n := node.body; n != nil; n = n.next {
+		for x := n.args; x != nil; x = x.next {
+			val, ok := constValue(x)
+			if !ok {
+				return 0, 0, false
+			}
+			if count == 0 || val < lo {
+				lo = val
+			}
+			if count == 0 || val > hi {
+				hi = val
+			}
+			count++
+		}
+	}
+	// The range is computed without overflow, since the values may
+	// be as far apart as the smallest and the largest int64.
+	if count < 4 || uint64(hi)-uint64(lo) >= uint64(count*2) {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+// Jumps to the case matching the tag in rax through a table of
+// offsets relative to the table. Values without a case jump to
+// `fallback`.
+
+func genJumpTable(node *Node, lo int, hi int, fallback string) {
+	c := counter()
+	println("  mov rdi, %d", lo)
+	println("  sub rax, rdi")
+	println("  cmp rax, %d", hi-lo)
+	println("  ja %s", fallback)
+	println("  lea rdi, [rip+.L.table.%d]", c)
+	println("  movsxd rax, dword ptr [rdi+rax*4]")
+	println("  add rax, rdi")
+	println("  jmp rax")
+	println(".L.table.%d:", c)
+	for i := 0; i <= hi-lo; i++ {
+		val := lo + i
+		target := fallback
+		for n := node.body; n != nil; n = n.next {
+			for x := n.args; x != nil; x = x.next {
+				if v, _ := constValue(x); v == val {
+					target = n.uniqueLabel
+				}
+			}
+		}
+		println("  .long %s-.L.table.%d", target, c)
+	}
+}
+
 // Assign offsets to local variables.
 
 func assignLvarOffsets(prog *Obj) {
@@ -235,7 +1151,13 @@ func assignLvarOffsets(prog *Obj) {
 		}
 		offset := 0
 		for vr := fn.locals; vr != nil; vr = vr.next {
-			offset += vr.ty.size
+			if vr.isBoxed {
+				// The variable holds a pointer to the heap.
+				offset = alignTo(offset+8, 8)
+			} else {
+				offset += vr.ty.size
+				offset = alignTo(offset, vr.ty.align)
+			}
 			vr.offset = -offset
 		}
 		fn.stackSize = alignTo(offset, 16)
@@ -249,22 +1171,13 @@ func emitData(prog *Obj) {
 		}
 
 		println("  .data")
-		println("  .globl %s", vr.name)
-		println("%s:", vr.name)
-		if vr.init != nil {
-			if vr.init.rhs != nil && vr.init.rhs.vr != nil && vr.init.rhs.vr.initData != "" {
-				for i := 0; i < vr.ty.size; i++ {
-					println("  .byte %d", vr.init.rhs.vr.initData[i])
-				}
-			} else if vr.init.rhs != nil && vr.init.rhs.val != 0 {
-				println("  .long %d", vr.init.rhs.val)
-			} else if vr.init.rhs == nil {
-				for vr.init != nil {
-					println("  .quad %d", vr.init.lhs.rhs.val)
-					vr.init = vr.init.next
-				}
-			}
+		if vr.isStatic {
+			println("  .local %s", vr.name)
+		} else {
+			println("  .globl %s", vr.name)
 		}
+		println("  .align %d", vr.ty.align)
+		println("%s:", vr.name)
 		if vr.initData != "" {
 			for i := 0; i < vr.ty.size; i++ {
 				println("  .byte %d", vr.initData[i])
@@ -275,16 +1188,83 @@ func emitData(prog *Obj) {
 	}
 }
 
+// Copy an aggregate parameter from where `reg` is pointing to.
+// Argument registers other than `reg` must be preserved.
+
+func copyParam(vr *Obj, reg string) {
+	i := 0
+	for ; i+8 <= vr.ty.size; i += 8 {
+		println("  mov r11, [%s+%d]", reg, i)
+		println("  mov %d[rbp], r11", vr.offset+i)
+	}
+	for ; i < vr.ty.size; i++ {
+		println("  mov r11b, [%s+%d]", reg, i)
+		println("  mov %d[rbp], r11b", vr.offset+i)
+	}
+}
+
+// Pass the values of the result variables to the caller.
+
+func emitResults(fn *Obj) {
+	if fn.results == nil {
+		return
+	}
+
+	ty := fn.ty.returnTy
+	if returnsInRegs(ty) {
+		vr := fn.results.next
+		genVarAddr(vr)
+		load(vr.ty)
+		println("  mov rdx, rax")
+		vr = fn.results
+		genVarAddr(vr)
+		load(vr.ty)
+		return
+	}
+
+	if returnsViaPointer(ty) {
+		if ty.kind == TY_TUPLE {
+			vr := fn.results
+			for mem := ty.members; mem != nil; mem = mem.next {
+				copyResult(fn, vr, mem.offset)
+				vr = vr.next
+			}
+		} else {
+			copyResult(fn, fn.results, 0)
+		}
+		println("  mov rax, %d[rbp]", fn.retPtr.offset)
+		return
+	}
+
+	genVarAddr(fn.results)
+	load(fn.results.ty)
+}
+
+// Copy a result variable to the buffer given by the caller.
+
+func copyResult(fn *Obj, vr *Obj, offset int) {
+	println("  mov rdi, %d[rbp]", fn.retPtr.offset)
+	println("  add rdi, %d", offset)
+	genVarAddr(vr)
+	println("  mov rsi, rax")
+	println("  mov rcx, %d", vr.ty.size)
+	println("  rep movsb")
+}
+
 func emitText(prog *Obj) {
 	assignLvarOffsets(prog)
 
 	println(".intel_syntax noprefix")
 	for fn := prog; fn != nil; fn = fn.next {
-		if fn.isFunction == false {
+		if fn.isFunction == false || fn.isDefinition == false {
 			continue
 		}
 
-		println(".globl %s", fn.name)
+		if fn.isStatic {
+			println(".local %s", fn.name)
+		} else {
+			println(".globl %s", fn.name)
+		}
 		println(".text")
 		println("%s:", fn.name)
 		current_fn = fn
@@ -294,15 +1274,40 @@ func emitText(prog *Obj) {
 		println("  mov rbp, rsp")
 		println("  sub rsp, %d", fn.stackSize)
 
+		// Load the pointers to the captured variables from the
+		// context of the closure.
+		for i, vr := range fn.captures {
+			println("  mov rax, [r10+%d]", 8+i*8)
+			println("  mov %d[rbp], rax", vr.offset)
+		}
+
 		// Save passed-by-register arguments to the stack
-		i := 0
+		gp, fp := 0, 0
+		if fn.retPtr != nil {
+			println("  mov %d[rbp], rdi", fn.retPtr.offset)
+			gp++
+		}
 		for vr := fn.params; vr != nil; vr = vr.next {
-			if vr != nil && vr.ty != nil && vr.ty.size == 1 {
-				println("  mov %d[rbp], %s", vr.offset, argreg8[i])
+			if vr != nil && vr.ty != nil && isAggregate(vr.ty) {
+				// An aggregate is passed by its address. Copy it
+				// to make the parameter a value of its own.
+				copyParam(vr, argreg64[gp])
+				gp++
+			} else if vr.ty.kind == TY_FLOAT32 {
+				println("  movss %d[rbp], xmm%d", vr.offset, fp)
+				fp++
+			} else if vr.ty.kind == TY_FLOAT64 {
+				println("  movsd %d[rbp], xmm%d", vr.offset, fp)
+				fp++
 			} else {
-				println("  mov %d[rbp], %s", vr.offset, argreg64[i])
+				println("  mov %d[rbp], %s", vr.offset, regName(argreg64[gp], vr.ty.size))
+				gp++
 			}
-			i++
+		}
+
+		// Initialize global variables before running main.
+		if fn.name == "main" && initFn != nil {
+			println("  call %s", initFn.name)
 		}
 
 		// Emit code
@@ -313,14 +1318,312 @@ func emitText(prog *Obj) {
 
 		// Epilogue
 		println(".L.return.%s:", fn.name)
+		if fn.hasDefer {
+			// Run the deferred calls, keeping the result in the
+			// registers.
+			println("  lea rsp, %d[rbp]", -fn.stackSize)
+			println("  push rax")
+			println("  push rdx")
+			println("  sub rsp, 16")
+			println("  movsd [rsp], xmm0")
+			println("  mov rdi, rbp")
+			println("  call runtime_deferreturn")
+			println("  movsd xmm0, [rsp]")
+			println("  add rsp, 16")
+			println("  pop rdx")
+			println("  pop rax")
+		}
+		emitResults(fn)
 		println("  mov rsp, rbp")
 		println("  pop rbp")
 		println("  ret")
+
+		// A function recovering from a panic returns the values
+		// of the result variables, or zero if it has none.
+		if fn.hasDefer {
+			println(".L.recover.%s:", fn.name)
+			println("  lea rsp, %d[rbp]", -fn.stackSize)
+			println("  mov rax, 0")
+			println("  mov rdx, 0")
+			println("  pxor xmm0, xmm0")
+			println("  jmp .L.return.%s", fn.name)
+		}
+	}
+}
+
+// Returns the label of the type descriptor of `ty`.
+
+func typeDesc(ty *Type) string {
+	for i, t := range typeDescs {
+		if identical(t, ty) {
+			return fmt.Sprintf(".L.type.%d", i)
+		}
+	}
+	typeDescs = append(typeDescs, ty)
+	return fmt.Sprintf(".L.type.%d", len(typeDescs)-1)
+}
+
+// Returns the functions hashing and comparing values of the type `ty`,
+// or nil if no value of the type is converted to an interface or used
+// as a map key.
+
+func findTypeFuncs(ty *Type) *TypeFuncs {
+	for _, tf := range typeFuncs {
+		if identical(tf.ty, ty) {
+			return tf
+		}
+	}
+	return nil
+}
+
+// Returns the name of `ty` printed by the runtime. Types declared in
+// the program are qualified by the package name.
+
+func descName(ty *Type) string {
+	if ty.typeName != nil {
+		return "main." + typeString(ty)
+	}
+	return typeString(ty)
+}
+
+// Returns the label of the itab of the interface `iface` for `ty`.
+
+func itabLabel(ty *Type, iface *Type) string {
+	typeDesc(ty)
+	typeDesc(iface)
+	for i, it := range itabs {
+		if identical(it[0], ty) && identical(it[1], iface) {
+			return fmt.Sprintf(".L.itab.%d", i)
+		}
+	}
+	itabs = append(itabs, [2]*Type{ty, iface})
+	return fmt.Sprintf(".L.itab.%d", len(itabs)-1)
+}
+
+// Emit the type descriptors and itabs. The descriptor of a type
+// points to a list of pairs of an interface descriptor and an itab,
+// which has an entry for every interface in the program that the type
+// implements, so that a type assertion to an interface can look it up.
+
+func emitTypes() {
+	if len(typeDescs) == 0 {
+		return
+	}
+
+	for _, ty := range typeDescs {
+		if ty.kind == TY_INTERFACE {
+			continue
+		}
+		for _, iface := range typeDescs {
+			if iface.kind == TY_INTERFACE && missingMethod(ty, iface) == nil {
+				itabLabel(ty, iface)
+			}
+		}
+	}
+
+	println("  .data")
+	println("  .align 8")
+	for i, ty := range typeDescs {
+		println(".L.type.%d:", i)
+		if ty.kind == TY_INTERFACE {
+			println("  .quad 0")
+			continue
+		}
+		println("  .quad .L.type.%d.itabs", i)
+		println("  .quad .L.type.%d.name", i)
+		println("  .quad %d", len(descName(ty)))
+		if tf := findTypeFuncs(ty); tf != nil {
+			println("  .quad .L.type.%d.hash", i)
+			println("  .quad .L.type.%d.equal", i)
+		} else {
+			println("  .quad 0")
+			println("  .quad 0")
+		}
+		if ty.kind == TY_PTR {
+			println("  .quad 1")
+		} else {
+			println("  .quad 0")
+		}
+		println(".L.type.%d.itabs:", i)
+		for j, it := range itabs {
+			if identical(it[0], ty) {
+				println("  .quad %s", typeDesc(it[1]))
+				println("  .quad .L.itab.%d", j)
+			}
+		}
+		println("  .quad 0")
+	}
+
+	// The functions of a type are called as function values, which
+	// point to the code.
+	for i, ty := range typeDescs {
+		if tf := findTypeFuncs(ty); tf != nil && ty.kind != TY_INTERFACE {
+			println(".L.type.%d.hash:", i)
+			println("  .quad %s", tf.hash.name)
+			println(".L.type.%d.equal:", i)
+			println("  .quad %s", tf.equal.name)
+		}
+	}
+
+	for i, ty := range typeDescs {
+		if ty.kind != TY_INTERFACE {
+			println(".L.type.%d.name:", i)
+			println("  .ascii \"%s\"", descName(ty))
+		}
+	}
+
+	for i, it := range itabs {
+		println(".L.itab.%d:", i)
+		println("  .quad %s", typeDesc(it[0]))
+		for im := it[1].methods; im != nil; im = im.next {
+			println("  .quad %s", derefStub(findMethod(it[0], im.name)))
+		}
+	}
+	println(".L.iface.msg:")
+	println("  .ascii \"panic: interface conversion\\n\"")
+
+	println("  .text")
+	println(".L.iface.panic:")
+	println("  and rsp, -16")
+	println("  mov rdi, 2")
+	println("  lea rsi, [rip+.L.iface.msg]")
+	println("  mov rdx, 28")
+	println("  call write")
+	println("  mov rdi, 2")
+	println("  call exit")
+}
+
+// Emit the stubs calling methods.
+
+func emitStubs() {
+	println("  .text")
+
+	// runtime_resume(frame, pc) continues the function whose frame
+	// is `frame` at `pc`, discarding the frames of its callees.
+	println("runtime_resume:")
+	println("  mov rbp, rdi")
+	println("  jmp rsi")
+
+	// runtime_getframe() returns the frame of its caller.
+	println("runtime_getframe:")
+	println("  mov rax, rbp")
+	println("  ret")
+
+	// A stub for a method value moves the arguments to the next
+	// registers, and passes the receiver in the context.
+	for i, node := range boundMethods {
+		m := node.method
+		fnTy := m.ty
+		if m.fn != nil {
+			fnTy = m.fn.ty
+		}
+		first := 0
+		if returnsViaPointer(fnTy.returnTy) {
+			first = 1
+		}
+		nargs, nfloats := countParams(m.ty.params)
+		recv := node.lhs.ty
+
+		println(".L.bound.%d:", i)
+		if isFlonum(recv) {
+			if nfloats+1 > 8 {
+				errorTok(node.tok, "too many arguments")
+			}
+			shiftFloatArgs(nfloats)
+			loadFloat(recv, "xmm0", "r10+8")
+			println("  jmp %s", m.fn.name)
+			continue
+		}
+
+		if first+nargs+1 > len(argreg64) {
+			errorTok(node.tok, "too many arguments")
+		}
+		for j := first + nargs; j > first; j-- {
+			println("  mov %s, %s", argreg64[j], argreg64[j-1])
+		}
+		reg := argreg64[first]
+
+		if recv.kind == TY_INTERFACE {
+			idx := 1
+			for im := recv.methods; im != m; im = im.next {
+				idx++
+			}
+			println("  mov r11, [r10+8]")
+			println("  mov %s, [r10+16]", reg)
+			println("  jmp [r11+%d]", idx*8)
+			continue
+		}
+		if isAggregate(recv) {
+			println("  lea %s, [r10+8]", reg)
+		} else {
+			loadReg(recv, reg, "r10+8")
+		}
+		println("  jmp %s", m.fn.name)
+	}
+
+	// A stub for a method with a value receiver loads the receiver
+	// from the address passed to it.
+	for _, m := range derefMethods {
+		first := 0
+		if returnsViaPointer(m.fn.ty.returnTy) {
+			first = 1
+		}
+		reg := argreg64[first]
+		recv := m.fn.ty.params
+		println(".L.deref.%s:", m.fn.name)
+		if isFlonum(recv) {
+			// The receiver is passed in xmm0 instead, so the rest of
+			// the arguments move to the previous registers.
+			nargs, nfloats := countParams(m.ty.params)
+			shiftFloatArgs(nfloats)
+			loadFloat(recv, "xmm0", reg)
+			for j := first; j < first+nargs; j++ {
+				println("  mov %s, %s", argreg64[j], argreg64[j+1])
+			}
+		} else {
+			loadReg(recv, reg, reg)
+		}
+		println("  jmp %s", m.fn.name)
+	}
+}
+
+// Returns the numbers of the parameters passed in general-purpose
+// registers and of those passed in xmm registers.
+
+func countParams(params *Type) (int, int) {
+	nargs, nfloats := 0, 0
+	for p := params; p != nil; p = p.next {
+		if isFlonum(p) {
+			nfloats++
+		} else {
+			nargs++
+		}
+	}
+	return nargs, nfloats
+}
+
+// Move the floating-point arguments to the next registers to pass a
+// receiver in xmm0.
+
+func shiftFloatArgs(n int) {
+	for j := n; j > 0; j-- {
+		println("  movsd xmm%d, xmm%d", j, j-1)
+	}
+}
+
+func hasMethod(methods []*Method, m *Method) bool {
+	for _, x := range methods {
+		if x == m {
+			return true
+		}
 	}
+	return false
 }
 
 func codegen(prog *Obj) {
 	assignLvarOffsets(prog)
 	emitData(prog)
 	emitText(prog)
+	emitTypes()
+	emitStubs()
 }
@@ -11,15 +11,38 @@ func main() {
 		return
 	}
 
-	tok, err := tokenizeFile(os.Args[1])
+	if err := compile(os.Args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if len(errorList) > 0 {
+		printErrors()
+		os.Exit(1)
+	}
+}
+
+// Compiles the file at `path`. The errors in the program are added to
+// errorList, and the assembly is emitted only if there are none.
 
+func compile(path string) error {
+	// The compilation stops at too many errors.
+	defer func() {
+		if r := recover(); r != nil && len(errorList) == 0 {
+			panic(r)
+		}
+	}()
+
+	tok, err := tokenizeFile(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+		return err
 	}
 
 	prog := parse(tok)
+	if len(errorList) > 0 {
+		return nil
+	}
 
 	// Traverse the AST to emit assembly.
 	codegen(prog)
+	return nil
 }
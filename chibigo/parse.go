@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"math/big"
+)
 
 //
 // Parser
@@ -11,75 +14,169 @@ import "fmt"
 var locals *Obj
 var globals *Obj
 
+// The function being parsed
+var currentFn *Obj
+
+// Lists of all goto statements and labels in the current function.
+var gotos *Node
+var labels *Node
+
+// Current targets of "break" and "continue" without a label.
+var brkLabel string
+var contLabel string
+
+// The value of `iota` in the constant declaration being parsed, or -1
+// outside of constant declarations.
+var constIota = -1
+
+// The labeled statement about to be parsed, if any.
+var stmtLabel *Node
+
+// The variables and constants whose declarations are being parsed.
+var pendingDecls []*pendingDecl
+
 var scope *Scope = new(Scope)
 
+// The scopes of the package-level declarations of the program and of
+// the runtime. Both are nested in the scope of the predeclared
+// identifiers, so that the program does not see the names declared by
+// the runtime, such as the functions of libc, and may declare its own.
+var programScope *Scope
+var runtimeScope *Scope
+
+// The functions hashing and comparing the values of the types used as
+// map keys or converted to interfaces.
+var typeFuncs []*TypeFuncs
+
 type NodeKind int
 
 const (
-	ND_ADD       NodeKind = iota // +
-	ND_SUB                       // -
-	ND_MUL                       // *
-	ND_DIV                       // /
-	ND_NUM                       // Integer
-	ND_NEG                       // unary -
-	ND_EQ                        // ==
-	ND_NE                        // !=
-	ND_LT                        // <
-	ND_LE                        // <=
-	ND_EXPR_STMT                 // Expression statement
-	ND_ASSIGN                    // =
-	ND_ADDR                      // unary &
-	ND_DEREF                     // unary *
-	ND_VAR                       // Variable
-	ND_RETURN                    // "return"
-	ND_BLOCK                     // { ... }
-	ND_FUNCALL                   // Function call
-	ND_IF                        // "if"
-	ND_FOR                       // "for"
+	ND_ADD        NodeKind = iota // +
+	ND_SUB                        // -
+	ND_MUL                        // *
+	ND_DIV                        // /
+	ND_MOD                        // %
+	ND_BITAND                     // &
+	ND_BITOR                      // |
+	ND_BITXOR                     // ^
+	ND_ANDNOT                     // &^
+	ND_SHL                        // <<
+	ND_SHR                        // >>
+	ND_LOGAND                     // &&
+	ND_LOGOR                      // ||
+	ND_NOT                        // !
+	ND_BITNOT                     // unary ^
+	ND_NUM                        // Integer
+	ND_NEG                        // unary -
+	ND_EQ                         // ==
+	ND_NE                         // !=
+	ND_LT                         // <
+	ND_LE                         // <=
+	ND_EXPR_STMT                  // Expression statement
+	ND_ASSIGN                     // =
+	ND_ADDR                       // unary &
+	ND_DEREF                      // unary *
+	ND_VAR                        // Variable
+	ND_RETURN                     // "return"
+	ND_BLOCK                      // { ... }
+	ND_FUNCALL                    // Function call
+	ND_IF                         // "if"
+	ND_FOR                        // "for"
+	ND_COMMA                      // Evaluate lhs, then yield rhs
+	ND_MEMBER                     // . (struct member access)
+	ND_MEMZERO                    // Zero-clear a variable
+	ND_DECL                       // Allocate a variable
+	ND_COMPLIT                    // Composite literal
+	ND_INIT                       // Composite literal element
+	ND_GOTO                       // "goto", "break" or "continue"
+	ND_LABEL                      // Labeled statement
+	ND_SWITCH                     // "switch"
+	ND_CASE                       // "case" or "default"
+	ND_IFACE                      // Conversion to an interface
+	ND_TYPEASSERT                 // Type assertion
+	ND_CLOSURE                    // Function value
+	ND_CAST                       // Integer conversion
+	ND_DEFER                      // "defer"
 )
 
 // AST node type
 
 type Node struct {
-	kind     NodeKind // Node kind
-	next     *Node    // Next node
-	ty       *Type    // Type, e.g. int or pointer to int
-	tok      *Token   // Representative token
-	lhs      *Node    // Left-hand side
-	rhs      *Node    // Right-hand side
-	vr       *Obj
-	val      int    // Used if kind == ND_NUM
-	body     *Node  // Block
-	funcname string // Function call
-	args     *Node  // Function args
-	cond     *Node  // "if" statement
-	then     *Node  // "if" statement
-	els      *Node  // "if" statement
-	init     *Node  // "for" statement
-	inc      *Node  // "for" statement
+	kind      NodeKind // Node kind
+	next      *Node    // Next node
+	ty        *Type    // Type, e.g. int or pointer to int
+	tok       *Token   // Representative token
+	lhs       *Node    // Left-hand side
+	rhs       *Node    // Right-hand side
+	vr        *Obj
+	member    *Member    // Struct member access
+	val       int        // Used if kind == ND_NUM
+	cval      *big.Int   // Exact value of a constant
+	fval      *big.Float // Exact value of a floating-point constant
+	body      *Node      // Block
+	funcname  string     // Function call
+	funcTy    *Type      // Function call
+	args      *Node      // Function args
+	retBuffer *Obj       // Function call returning an aggregate
+	target    *Type      // Type assertion
+	method    *Method    // Method value or method expression
+	cond      *Node      // "if" statement
+	then      *Node      // "if" statement
+	els       *Node      // "if" statement
+	init      *Node      // "if" or "for" statement
+	inc       *Node      // "for" statement
+	loopVars  []*Obj     // Per-iteration variables of a "for" statement
+
+	// "break" and "continue"
+	brkLabel  string
+	contLabel string
+
+	// "switch" statement
+	defaultCase *Node
+
+	// Goto or labeled statement
+	label       string
+	uniqueLabel string
+	gotoNext    *Node
+	scope       *Scope
+	vrs         []*VarScope // Variables visible in each enclosing scope
 }
 
 type Obj struct {
-	next       *Obj
-	name       string // Variable name
-	ty         *Type  // Type
-	isLocal    bool   // local or global/function
-	offset     int    // Local variable
-	isFunction bool   // Global variable or function
-	params     *Obj
-	body       *Node
-	locals     *Obj
-	stackSize  int
-	initData   string // Global variable
-	init       *Node  // Global variable initial value
+	next         *Obj
+	name         string // Variable name
+	ty           *Type  // Type
+	isLocal      bool   // local or global/function
+	offset       int    // Local variable
+	isParam      bool   // Parameter or result variable
+	isBoxed      bool   // Local variable allocated on the heap
+	isFunction   bool   // Global variable or function
+	isDefinition bool   // Function with a body
+	isStatic     bool   // Function or global variable not exported
+	fn           *Obj   // Function of a local variable
+	outer        *Obj   // Variable of an enclosing function referred to by a captured variable
+	params       *Obj
+	results      *Obj // Result variables
+	retPtr       *Obj // Where to store an aggregate result
+	body         *Node
+	locals       *Obj
+	stackSize    int
+	parent       *Obj   // Function enclosing a function literal
+	captures     []*Obj // Variables captured by a function literal
+	numFuncLits  int    // Number of function literals in a function
+	hasDefer     bool   // Function containing a "defer" statement
+	isTemp       bool   // Unaddressable value of a composite literal or a receive
+	initData     string // Global variable
 }
 
 // Scope for local or global variables.
 
 type VarScope struct {
-	next  *VarScope
-	name  string
-	vrObj *Obj
+	next    *VarScope
+	name    string
+	vrObj   *Obj
+	typeDef *Type
+	con     *Node // Constant
 }
 
 // Represents a block scope.
@@ -113,6 +210,61 @@ func findVar(tok *Token) *Obj {
 	return nil
 }
 
+// Find a type declared by "type" by name.
+
+func findTypedef(tok *Token) *Type {
+	if tok.kind != TK_IDENT {
+		return nil
+	}
+	for sc := scope; sc != nil; sc = sc.next {
+		for sc2 := sc.vrs; sc2 != nil; sc2 = sc2.next {
+			if equal(tok, sc2.name) {
+				return sc2.typeDef
+			}
+		}
+	}
+	return nil
+}
+
+// Find a constant declared by "const" by name.
+
+func findConst(tok *Token) *Node {
+	if tok.kind != TK_IDENT {
+		return nil
+	}
+	for sc := scope; sc != nil; sc = sc.next {
+		for sc2 := sc.vrs; sc2 != nil; sc2 = sc2.next {
+			if equal(tok, sc2.name) {
+				return sc2.con
+			}
+		}
+	}
+	return nil
+}
+
+// Find a variable declared in the innermost scope by name.
+
+func findVarInCurrentScope(tok *Token) *Obj {
+	for sc := scope.vrs; sc != nil; sc = sc.next {
+		if equal(tok, sc.name) {
+			return sc.vrObj
+		}
+	}
+	return nil
+}
+
+// Returns true if `tok` is declared in the current scope as a variable,
+// a function, a type or a constant.
+
+func isDeclared(tok *Token) bool {
+	for sc := scope.vrs; sc != nil; sc = sc.next {
+		if equal(tok, sc.name) {
+			return true
+		}
+	}
+	return false
+}
+
 func newNode(kind NodeKind, tok *Token) *Node {
 	node := new(Node)
 	node.kind = kind
@@ -139,6 +291,39 @@ func newNum(val int, tok *Token) *Node {
 	return node
 }
 
+// Returns a constant of the value `v`. The constant has the type `ty`,
+// which is the untyped int type for the result of an operation on
+// untyped constants.
+
+func newConst(v *big.Int, ty *Type, tok *Token) *Node {
+	node := newNode(ND_NUM, tok)
+	node.cval = v
+	if v.IsInt64() {
+		node.val = int(v.Int64())
+	} else {
+		node.val = int(v.Uint64())
+	}
+	node.ty = ty
+	return node
+}
+
+func newBool(val bool, tok *Token) *Node {
+	v := 0
+	if val {
+		v = 1
+	}
+	return newConst(big.NewInt(int64(v)), tyUntypedBool, tok)
+}
+
+// Returns a floating-point constant of the value `v`.
+
+func newFloatConst(v *big.Float, ty *Type, tok *Token) *Node {
+	node := newNode(ND_NUM, tok)
+	node.fval = v
+	node.ty = ty
+	return node
+}
+
 func newVarNode(vr *Obj, tok *Token) *Node {
 	node := newNode(ND_VAR, tok)
 	node.vr = vr
@@ -165,6 +350,7 @@ func newVar(name string, ty *Type) *Obj {
 func newLvar(name string, ty *Type) *Obj {
 	vr := newVar(name, ty)
 	vr.isLocal = true
+	vr.fn = currentFn
 	vr.next = locals
 	locals = vr
 	return vr
@@ -195,53 +381,97 @@ func newStringLiteral(str string, ty *Type) *Obj {
 	return vr
 }
 
+func getPunct(tok *Token) string {
+	return currentInput[tok.pos : int(tok.pos)+tok.len]
+}
+
 func getIdent(tok *Token) string {
 	if tok.kind != TK_IDENT {
 		errorTok(tok, "expected an identifier")
 	}
-	return currentInput[tok.loc : tok.loc+tok.len]
+	return currentInput[tok.pos : int(tok.pos)+tok.len]
 }
 
-// func-params = (param ("," param)*)? ")"
-// param       = declspec declarator
+// func-params = "(" (param ("," param)* ","?)? ")"
+// param       = ident declarator
+//             | ident
+//             | declarator
+//
+// Either all parameters are named or none is. In a named list, a bare
+// identifier shares the type of the parameter which follows it;
+// otherwise it is a type name.
 
 func funcParams(rest **Token, tok *Token) *Type {
-	tok = tok.next
+	tok = skip(tok, "(")
 
-	head := new(Type)
-	cur := head
+	type param struct {
+		name *Token
+		ty   *Type
+	}
+	var params []param
+	named := false
 
 	for !equal(tok, ")") {
-		if cur != head {
+		if len(params) > 0 {
 			tok = skip(tok, ",")
+			if equal(tok, ")") {
+				break
+			}
+		}
+
+		if tok.kind == TK_IDENT && (equal(tok.next, ",") || equal(tok.next, ")")) {
+			params = append(params, param{name: tok})
+			tok = tok.next
+		} else if tok.kind == TK_IDENT && findTypedef(tok) == nil {
+			name := tok
+			params = append(params, param{name, declarator(&tok, tok.next)})
+			named = true
+		} else {
+			params = append(params, param{nil, declarator(&tok, tok)})
 		}
-		vr := newNode(ND_VAR, tok)
-		ty := declarator(&tok, tok.next)
-		ty.name = vr.tok
-		cur.next = copyType(ty)
-		cur = cur.next
 	}
 	*rest = tok.next
-	return head.next
-}
 
-// type-suffix = ("(" func-params
-
-func typeSuffix(rest **Token, tok *Token) *Type {
-	if equal(tok, "(") == true {
-		return funcParams(rest, tok)
+	var ty *Type
+	for i := len(params) - 1; i >= 0; i-- {
+		p := &params[i]
+		if !named {
+			if p.ty == nil {
+				p.ty = declspec(&tok, p.name)
+				p.name = nil
+			}
+			continue
+		}
+		if p.ty != nil {
+			ty = p.ty
+		}
+		if p.name == nil || ty == nil {
+			errorTok(tok, "mixed named and unnamed parameters")
+		}
+		p.ty = ty
 	}
 
-	*rest = tok
-	return nil
+	head := new(Type)
+	cur := head
+	for _, p := range params {
+		cur.next = copyType(p.ty)
+		cur = cur.next
+		cur.name = p.name
+	}
+	return head.next
 }
 
-// declspec = "int" || "char"
+// declspec = "int" | "char" | "string" | struct-decl | "interface" interface-decl | typedef-name
 
 func declspec(rest **Token, tok *Token) *Type {
 	if equal(tok, "char") {
 		*rest = tok.next
-		return tyChar
+		return tyInt8
+	}
+
+	if equal(tok, "string") {
+		*rest = tok.next
+		return tyString
 	}
 
 	if equal(tok, "int") {
@@ -249,504 +479,4149 @@ func declspec(rest **Token, tok *Token) *Type {
 		return tyInt
 	}
 
-	errorTok(tok, "Found an unsupported specifier")
-	return nil
-}
-
-// declarator = "[" num "]" declarator
-//            | "*"* declspec
-
-func declarator(rest **Token, tok *Token) *Type {
-	if equal(tok, "[") {
-		sz, err := getNumber(tok.next)
-		if err != nil {
-			fmt.Printf("Error: ", err)
-			return nil
-		}
-		tok = skip(tok.next.next, "]")
-		base := declarator(&tok, tok)
-		*rest = tok
-		return arrayOf(base, sz)
+	if equal(tok, "struct") {
+		return structDecl(rest, tok.next)
 	}
 
-	tmp := tok
-	for equal(tmp, "*") {
-		tmp = tmp.next
+	if equal(tok, "interface") {
+		return interfaceDecl(rest, tok.next)
 	}
 
-	ty := declspec(&tmp, tmp)
-	for consume(&tok, tok, "*") {
-		ty = pointerTo(ty)
+	if ty := findTypedef(tok); ty != nil {
+		resolveType(ty)
+		*rest = tok.next
+		return ty
 	}
 
-	*rest = tmp
-	return ty
+	errorTok(tok, "Found an unsupported specifier")
+	return nil
 }
 
-// declaration = "var" ident (("," ident)?)* declarator ("=" expr ("," expr)?)*)? ";"
+// struct-decl = "{" (ident ("," ident)* declarator ";")* "}"
 
-func declaration(rest **Token, tok *Token) *Node {
-	vrs_head := storeIdentTemp(&tok, tok)
-	ty := declarator(&tok, tok)
-	head := new(Node)
+func structDecl(rest **Token, tok *Token) *Type {
+	tok = skip(tok, "{")
+
+	head := new(Member)
 	cur := head
-	if consume(&tok, tok, "=") {
-		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
-			vr := newLvar(getIdent(vr_cur.tok), ty)
 
-			lhs := newVarNode(vr, ty.name)
-			rhs := assign(&tok, tok)
-			if ty != nil && ty.kind == TY_ARRAY {
-				i := 0
-				for cur_node := rhs; cur_node != nil; cur_node = cur_node.next {
-					tmp := newNum(i, tok)
-					addType(tmp)
-					new_lhs := newUnary(ND_DEREF, newAdd(lhs, tmp, tok), tok)
-					node := newBinary(ND_ASSIGN, new_lhs, rhs, tok)
-					i += 1
-					cur.next = newUnary(ND_EXPR_STMT, node, tok)
-					cur = cur.next
-					rhs = rhs.next
-				}
-			} else {
-				node := newBinary(ND_ASSIGN, lhs, rhs, tok)
-				cur.next = newUnary(ND_EXPR_STMT, node, tok)
-				cur = cur.next
+	for !equal(tok, "}") {
+		names := new(Member)
+		last := names
+		for {
+			last.next = new(Member)
+			last = last.next
+			last.name = tok
+			getIdent(tok)
+			tok = tok.next
+			if !equal(tok, ",") {
+				break
 			}
-			consume(&tok, tok, ",")
-		}
-	} else {
-		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
-			newLvar(getIdent(vr_cur.tok), ty)
+			tok = tok.next
 		}
-	}
-
-	node := newNode(ND_BLOCK, tok)
-	node.body = head.next
-	*rest = tok.next
-	return node
-}
-
-// Returns true if a given token represents a type.
-
-func isTypename(tok *Token) bool {
-	return equal(tok, "char") || equal(tok, "int")
-}
 
-// stmt = "return" expr ";"
-//      | "if" expr "{" stmt "}" ("else" "{" stmt "}")?
-//      | "for" (expr ";" expr ";" expr)? "{" stmt "}"
-//      | "for" expr "{" stmt "}"
-//      | "{" compound-stmt
-//      | expr-stmt
+		ty := declarator(&tok, tok)
+		for mem := names.next; mem != nil; mem = mem.next {
+			mem.ty = ty
+			cur.next = mem
+			cur = cur.next
+		}
 
-func stmt(rest **Token, tok *Token) *Node {
-	if equal(tok, "return") {
-		node := newNode(ND_RETURN, tok)
-		node.lhs = expr(&tok, tok.next)
-		*rest = skip(tok, ";")
-		return node
-	}
-	if equal(tok, "if") {
-		node := newNode(ND_IF, tok)
-		node.cond = expr(&tok, tok.next)
-		node.then = stmt(&tok, tok)
-		if equal(tok, "else") {
-			node.els = stmt(&tok, tok.next)
+		if !equal(tok, "}") {
+			tok = skip(tok, ";")
 		}
-		*rest = tok
-		return node
 	}
-	if equal(tok, "for") {
-		node := newNode(ND_FOR, tok)
-		tok = tok.next
-		if !equal(tok, "{") {
-			node.init = expr(&tok, tok)
-			if equal(tok, ";") {
-				// for
-				tok = skip(tok, ";")
-				node.cond = expr(&tok, tok)
-				tok = skip(tok, ";")
-				node.inc = expr(&tok, tok)
-			} else {
-				// while
-				node.cond = node.init
-				node.init = nil
+	*rest = tok.next
+
+	ty := structType()
+	ty.members = head.next
+	for mem := ty.members; mem != nil; mem = mem.next {
+		for mem2 := ty.members; mem2 != mem; mem2 = mem2.next {
+			if equal(mem.name, getIdent(mem2.name)) {
+				errorTok(mem.name, "duplicate field %s", getIdent(mem.name))
 			}
 		}
-		node.then = stmt(&tok, tok)
-		*rest = tok
-		return node
-	}
-	if equal(tok, "{") {
-		return componentStmt(rest, tok.next)
 	}
-	return exprStmt(rest, tok)
+	layoutMembers(ty)
+	return ty
 }
 
-// compound-stmt = (declaration | stmt)* "}"
+// interface-decl = "{" (ident func-params result? ";")* "}"
+//
+// The methods are sorted by name, which gives their order in itabs.
 
-func componentStmt(rest **Token, tok *Token) *Node {
-	node := newNode(ND_BLOCK, tok)
+func interfaceDecl(rest **Token, tok *Token) *Type {
+	tok = skip(tok, "{")
+	ty := interfaceType()
 
-	head := new(Node)
-	cur := head
-	enterScope()
 	for !equal(tok, "}") {
-		if equal(tok, "var") {
-			cur.next = declaration(&tok, tok)
-			cur = cur.next
-		} else {
-			cur.next = stmt(&tok, tok)
-			cur = cur.next
+		fnTy := signature(&tok, tok)
+		m := &Method{name: getIdent(fnTy.name), ty: fnTy}
+
+		link := &ty.methods
+		for *link != nil && (*link).name < m.name {
+			link = &(*link).next
+		}
+		if *link != nil && (*link).name == m.name {
+			errorTok(fnTy.name, "duplicate method %s", m.name)
 		}
+		m.next = *link
+		*link = m
+		tok = skipSemicolon(tok)
 	}
-	leaveScope()
-	node.body = head.next
+
 	*rest = tok.next
-	return node
+	return ty
+}
+
+func getStructMember(ty *Type, tok *Token) *Member {
+	for mem := ty.members; mem != nil; mem = mem.next {
+		if equal(tok, getIdent(mem.name)) {
+			return mem
+		}
+	}
+	errorTok(tok, "no such member")
+	return nil
 }
 
-// expr-stmt = expr? ";"
+// Accesses a struct member. A pointer to a struct is dereferenced
+// automatically, so `p.x` is short for `(*p).x`.
 
-func exprStmt(rest **Token, tok *Token) *Node {
-	if equal(tok, ";") {
-		*rest = tok.next
-		return newNode(ND_BLOCK, tok)
+func structRef(lhs *Node, tok *Token) *Node {
+	addType(lhs)
+	if lhs.ty.kind == TY_PTR && lhs.ty.base.kind == TY_STRUCT {
+		lhs = newUnary(ND_DEREF, lhs, tok)
+		addType(lhs)
+	}
+	if lhs.ty.kind != TY_STRUCT {
+		errorTok(lhs.tok, "not a struct")
 	}
 
-	node := newNode(ND_EXPR_STMT, tok)
-	node.lhs = expr(&tok, tok)
-	*rest = skip(tok, ";")
+	node := newUnary(ND_MEMBER, lhs, tok)
+	node.member = getStructMember(lhs.ty, tok.next)
 	return node
 }
 
-// expr = assign
+// const-decl = "const" (const-spec | "(" (const-spec ";")* ")") ";"
+//
+// `iota` is the index of the const-spec in the declaration.
 
-func expr(rest **Token, tok *Token) *Node {
-	return assign(rest, tok)
+func constDecl(rest **Token, tok *Token) {
+	tok = skip(tok, "const")
+	if !equal(tok, "(") {
+		constIota = 0
+		constSpec(&tok, tok, nil)
+		constIota = -1
+		*rest = skip(tok, ";")
+		return
+	}
+
+	tok = tok.next
+	var prev *Token
+	for constIota = 0; !equal(tok, ")"); constIota++ {
+		prev = constSpec(&tok, tok, prev)
+		if !equal(tok, ")") {
+			tok = skip(tok, ";")
+		}
+	}
+	constIota = -1
+	*rest = skip(tok.next, ";")
 }
 
-// array-init = declarator "{" (num (, num)*)? "}"
+// const-spec = ident ("," ident)* (declarator? "=" expr-list)?
+//
+// A const-spec without the expression list repeats the type and the
+// expressions of the previous one, which start at `prev`. Returns
+// where the type and the expressions of this one start.
 
-func arrayInit(rest **Token, tok *Token) *Node {
-	head := new(Node)
-	cur := head
-	ty := declarator(&tok, tok)
-	tok = skip(tok, "{")
-	for i := 0; i < ty.arrayLen; i += 1 {
-		if i != 0 {
-			tok = skip(tok, ",")
+func constSpec(rest **Token, tok *Token, prev *Token) *Token {
+	var names []*Token
+	for {
+		getIdent(tok)
+		names = append(names, tok)
+		addPending(tok)
+		tok = tok.next
+		if !equal(tok, ",") {
+			break
 		}
-		if ty.base.kind == TY_CHAR {
-			ty.kind = TY_CHAR
-			vr := newStringLiteral(tok.str, tok.ty)
-			cur.next = newVarNode(vr, tok)
-			tok = tok.next
-		} else if ty.base.kind == TY_INT {
-			num, err := getNumber(tok)
-			if err != nil {
-				fmt.Printf("Error: ", err)
-				return nil
+		tok = tok.next
+	}
+
+	start := tok
+	if equal(tok, ";") || equal(tok, ")") {
+		if prev == nil {
+			errorTok(tok, "missing init expr for const declaration")
+		}
+		start = prev
+	}
+	var ty *Type
+	t := start
+	if !equal(t, "=") {
+		ty = declarator(&t, t)
+	}
+	values := exprList(&t, skip(t, "="))
+	if start != prev {
+		tok = t
+	}
+	if len(values) < len(names) {
+		errorTok(tok, "missing init expr for const declaration")
+	}
+	if len(values) > len(names) {
+		errorTok(values[len(names)].tok, "extra init expr")
+	}
+
+	for i, name := range names {
+		val := values[i]
+		addType(val)
+		if !isConst(val) && !isStringLiteral(val) {
+			errorTok(val.tok, "initializer of %s is not a constant", getIdent(name))
+		}
+		if ty != nil {
+			if !isUntyped(val.ty) && !identical(val.ty, ty) {
+				errorTok(val.tok, "cannot use a constant of type %s as %s value",
+					typeString(val.ty), typeString(ty))
 			}
-			cur.next = newNum(num, tok)
-			tok = tok.next
+			convertConst(val, ty)
+			val.ty = ty
+		}
+		if !isBlank(name) {
+			if isDeclared(name) {
+				errorTok(name, "%s redeclared", getIdent(name))
+			}
+			pushScope(getIdent(name), nil).con = val
 		}
-		cur = cur.next
 	}
-	tok = skip(tok, "}")
-	head.next.ty = ty
 	*rest = tok
-	return head.next
+	return start
 }
 
-// assign = equality ("=" assign)?
-//        | array-init
+// Returns a use of the constant `con` at `tok`.
 
-func assign(rest **Token, tok *Token) *Node {
-	if equal(tok, "[") == true {
-		return arrayInit(rest, tok)
+func constUse(con *Node, tok *Token) *Node {
+	if isStringLiteral(con) {
+		node := stringLiteral(con.tok)
+		addType(node)
+		node.ty = con.ty
+		return node
 	}
-	node := equality(&tok, tok)
-	if equal(tok, "=") {
-		return newBinary(ND_ASSIGN, node, assign(rest, tok.next), tok)
+	if con.fval != nil {
+		return newFloatConst(con.fval, con.ty, tok)
 	}
-	*rest = tok
-	return node
+	return newConst(constOf(con), con.ty, tok)
 }
 
-// equality = relational ("==" relational | "!=" relational)*
+// type-decl = "type" (type-spec | "(" (type-spec ";")* ")") ";"
+//
+// Declares the named types and the aliases of a type declaration.
+// Their underlying types are parsed later by resolveType so that a
+// type can refer to itself and to types declared after it.
 
-func equality(rest **Token, tok *Token) *Node {
-	node := relational(&tok, tok)
+func typeDecl(rest **Token, tok *Token) []*Type {
+	tok = skip(tok, "type")
+	if !equal(tok, "(") {
+		ty := typeSpec(&tok, tok)
+		*rest = skip(tok, ";")
+		return []*Type{ty}
+	}
 
-	for {
-		start := tok
-		if equal(tok, "==") {
-			node = newBinary(ND_EQ, node, relational(&tok, tok.next), start)
-			continue
-		}
-		if equal(tok, "!=") {
-			node = newBinary(ND_NE, node, relational(&tok, tok.next), start)
-			continue
+	var types []*Type
+	for tok = tok.next; !equal(tok, ")"); {
+		types = append(types, typeSpec(&tok, tok))
+		if !equal(tok, ")") {
+			tok = skip(tok, ";")
 		}
-		*rest = tok
-		return node
 	}
+	*rest = skip(tok.next, ";")
+	return types
 }
 
-// relational = add ("<" add | "<=" add | ">" add | ">=" add)*
+// type-spec = ident "="? declarator
+//
+// Returns an incomplete type to be filled in by resolveType.
 
-func relational(rest **Token, tok *Token) *Node {
-	node := add(&tok, tok)
+func typeSpec(rest **Token, tok *Token) *Type {
+	name := tok
+	getIdent(name)
+	if !isBlank(name) && isDeclared(name) {
+		errorTok(name, "%s redeclared", getIdent(name))
+	}
+	ty := &Type{kind: TY_VOID, align: 1, typeName: name, decl: tok.next}
+	pushScope(getIdent(name), nil).typeDef = ty
 
-	for {
+	// Skip the declarator.
+	depth := 0
+	for tok = tok.next; depth > 0 || !equal(tok, ";") && !equal(tok, ")"); tok = tok.next {
+		if tok.kind == TK_EOF {
+			errorTok(name, "unterminated type declaration")
+		}
+		if equal(tok, "(") || equal(tok, "[") || equal(tok, "{") {
+			depth++
+		} else if equal(tok, ")") || equal(tok, "]") || equal(tok, "}") {
+			depth--
+		}
+	}
+	*rest = tok
+	return ty
+}
+
+// Parses the declarator of a named type or an alias declared by
+// type-spec. A named type is distinct from any other type, including
+// the one it is declared with, while an alias is another name for the
+// same type.
+
+func resolveType(ty *Type) {
+	if ty.decl == nil {
+		return
+	}
+	tok := ty.decl
+	ty.decl = nil
+	name := ty.typeName
+	alias := consume(&tok, tok, "=")
+
+	base := declarator(&tok, tok)
+	if isIncomplete(base) {
+		errorTok(name, "invalid recursive type %s", getIdent(name))
+	}
+
+	copies := ty.copies
+	*ty = *copyType(base)
+	if !alias {
+		ty.typeName = name
+		ty.origin = nil
+		if ty.kind != TY_INTERFACE {
+			ty.methods = nil
+		}
+	}
+	ty.copies = nil
+
+	for _, c := range copies {
+		cname, next := c.name, c.next
+		*c = *ty
+		c.origin = ty
+		c.name, c.next = cname, next
+	}
+
+	if containsType(ty, ty) {
+		errorTok(name, "invalid recursive type %s", getIdent(name))
+	}
+}
+
+// declarator = "*" declarator
+//            | "func" func-signature
+//            | "[" const-int? "]" declarator
+//            | "map" "[" declarator "]" declarator
+//            | "chan" "<-"? declarator
+//            | "<-" "chan" declarator
+//            | declspec
+
+func declarator(rest **Token, tok *Token) *Type {
+	if equal(tok, "chan") {
+		if equal(tok.next, "<-") {
+			return chanOf(declarator(rest, tok.next.next), CHAN_SEND)
+		}
+		return chanOf(declarator(rest, tok.next), CHAN_BOTH)
+	}
+
+	if equal(tok, "<-") {
+		return chanOf(declarator(rest, skip(tok.next, "chan")), CHAN_RECV)
+	}
+
+	if equal(tok, "*") {
+		return pointerTo(declarator(rest, tok.next))
+	}
+
+	if equal(tok, "[") && equal(tok.next, "]") {
+		return sliceOf(declarator(rest, tok.next.next))
+	}
+
+	if equal(tok, "map") {
 		start := tok
-		if equal(tok, "<") {
-			node = newBinary(ND_LT, node, add(&tok, tok.next), start)
+		key := declarator(&tok, skip(tok.next, "["))
+		tok = skip(tok, "]")
+		if !isComparable(key) {
+			errorTok(start, "invalid map key type %s", typeString(key))
+		}
+		return mapOf(key, declarator(rest, tok))
+	}
+
+	if equal(tok, "func") {
+		return funcSignature(rest, tok.next)
+	}
+
+	if equal(tok, "[") {
+		sz := constInt(&tok, tok.next)
+		tok = skip(tok, "]")
+		base := declarator(&tok, tok)
+		*rest = tok
+		return arrayOf(base, sz)
+	}
+
+	return declspec(rest, tok)
+}
+
+// declaration = "var" ident ("," ident)* (declarator ("=" expr ("," expr)*)? | "=" expr ("," expr)*) ";"
+//
+// If the declarator is omitted, the type of each variable is inferred
+// from its initializer. Variables without an initializer are
+// zero-cleared.
+
+func declaration(rest **Token, tok *Token) *Node {
+	vrs_head := storeIdentTemp(&tok, tok)
+	var pending []*pendingDecl
+	for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
+		pending = append(pending, addPending(vr_cur.tok))
+	}
+	var ty *Type
+	if !equal(tok, "=") {
+		ty = declarator(&tok, tok)
+		for _, pd := range pending {
+			pd.ty = ty
+		}
+	}
+	head := new(Node)
+	cur := head
+	if consume(&tok, tok, "=") {
+		op := tok
+		unpack, values := unpackTuple(exprList(&tok, tok), countNodes(vrs_head), op)
+		if unpack != nil {
+			cur.next = unpack
+			cur = cur.next
+		}
+		i := 0
+		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
+			rhs := values[i]
+			i++
+			vrTy := ty
+			if vrTy == nil {
+				vrTy = inferType(rhs)
+			}
+			vr := newLvar(getIdent(vr_cur.tok), vrTy)
+			cur.next = newUnary(ND_EXPR_STMT, newDecl(vr, vr_cur.tok), vr_cur.tok)
+			cur.next.next = newUnary(ND_EXPR_STMT, newInit(newVarNode(vr, vr_cur.tok), rhs, op), op)
+			cur = cur.next.next
+		}
+	} else {
+		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
+			vr := newLvar(getIdent(vr_cur.tok), ty)
+			cur.next = newUnary(ND_EXPR_STMT, newDecl(vr, vr_cur.tok), vr_cur.tok)
+			cur.next.next = newUnary(ND_EXPR_STMT, newMemzero(vr, vr_cur.tok), vr_cur.tok)
+			cur = cur.next.next
+		}
+	}
+
+	node := newNode(ND_BLOCK, tok)
+	node.body = head.next
+	*rest = skipSemicolon(tok)
+	return node
+}
+
+// Returns an expression initializing `lhs` with `rhs`.
+
+func newInit(lhs *Node, rhs *Node, tok *Token) *Node {
+	addType(lhs)
+	if rhs.kind == ND_COMPLIT && lhs.ty.kind != TY_INTERFACE {
+		return initComplit(lhs, rhs)
+	}
+	checkAssign(rhs, lhs.ty)
+	return newBinary(ND_ASSIGN, lhs, rhs, tok)
+}
+
+func newMemzero(vr *Obj, tok *Token) *Node {
+	return newUnary(ND_MEMZERO, newVarNode(vr, tok), tok)
+}
+
+// Returns an expression allocating the local variable `vr`. A variable
+// whose address is taken lives on the heap, so that each execution of
+// its declaration creates a distinct variable. For other variables,
+// the expression does nothing.
+
+func newDecl(vr *Obj, tok *Token) *Node {
+	node := newNode(ND_DECL, tok)
+	node.vr = vr
+	return node
+}
+
+// Skips the semicolon terminating a statement, which may be omitted
+// before a closing "}".
+
+func skipSemicolon(tok *Token) *Token {
+	if equal(tok, "}") {
+		return tok
+	}
+	return skip(tok, ";")
+}
+
+// Returns the type of a variable initialized by `node`.
+
+func inferType(node *Node) *Type {
+	if node.kind == ND_COMPLIT {
+		return node.ty
+	}
+	addType(node)
+	if node.ty == nil {
+		errorTok(node.tok, "cannot infer the type of the initializer")
+	}
+	if node.ty == tyVoid {
+		if node.tok.kind == TK_IDENT {
+			errorTok(node.tok, "%s() (no value) used as value", getIdent(node.tok))
+		}
+		errorTok(node.tok, "function call (no value) used as value")
+	}
+	if isUntyped(node.ty) {
+		convertConst(node, defaultType(node.ty))
+	}
+	return node.ty
+}
+
+// Returns true if `tok` starts a short variable declaration,
+// i.e. ident ("," ident)* ":=".
+
+func isShortVarDecl(tok *Token) bool {
+	for tok.kind == TK_IDENT {
+		tok = tok.next
+		if equal(tok, ":=") {
+			return true
+		}
+		if !equal(tok, ",") {
+			return false
+		}
+		tok = tok.next
+	}
+	return false
+}
+
+// short-var-decl = ident ("," ident)* ":=" expr-list
+//
+// At least one of the identifiers must be new in the current scope.
+// The others are assigned to.
+
+func shortVarDecl(rest **Token, tok *Token) *Node {
+	start := tok
+	var names []*Token
+	for !equal(tok, ":=") {
+		if len(names) > 0 {
+			tok = skip(tok, ",")
+		}
+		getIdent(tok)
+		for _, name := range names {
+			if !isBlank(tok) && equal(name, getIdent(tok)) {
+				errorTok(tok, "%s repeated on left side of :=", getIdent(tok))
+			}
+		}
+		names = append(names, tok)
+		if findVarInCurrentScope(tok) == nil {
+			addPending(tok)
+		}
+		tok = tok.next
+	}
+	op := tok
+
+	// Initializers are evaluated in the enclosing scope, so parse
+	// them all before declaring any new variable.
+	unpack, values := unpackTuple(exprList(&tok, tok.next), len(names), op)
+
+	var lhs []*Node
+	decls := new(Node)
+	last := decls
+	isNew := false
+	reassign := false
+	for i, name := range names {
+		if isBlank(name) {
+			lhs = append(lhs, nil)
 			continue
 		}
-		if equal(tok, "<=") {
-			node = newBinary(ND_LE, node, add(&tok, tok.next), start)
+		if vr := findVarInCurrentScope(name); vr != nil {
+			lhs = append(lhs, newVarNode(vr, name))
+			reassign = true
 			continue
 		}
-		if equal(tok, ">") {
-			node = newBinary(ND_LT, add(&tok, tok.next), node, start)
+		vr := newLvar(getIdent(name), inferType(values[i]))
+		lhs = append(lhs, newVarNode(vr, name))
+		last.next = newUnary(ND_EXPR_STMT, newDecl(vr, name), name)
+		last = last.next
+		isNew = true
+	}
+	if !isNew {
+		errorTok(op, "no new variables on left side of :=")
+	}
+
+	node := newNode(ND_BLOCK, start)
+	node.body = decls.next
+	if reassign && unpack == nil {
+		last.next = tupleAssign(lhs, values, op)
+	} else {
+		cur := last
+		if unpack != nil {
+			cur.next = unpack
+			cur = cur.next
+		}
+		for i, vr := range lhs {
+			if vr == nil {
+				cur.next = newUnary(ND_EXPR_STMT, values[i], op)
+			} else {
+				cur.next = newUnary(ND_EXPR_STMT, newInit(vr, values[i], op), op)
+			}
+			cur = cur.next
+		}
+	}
+	*rest = tok
+	return node
+}
+
+// expr-list = expr ("," expr)*
+
+func exprList(rest **Token, tok *Token) []*Node {
+	var list []*Node
+	list = append(list, assign(&tok, tok))
+	for equal(tok, ",") {
+		list = append(list, assign(&tok, tok.next))
+	}
+	*rest = tok
+	return list
+}
+
+func countNodes(node *Node) int {
+	n := 0
+	for ; node != nil; node = node.next {
+		n++
+	}
+	return n
+}
+
+func isBlank(tok *Token) bool {
+	return equal(tok, "_")
+}
+
+// If a call of a function with multiple results is assigned to `n`
+// operands, returns a statement evaluating the call and the list
+// of its results. Otherwise returns `values` as is.
+
+func unpackTuple(values []*Node, n int, tok *Token) (*Node, []*Node) {
+	if len(values) == 1 && n > 1 {
+		node := values[0]
+		if n == 2 && node.kind == ND_TYPEASSERT {
+			commaOk(node)
+		}
+		if n == 2 && isMapIndex(node) {
+			mapCommaOk(node)
+		}
+		if n == 2 && isChanRecv(node) {
+			chanCommaOk(node)
+		}
+		addType(node)
+		if node.ty.kind != TY_TUPLE {
+			errorTok(tok, "assignment mismatch: %d variables but 1 value", n)
+		}
+		var results []*Node
+		for mem := node.ty.members; mem != nil; mem = mem.next {
+			res := newUnary(ND_MEMBER, newVarNode(node.retBuffer, tok), tok)
+			res.member = mem
+			results = append(results, res)
+		}
+		if len(results) != n {
+			errorTok(tok, "assignment mismatch: %d variables but %s returns %d values",
+				n, node.funcname, len(results))
+		}
+		return newUnary(ND_EXPR_STMT, node, tok), results
+	}
+
+	if len(values) != n {
+		errorTok(tok, "assignment mismatch: %d variables but %d values", n, len(values))
+	}
+	for _, node := range values {
+		if node.kind == ND_COMPLIT {
 			continue
 		}
-		if equal(tok, ">=") {
-			node = newBinary(ND_LE, add(&tok, tok.next), node, start)
+		addType(node)
+		if node.ty != nil && node.ty.kind == TY_TUPLE {
+			errorTok(node.tok, "multiple-value %s() in single-value context", node.funcname)
+		}
+	}
+	return nil, values
+}
+
+// Returns statements assigning `rhs` to `lhs` pairwise, where a nil
+// element of `lhs` stands for the blank identifier. The operands of
+// the left-hand side and all the values are evaluated before any
+// assignment takes place, so `a, b = b, a` swaps the values.
+
+func tupleAssign(lhs []*Node, rhs []*Node, tok *Token) *Node {
+	if len(lhs) == 1 {
+		if lhs[0] == nil {
+			return newUnary(ND_EXPR_STMT, rhs[0], tok)
+		}
+		addType(lhs[0])
+		checkAssign(rhs[0], lhs[0].ty)
+		return newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, lhs[0], rhs[0], tok), tok)
+	}
+
+	head := new(Node)
+	cur := head
+	targets := make([]*Node, len(lhs))
+	for i, node := range lhs {
+		if node == nil || node.kind == ND_VAR {
+			targets[i] = node
+			continue
+		}
+		// Take the address of a target other than a variable, so
+		// that its operands are evaluated only once.
+		addType(node)
+		checkMapElement(node)
+		ptr := newLvar("", pointerTo(node.ty))
+		addr := newUnary(ND_ADDR, node, tok)
+		cur.next = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(ptr, tok), addr, tok), tok)
+		cur = cur.next
+		targets[i] = newUnary(ND_DEREF, newVarNode(ptr, tok), tok)
+	}
+
+	values := make([]*Node, len(rhs))
+	for i, node := range rhs {
+		if lhs[i] == nil {
+			cur.next = newUnary(ND_EXPR_STMT, node, tok)
+			cur = cur.next
 			continue
 		}
+		addType(lhs[i])
+		checkAssign(node, lhs[i].ty)
+		tmp := newLvar("", inferType(node))
+		cur.next = newUnary(ND_EXPR_STMT, newInit(newVarNode(tmp, tok), node, tok), tok)
+		cur = cur.next
+		values[i] = newVarNode(tmp, tok)
+	}
+
+	for i, target := range targets {
+		if target != nil {
+			cur.next = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, target, values[i], tok), tok)
+			cur = cur.next
+		}
+	}
+	return head.next
+}
+
+// Returns true if a given token represents a type.
+
+func isTypename(tok *Token) bool {
+	return equal(tok, "char") || equal(tok, "int") || equal(tok, "string") || equal(tok, "struct") ||
+		equal(tok, "interface") || equal(tok, "map") || equal(tok, "func") || equal(tok, "chan") ||
+		equal(tok, "<-") && equal(tok.next, "chan") || findTypedef(tok) != nil
+}
+
+// stmt = "return" expr-list? ";"
+//      | defer-stmt
+//      | "if" (simple-stmt ";")? expr "{" stmt "}" ("else" "{" stmt "}")?
+//      | "for" simple-stmt? ";" expr? ";" simple-stmt? "{" stmt "}"
+//      | "for" expr? "{" stmt "}"
+//      | "for" range-clause "{" stmt "}"
+//      | "switch" switch-stmt
+//      | "break" ident? ";"
+//      | "continue" ident? ";"
+//      | "goto" ident ";"
+//      | ident ":" stmt?
+//      | "{" compound-stmt
+//      | expr-stmt
+
+func stmt(rest **Token, tok *Token) *Node {
+	label := stmtLabel
+	stmtLabel = nil
+
+	if equal(tok, "return") {
+		return returnStmt(rest, tok)
+	}
+	if equal(tok, "defer") {
+		return deferStmt(rest, tok)
+	}
+	if equal(tok, "if") {
+		node := newNode(ND_IF, tok)
+		enterScope()
+		init := simpleStmt(&tok, tok.next)
+		if equal(tok, ";") {
+			node.init = init
+			node.cond = boolCond(expr(&tok, tok.next), "if")
+		} else {
+			node.cond = boolCond(condition(init), "if")
+		}
+		node.then = stmt(&tok, tok)
+		if equal(tok, "else") {
+			node.els = stmt(&tok, tok.next)
+		}
+		leaveScope()
 		*rest = tok
 		return node
 	}
-}
+	if equal(tok, "for") {
+		node := newNode(ND_FOR, tok)
+		tok = tok.next
+		enterScope()
+
+		brk := brkLabel
+		cont := contLabel
+		brkLabel = newUniqueName()
+		contLabel = newUniqueName()
+		node.brkLabel = brkLabel
+		node.contLabel = contLabel
+		if label != nil {
+			label.brkLabel = brkLabel
+			label.contLabel = contLabel
+		}
+		var vars *Node
+		if isRangeClause(tok) {
+			vars = rangeClause(&tok, tok, node)
+		} else if !equal(tok, "{") {
+			var init *Node
+			prev := locals
+			if !equal(tok, ";") {
+				init = simpleStmt(&tok, tok)
+			}
+			if equal(tok, ";") {
+				// for
+				node.init = init
+				// Each iteration has its own copy of the variables
+				// declared by the init statement.
+				for vr := locals; vr != prev; vr = vr.next {
+					if vr.name != "" {
+						node.loopVars = append(node.loopVars, vr)
+					}
+				}
+				tok = tok.next
+				if !equal(tok, ";") {
+					node.cond = boolCond(expr(&tok, tok), "for")
+				}
+				tok = skip(tok, ";")
+				if !equal(tok, "{") {
+					node.inc = simpleStmt(&tok, tok)
+				}
+			} else {
+				// while
+				node.cond = boolCond(condition(init), "for")
+			}
+		}
+		node.then = stmt(&tok, tok)
+		if vars != nil {
+			last := vars
+			for last.next != nil {
+				last = last.next
+			}
+			last.next = node.then
+			node.then = newNode(ND_BLOCK, node.then.tok)
+			node.then.body = vars
+		}
+		brkLabel = brk
+		contLabel = cont
+		leaveScope()
+		*rest = tok
+		return node
+	}
+	if equal(tok, "switch") {
+		return switchStmt(rest, tok, label)
+	}
+	if equal(tok, "fallthrough") {
+		errorTok(tok, "fallthrough statement out of place")
+	}
+	if equal(tok, "break") {
+		node := newNode(ND_GOTO, tok)
+		if tok.next.kind == TK_IDENT {
+			target := findLabel(tok.next)
+			if target.brkLabel == "" {
+				errorTok(tok.next, "invalid break label %s", target.label)
+			}
+			node.uniqueLabel = target.brkLabel
+			tok = tok.next
+		} else {
+			if brkLabel == "" {
+				errorTok(tok, "break is not in a loop or switch")
+			}
+			node.uniqueLabel = brkLabel
+		}
+		*rest = skipSemicolon(tok.next)
+		return node
+	}
+	if equal(tok, "continue") {
+		node := newNode(ND_GOTO, tok)
+		if tok.next.kind == TK_IDENT {
+			target := findLabel(tok.next)
+			if target.contLabel == "" {
+				errorTok(tok.next, "invalid continue label %s", target.label)
+			}
+			node.uniqueLabel = target.contLabel
+			tok = tok.next
+		} else {
+			if contLabel == "" {
+				errorTok(tok, "continue is not in a loop")
+			}
+			node.uniqueLabel = contLabel
+		}
+		*rest = skipSemicolon(tok.next)
+		return node
+	}
+	if equal(tok, "goto") {
+		node := newNode(ND_GOTO, tok)
+		if tok.next.kind != TK_IDENT {
+			errorTok(tok.next, "expected a label")
+		}
+		node.label = getIdent(tok.next)
+		node.scope = scope
+		node.vrs = visibleVars()
+		node.gotoNext = gotos
+		gotos = node
+		*rest = skipSemicolon(tok.next.next)
+		return node
+	}
+	if tok.kind == TK_IDENT && equal(tok.next, ":") {
+		node := newNode(ND_LABEL, tok)
+		node.label = getIdent(tok)
+		for l := labels; l != nil; l = l.gotoNext {
+			if l.label == node.label {
+				errorTok(tok, "label %s already defined", node.label)
+			}
+		}
+		node.uniqueLabel = newUniqueName()
+		node.scope = scope
+		node.vrs = visibleVars()
+		node.gotoNext = labels
+		labels = node
+
+		tok = tok.next.next
+		if equal(tok, "}") {
+			node.lhs = newNode(ND_BLOCK, tok)
+			*rest = tok
+			return node
+		}
+		stmtLabel = node
+		node.lhs = stmt(rest, tok)
+
+		// "break" and "continue" may refer to the label only within
+		// the labeled statement.
+		node.brkLabel = ""
+		node.contLabel = ""
+		return node
+	}
+	if equal(tok, "{") {
+		return componentStmt(rest, tok.next)
+	}
+	return exprStmt(rest, tok)
+}
+
+// switch-stmt = (simple-stmt ";")? (expr | type-guard)? "{" case-clause* "}"
+// type-guard  = (ident ":=")? unary "." "(" "type" ")"
+// case-clause = ("case" case-list | "default") ":" block-item* ("fallthrough" ";")?
+// case-list   = expr-list | type-list
+// type-list   = ("nil" | declarator) ("," ("nil" | declarator))*
+//
+// Each clause ends with an implicit "break". The tag is evaluated
+// once into a temporary variable, and a tagless switch matches the
+// first case that is true. A type switch matches the dynamic type
+// of an interface value, which the identifier of the guard holds
+// in each clause as the type of the case if there is only one.
+
+func switchStmt(rest **Token, tok *Token, label *Node) *Node {
+	node := newNode(ND_SWITCH, tok)
+	tok = tok.next
+	enterScope()
+
+	var tag *Node
+	if !equal(tok, "{") && !isTypeGuard(tok) {
+		init := simpleStmt(&tok, tok)
+		if equal(tok, ";") {
+			node.init = init
+			tok = tok.next
+			if !equal(tok, "{") && !isTypeGuard(tok) {
+				tag = expr(&tok, tok)
+			}
+		} else {
+			tag = condition(init)
+		}
+	}
+
+	var guard *Node
+	var bind *Token
+	typeSwitch := isTypeGuard(tok)
+	if typeSwitch {
+		if tok.kind == TK_IDENT && equal(tok.next, ":=") {
+			bind = tok
+			tok = tok.next.next
+		}
+		tag = unary(&tok, tok)
+		tok = skip(skip(skip(skip(tok, "."), "("), "type"), ")")
+		addType(tag)
+		if tag.ty.kind != TY_INTERFACE {
+			errorTok(tag.tok, "%s is not an interface", typeString(tag.ty))
+		}
+	}
+	if tag != nil {
+		val := tag
+		tag = newVarNode(newLvar("", inferType(val)), val.tok)
+		node.lhs = newBinary(ND_ASSIGN, tag, val, val.tok)
+		if typeSwitch {
+			guard = tag
+		}
+	}
+
+	brk := brkLabel
+	brkLabel = newUniqueName()
+	node.brkLabel = brkLabel
+	if label != nil {
+		label.brkLabel = brkLabel
+	}
+
+	tok = skip(tok, "{")
+	head := new(Node)
+	cur := head
+	var fallthru *Node
+	for !equal(tok, "}") {
+		n := newNode(ND_CASE, tok)
+		var bindTy *Type
+		n.uniqueLabel = newUniqueName()
+		if fallthru != nil {
+			fallthru.uniqueLabel = n.uniqueLabel
+			fallthru = nil
+		}
+
+		if equal(tok, "default") {
+			if node.defaultCase != nil {
+				errorTok(tok, "multiple defaults in switch")
+			}
+			node.defaultCase = n
+			tok = tok.next
+		} else if guard != nil {
+			tok = skip(tok, "case")
+			bindTy = typeCase(&tok, tok, guard, n)
+		} else {
+			tok = skip(tok, "case")
+			args := new(Node)
+			last := args
+			for i, val := range exprList(&tok, tok) {
+				cond := val
+				if tag != nil {
+					cond = newBinary(ND_EQ, tag, val, val.tok)
+				} else if addType(val); val.ty.kind != TY_BOOL {
+					errorTok(val.tok, "invalid case in switch (mismatched types %s and bool)",
+						typeString(val.ty))
+				}
+				if i == 0 {
+					n.cond = cond
+				} else {
+					n.cond = newBinary(ND_LOGOR, n.cond, cond, val.tok)
+				}
+				last.next = val
+				last = val
+			}
+			n.args = args.next
+			addType(n.cond)
+		}
+		tok = skip(tok, ":")
+
+		enterScope()
+		body := new(Node)
+		last := body
+		if bind != nil {
+			val := guard
+			if bindTy != nil {
+				val = newTypeAssert(guard, bindTy, bind)
+			}
+			vr := newLvar(getIdent(bind), inferType(val))
+			last.next = newUnary(ND_EXPR_STMT, newDecl(vr, bind), bind)
+			last.next.next = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(vr, bind), val, bind), bind)
+			last = last.next.next
+			addType(last)
+		}
+		for !equal(tok, "case") && !equal(tok, "default") && !equal(tok, "}") {
+			if equal(tok, "fallthrough") && guard != nil {
+				errorTok(tok, "cannot fallthrough in type switch")
+			}
+			if equal(tok, "fallthrough") {
+				fallthru = newNode(ND_GOTO, tok)
+				tok = skipSemicolon(tok.next)
+				if !equal(tok, "case") && !equal(tok, "default") && !equal(tok, "}") {
+					errorTok(fallthru.tok, "fallthrough statement out of place")
+				}
+				if equal(tok, "}") {
+					errorTok(fallthru.tok, "cannot fallthrough final case in switch")
+				}
+				last.next = fallthru
+				last = fallthru
+				continue
+			}
+			if item := blockItem(&tok, tok); item != nil {
+				last.next = item
+				last = item
+			}
+		}
+		leaveScope()
+		n.body = body.next
+
+		cur.next = n
+		cur = n
+	}
+	node.body = head.next
+	if tag != nil && guard == nil {
+		checkDuplicateCases(node)
+	}
+
+	brkLabel = brk
+	leaveScope()
+	*rest = tok.next
+	return node
+}
+
+// Returns true if `tok` starts the guard of a type switch.
+
+func isTypeGuard(tok *Token) bool {
+	depth := 0
+	for ; tok.kind != TK_EOF; tok = tok.next {
+		if depth == 0 && (equal(tok, "{") || equal(tok, ";")) {
+			return false
+		}
+		if equal(tok, "(") || equal(tok, "[") {
+			depth++
+		} else if equal(tok, ")") || equal(tok, "]") {
+			depth--
+		}
+		if equal(tok, ".") && equal(tok.next, "(") && equal(tok.next.next, "type") {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the types of a case of a type switch on `guard` and sets
+// the condition of the case `n`. Returns the type if there is only
+// one.
+
+func typeCase(rest **Token, tok *Token, guard *Node, n *Node) *Type {
+	var ty *Type
+	count := 0
+	for {
+		var cond *Node
+		start := tok
+		if equal(tok, "nil") {
+			null := newNum(0, tok)
+			null.ty = tyNil
+			cond = newBinary(ND_EQ, guard, null, tok)
+			ty = nil
+			tok = tok.next
+		} else {
+			ty = declarator(&tok, tok)
+			check := newTypeAssert(guard, ty, start)
+			commaOk(check)
+			cond = newUnary(ND_MEMBER, check, start)
+			cond.member = check.ty.members.next
+		}
+
+		if count == 0 {
+			n.cond = cond
+		} else {
+			n.cond = newBinary(ND_LOGOR, n.cond, cond, start)
+		}
+		count++
+		if !equal(tok, ",") {
+			break
+		}
+		tok = tok.next
+	}
+	addType(n.cond)
+
+	*rest = tok
+	if count > 1 {
+		return nil
+	}
+	return ty
+}
+
+// Reports an error if the same constant appears in two cases.
+
+func checkDuplicateCases(node *Node) {
+	for n := node.body; n != nil; n = n.next {
+		for x := n.args; x != nil; x = x.next {
+			val, ok := constValue(x)
+			if !ok {
+				continue
+			}
+			for m := node.body; m != nil; m = m.next {
+				for y := m.args; y != nil && y != x; y = y.next {
+					if v, ok := constValue(y); ok && v == val {
+						errorTok(x.tok, "duplicate case %d in switch", val)
+					}
+				}
+				if m == n {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Evaluates a constant integer expression. The second result is
+// false if `node` is not a constant.
+
+func constValue(node *Node) (int, bool) {
+	switch node.kind {
+	case ND_NUM:
+		return node.val, node.fval == nil
+	case ND_NEG, ND_BITNOT:
+		val, ok := constValue(node.lhs)
+		if node.kind == ND_NEG {
+			return -val, ok
+		}
+		return ^val, ok
+	case ND_ADD, ND_SUB, ND_MUL, ND_BITAND, ND_BITOR, ND_BITXOR:
+		if node.lhs.ty != nil && node.lhs.ty.base != nil {
+			return 0, false
+		}
+		lhs, ok1 := constValue(node.lhs)
+		rhs, ok2 := constValue(node.rhs)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch node.kind {
+		case ND_ADD:
+			return lhs + rhs, true
+		case ND_SUB:
+			return lhs - rhs, true
+		case ND_MUL:
+			return lhs * rhs, true
+		case ND_BITAND:
+			return lhs & rhs, true
+		case ND_BITOR:
+			return lhs | rhs, true
+		}
+		return lhs ^ rhs, true
+	}
+	return 0, false
+}
+
+// const-int = expr
+//
+// Evaluates a constant expression used as an array length or an index
+// in a composite literal, which must be a non-negative int.
+
+func constInt(rest **Token, tok *Token) int {
+	node := expr(rest, tok)
+	addType(node)
+	if !isConst(node) || !isInteger(node.ty) {
+		errorTok(tok, "expected an integer constant")
+	}
+	v := constOf(node)
+	if v.Sign() < 0 || !representable(v, tyInt) {
+		errorTok(tok, "constant %s must be a non-negative int", v)
+	}
+	return node.val
+}
+
+// Returns the label named by `tok` for "break" or "continue".
+
+func findLabel(tok *Token) *Node {
+	for l := labels; l != nil; l = l.gotoNext {
+		if l.label == getIdent(tok) {
+			return l
+		}
+	}
+	errorTok(tok, "label %s not defined", getIdent(tok))
+	return nil
+}
+
+// Returns the variables visible in the current scope and each of
+// the enclosing scopes, innermost first.
+
+func visibleVars() []*VarScope {
+	var vrs []*VarScope
+	for sc := scope; sc != nil; sc = sc.next {
+		vrs = append(vrs, sc.vrs)
+	}
+	return vrs
+}
+
+// Resolves goto labels. A goto must not jump into a block or over a
+// variable declaration in the block of the label.
+
+func resolveGotoLabels() {
+	for x := gotos; x != nil; x = x.gotoNext {
+		var y *Node
+		for y = labels; y != nil; y = y.gotoNext {
+			if x.label == y.label {
+				break
+			}
+		}
+		if y == nil {
+			errorTok(x.tok.next, "label %s not defined", x.label)
+		}
+		x.uniqueLabel = y.uniqueLabel
+
+		i := 0
+		sc := x.scope
+		for sc != nil && sc != y.scope {
+			sc = sc.next
+			i++
+		}
+		if sc == nil {
+			errorTok(x.tok.next, "goto %s jumps into block", x.label)
+		}
+		if y.tok.pos < x.tok.pos {
+			continue
+		}
+		var decl *Obj
+		for v := y.vrs[0]; v != x.vrs[i]; v = v.next {
+			if v.vrObj != nil {
+				decl = v.vrObj
+			}
+		}
+		if decl != nil {
+			errorTok(x.tok.next, "goto %s jumps over declaration of %s", x.label, decl.name)
+		}
+	}
+	gotos = nil
+	labels = nil
+}
+
+// A function returning a single unnamed scalar returns the value of
+// the expression. Otherwise the values are assigned to the result
+// variables, which the epilogue returns to the caller.
+
+func returnStmt(rest **Token, tok *Token) *Node {
+	node := newNode(ND_RETURN, tok)
+	tok = tok.next
+	fn := currentFn
+
+	if equal(tok, ";") || equal(tok, "}") {
+		if fn.ty.returnTy.kind != TY_VOID && (fn.results == nil || fn.results.name == "") {
+			errorTok(node.tok, "not enough return values")
+		}
+		consume(rest, tok, ";")
+		return node
+	}
+
+	values := exprList(&tok, tok)
+	if fn.ty.returnTy.kind == TY_VOID {
+		errorTok(values[0].tok, "too many return values")
+	}
+	if fn.results == nil {
+		if len(values) != 1 {
+			errorTok(values[1].tok, "too many return values")
+		}
+		checkAssign(values[0], fn.ty.returnTy)
+		node.lhs = convertValue(values[0], fn.ty.returnTy)
+	} else {
+		var lhs []*Node
+		vr := fn.results
+		for t := funcResults(fn.ty); t != nil; t = t.next {
+			lhs = append(lhs, newVarNode(vr, node.tok))
+			vr = vr.next
+		}
+		if len(values) < len(lhs) && (len(values) > 1 || len(lhs) == 1) {
+			errorTok(node.tok, "not enough return values")
+		}
+		if len(values) > len(lhs) {
+			errorTok(values[len(lhs)].tok, "too many return values")
+		}
+		unpack, values := unpackTuple(values, len(lhs), node.tok)
+		body := tupleAssign(lhs, values, node.tok)
+		if unpack != nil {
+			unpack.next = body
+			body = unpack
+		}
+		node.body = body
+	}
+	consume(rest, tok, ";")
+	return node
+}
+
+// defer-stmt = "defer" postfix ";"
+//
+// The deferred call is wrapped in a function without parameters,
+// which calls the function value with the arguments evaluated by the
+// "defer" statement. The wrapper is registered with the runtime and
+// called when the function returns or panics.
+
+func deferStmt(rest **Token, tok *Token) *Node {
+	node := newNode(ND_DEFER, tok)
+	call := postfix(&tok, tok.next)
+	*rest = skipSemicolon(tok)
+
+	head := new(Node)
+	cur := head
+	for call.kind == ND_COMMA {
+		cur.next = newUnary(ND_EXPR_STMT, call.lhs, node.tok)
+		cur = cur.next
+		call = call.rhs
+	}
+	if call.kind != ND_FUNCALL {
+		errorTok(node.tok.next, "expression in defer must be function call")
+	}
+
+	currentFn.numFuncLits++
+	fn := newGvar(fmt.Sprintf("%s.deferwrap%d", currentFn.name, currentFn.numFuncLits), funcType(tyVoid))
+	fn.isFunction = true
+	fn.isDefinition = true
+	fn.parent = currentFn
+	currentFn.hasDefer = true
+
+	if call.lhs != nil {
+		cur.next, call.lhs = deferValue(call.lhs, fn)
+		cur = cur.next
+	}
+	args := new(Node)
+	last := args
+	for arg := call.args; arg != nil; {
+		next := arg.next
+		cur.next, last.next = deferValue(arg, fn)
+		cur = cur.next
+		last = last.next
+		arg = next
+	}
+	call.args = args.next
+
+	if call.retBuffer != nil {
+		call.retBuffer = &Obj{ty: call.retBuffer.ty, isLocal: true, fn: fn}
+		fn.locals = call.retBuffer
+	}
+	for _, vr := range fn.captures {
+		vr.next = fn.locals
+		fn.locals = vr
+	}
+	fn.body = newUnary(ND_EXPR_STMT, call, node.tok)
+	addType(fn.body)
+
+	node.body = head.next
+	node.lhs = newNode(ND_CLOSURE, node.tok)
+	node.lhs.funcname = fn.name
+	node.lhs.vr = fn
+	node.lhs.ty = fn.ty
+	return node
+}
+
+// Returns a statement storing the value of `e` in a new variable when
+// a "defer" statement is executed, and the variable as seen from the
+// deferred function `fn`.
+
+func deferValue(e *Node, fn *Obj) (*Node, *Node) {
+	addType(e)
+	tmp := newLvar("", e.ty)
+	init := newBinary(ND_COMMA, newDecl(tmp, e.tok),
+		newBinary(ND_ASSIGN, newVarNode(tmp, e.tok), e, e.tok), e.tok)
+	return newUnary(ND_EXPR_STMT, init, e.tok), newVarNode(captureVar(tmp, fn), e.tok)
+}
+
+// Returns true if the header of a "for" statement at `tok` is a
+// range clause.
+
+func isRangeClause(tok *Token) bool {
+	for ; !equal(tok, "{") && !equal(tok, ";") && tok.kind != TK_EOF; tok = tok.next {
+		if equal(tok, "range") {
+			return true
+		}
+	}
+	return false
+}
+
+// range-clause = (ident ("," ident)? ":=" | operand ("," operand)? "=")? "range" expr
+//
+// Sets up `node` to iterate over the range expression, and returns
+// the statements which assign the iteration values at the start of
+// each iteration. Variables declared with ":=" are created in the
+// scope of the "for" statement.
+
+func rangeClause(rest **Token, tok *Token, node *Node) *Node {
+	var names []*Token
+	var lhs []*Node
+	define := false
+	if isShortVarDecl(tok) {
+		define = true
+		for !equal(tok, ":=") {
+			if len(names) > 0 {
+				tok = skip(tok, ",")
+			}
+			names = append(names, tok)
+			tok = tok.next
+		}
+		tok = tok.next
+	} else if !equal(tok, "range") {
+		for !equal(tok, "=") {
+			if len(lhs) > 0 {
+				tok = skip(tok, ",")
+			}
+			if isBlank(tok) {
+				lhs = append(lhs, nil)
+				tok = tok.next
+			} else {
+				lhs = append(lhs, logOr(&tok, tok))
+			}
+		}
+		tok = tok.next
+	}
+	if len(names) > 2 || len(lhs) > 2 {
+		errorTok(tok, "range clause permits at most two iteration variables")
+	}
+
+	start := skip(tok, "range")
+	x := expr(&tok, start)
+	*rest = tok
+	addType(x)
+	if isUntyped(x.ty) {
+		convertConst(x, tyInt)
+	}
+
+	var pre *Node
+	var values []*Node
+	ty := x.ty
+	if ty.kind == TY_PTR && ty.base.kind == TY_ARRAY {
+		ty = ty.base
+	}
+	switch {
+	case ty.kind == TY_MAP:
+		// for tmp = x, it = runtime_mapnext(tmp, 0); it >= 0;
+		//     it = runtime_mapnext(tmp, it+1) {
+		//   key, val = *runtime_mapkey(tmp, it), *runtime_mapval(tmp, it)
+		m := newLvar("", x.ty)
+		it := newLvar("", tyInt)
+		next := func(i *Node) *Node {
+			call := runtimeCall("runtime_mapnext", nil, start, newVarNode(m, start), i)
+			return newBinary(ND_ASSIGN, newVarNode(it, start), call, start)
+		}
+		node.init = newUnary(ND_EXPR_STMT,
+			newBinary(ND_COMMA, newBinary(ND_ASSIGN, newVarNode(m, start), x, start),
+				next(newNum(0, start)), start), start)
+		node.cond = newBinary(ND_LE, newNum(0, start), newVarNode(it, start), start)
+		node.inc = newUnary(ND_EXPR_STMT,
+			next(newBinary(ND_ADD, newVarNode(it, start), newNum(1, start), start)), start)
+		key := runtimeCall("runtime_mapkey", pointerTo(x.ty.key), start, newVarNode(m, start), newVarNode(it, start))
+		val := runtimeCall("runtime_mapval", pointerTo(x.ty.base), start, newVarNode(m, start), newVarNode(it, start))
+		values = []*Node{newUnary(ND_DEREF, key, start), newUnary(ND_DEREF, val, start)}
+	case ty.kind == TY_ARRAY || ty.kind == TY_SLICE:
+		// for tmp, i = x, 0; i < len(tmp); i++ {
+		//   key, val = i, tmp[i]
+		// }
+		//
+		// An array is copied to `tmp`, so that the values do not
+		// change even if the array is assigned to in the loop.
+		tmp := newLvar("", x.ty)
+		i := newLvar("", tyInt)
+		var length, elems *Node
+		switch {
+		case ty.kind == TY_SLICE:
+			length = sliceMember(newVarNode(tmp, start), 1, start)
+			elems = sliceMember(newVarNode(tmp, start), 0, start)
+		case x.ty.kind == TY_PTR:
+			length = newNum(ty.arrayLen, start)
+			elems = newUnary(ND_DEREF, newVarNode(tmp, start), start)
+		default:
+			length = newNum(ty.arrayLen, start)
+			elems = newVarNode(tmp, start)
+		}
+		node.init = newUnary(ND_EXPR_STMT,
+			newBinary(ND_COMMA, newBinary(ND_ASSIGN, newVarNode(tmp, start), x, start),
+				newBinary(ND_ASSIGN, newVarNode(i, start), newNum(0, start), start), start), start)
+		node.cond = newBinary(ND_LT, newVarNode(i, start), length, start)
+		node.inc = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(i, start),
+			newBinary(ND_ADD, newVarNode(i, start), newNum(1, start), start), start), start)
+		elem := newUnary(ND_DEREF, newPtrAdd(elems, newVarNode(i, start), start), start)
+		values = []*Node{newVarNode(i, start), elem}
+	case ty.kind == TY_STRING:
+		// for tmp, i = x, 0; i < len(tmp); i = next {
+		//   r, next = runtime_decoderune(tmp, i)
+		//   key, val = i, r
+		// }
+		tmp := newLvar("", x.ty)
+		i := newLvar("", tyInt)
+		call := runtimeCall("runtime_decoderune", nil, start, newVarNode(tmp, start), newVarNode(i, start))
+		var decoded []*Node
+		pre, decoded = unpackTuple([]*Node{call}, 2, start)
+		node.init = newUnary(ND_EXPR_STMT,
+			newBinary(ND_COMMA, newBinary(ND_ASSIGN, newVarNode(tmp, start), x, start),
+				newBinary(ND_ASSIGN, newVarNode(i, start), newNum(0, start), start), start), start)
+		node.cond = newBinary(ND_LT, newVarNode(i, start), sliceMember(newVarNode(tmp, start), 1, start), start)
+		node.inc = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(i, start), decoded[1], start), start)
+		values = []*Node{newVarNode(i, start), decoded[0]}
+	case ty.kind == TY_CHAN:
+		// for tmp = x; runtime_chanrecv(tmp, &v); {
+		//   key = v
+		// }
+		if len(names) > 1 || len(lhs) > 1 {
+			errorTok(x.tok, "range over %s permits only one iteration variable", typeString(x.ty))
+		}
+		if ty.dir == CHAN_SEND {
+			errorTok(x.tok, "invalid operation: cannot receive from send-only channel %s", typeString(x.ty))
+		}
+		c := newLvar("", x.ty)
+		v := newLvar("", ty.base)
+		node.init = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(c, start), x, start), start)
+		node.cond = runtimeCall("runtime_chanrecv", nil, start, newVarNode(c, start),
+			newUnary(ND_ADDR, newVarNode(v, start), start))
+		values = []*Node{newVarNode(v, start)}
+	case isInteger(ty):
+		// for n, i = x, 0; i < n; i++ {
+		//   key = i
+		// }
+		if len(names) > 1 || len(lhs) > 1 {
+			errorTok(x.tok, "range over %s permits only one iteration variable", typeString(x.ty))
+		}
+		n := newLvar("", x.ty)
+		i := newLvar("", x.ty)
+		node.init = newUnary(ND_EXPR_STMT,
+			newBinary(ND_COMMA, newBinary(ND_ASSIGN, newVarNode(n, start), x, start),
+				newBinary(ND_ASSIGN, newVarNode(i, start), newNum(0, start), start), start), start)
+		node.cond = newBinary(ND_LT, newVarNode(i, start), newVarNode(n, start), start)
+		node.inc = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, newVarNode(i, start),
+			newBinary(ND_ADD, newVarNode(i, start), newNum(1, start), start), start), start)
+		values = []*Node{newVarNode(i, start)}
+	default:
+		errorTok(x.tok, "cannot range over %s", typeString(x.ty))
+	}
+
+	// Variables declared with ":=" are allocated at the start of each
+	// iteration, so that each iteration has its own variables.
+	head := new(Node)
+	head.next = pre
+	cur := head
+	for cur.next != nil {
+		cur = cur.next
+	}
+	for i, val := range values {
+		var target *Node
+		if define && i < len(names) && !isBlank(names[i]) {
+			addType(val)
+			vr := newLvar(getIdent(names[i]), val.ty)
+			cur.next = newUnary(ND_EXPR_STMT, newDecl(vr, names[i]), names[i])
+			cur = cur.next
+			target = newVarNode(vr, names[i])
+		} else if !define && i < len(lhs) {
+			target = lhs[i]
+		}
+		if target != nil {
+			cur.next = newUnary(ND_EXPR_STMT, newBinary(ND_ASSIGN, target, val, start), start)
+			cur = cur.next
+		}
+	}
+	return head.next
+}
+
+// Returns the expression of a simple statement used as a condition.
+
+func condition(node *Node) *Node {
+	if node.kind != ND_EXPR_STMT {
+		errorTok(node.tok, "expected a condition")
+	}
+	return node.lhs
+}
+
+// Returns `node` after checking that it is a boolean, which the
+// condition of the statement `stmt` must be.
+
+func boolCond(node *Node, stmt string) *Node {
+	addType(node)
+	if node.ty.kind != TY_BOOL {
+		errorTok(node.tok, "non-boolean condition in %s statement", stmt)
+	}
+	return node
+}
+
+// compound-stmt = (type-decl | declaration | stmt)* "}"
+
+func componentStmt(rest **Token, tok *Token) *Node {
+	node := newNode(ND_BLOCK, tok)
+
+	head := new(Node)
+	cur := head
+	enterScope()
+	for !equal(tok, "}") {
+		if tok.kind == TK_EOF {
+			errorTok(tok, "expected }")
+		}
+		start := tok
+		var item *Node
+		if !tryParse(func() { item = blockItem(&tok, tok) }) {
+			tok = skipStmt(start)
+			continue
+		}
+		if item != nil {
+			cur.next = item
+			cur = cur.next
+		}
+	}
+	leaveScope()
+	node.body = head.next
+	*rest = tok.next
+	return node
+}
+
+// The state of the parser, which is restored when it recovers from an
+// error.
+
+type parserState struct {
+	scope     *Scope
+	vrs       *VarScope
+	currentFn *Obj
+	locals    *Obj
+	gotos     *Node
+	labels    *Node
+	brkLabel  string
+	contLabel string
+	constIota int
+	stmtLabel *Node
+}
+
+// A variable or a constant declared in `scope`, whose type is `ty` or
+// not known yet.
+
+type pendingDecl struct {
+	name  *Token
+	ty    *Type
+	scope *Scope
+}
+
+// Records that `name` is being declared. If the declaration has an
+// error, tryParse declares it anyway, so that its uses are not
+// reported as undefined.
+
+func addPending(name *Token) *pendingDecl {
+	pd := &pendingDecl{name: name, scope: scope}
+	pendingDecls = append(pendingDecls, pd)
+	return pd
+}
+
+// Calls `f` to parse a statement or a declaration, and returns false if
+// it reports an error. Then the state of the parser is restored, so
+// that the caller can skip to the next statement or declaration, and
+// the variables and constants declared by the statement are declared
+// with their declared types or tyInvalid.
+
+func tryParse(f func()) (ok bool) {
+	state := parserState{scope, scope.vrs, currentFn, locals, gotos, labels,
+		brkLabel, contLabel, constIota, stmtLabel}
+	npending := len(pendingDecls)
+	defer func() {
+		r := recover()
+		pending := pendingDecls[npending:]
+		pendingDecls = pendingDecls[:npending]
+		if r == nil {
+			return
+		}
+		if !recoverable(r) {
+			panic(r)
+		}
+		scope = state.scope
+		scope.vrs = state.vrs
+		currentFn, locals, gotos, labels = state.currentFn, state.locals, state.gotos, state.labels
+		brkLabel, contLabel = state.brkLabel, state.contLabel
+		constIota, stmtLabel = state.constIota, state.stmtLabel
+		ok = false
+
+		for _, pd := range pending {
+			// Names declared in an inner scope, such as in the
+			// header of a for statement, are not visible anyway.
+			if pd.scope != scope || isBlank(pd.name) {
+				continue
+			}
+			ty := pd.ty
+			if ty == nil {
+				ty = tyInvalid
+			}
+			// The variable is only used to check the rest of
+			// the program, which is not compiled.
+			newVar(getIdent(pd.name), ty)
+		}
+	}()
+	f()
+	return true
+}
+
+// Returns the token after the statement at `tok`, which ends with a
+// ";" outside braces or before an unmatched "}". A ";" inside
+// parentheses ends it too, since it is inserted at the end of a line
+// with an unclosed parenthesis. The semicolons separating the clauses
+// in the header of a "for", "if" or "switch" statement do not end it.
+
+func skipStmt(tok *Token) *Token {
+	start := tok
+	depth, braces := 0, 0
+	clauses := 0
+	if equal(tok, "for") {
+		clauses = 2
+	} else if equal(tok, "if") || equal(tok, "switch") {
+		clauses = 1
+	}
+	for tok.kind != TK_EOF {
+		if equal(tok, "(") || equal(tok, "[") || equal(tok, "{") {
+			depth++
+			if equal(tok, "{") {
+				braces++
+				clauses = 0
+			}
+		} else if braces == 0 && clauses > 0 && equal(tok, ";") {
+			clauses--
+			tok = tok.next
+			continue
+		} else if equal(tok, ")") || equal(tok, "]") || equal(tok, "}") {
+			if depth == 0 {
+				break
+			}
+			depth--
+			if equal(tok, "}") && braces > 0 {
+				braces--
+			}
+		} else if braces == 0 && equal(tok, ";") {
+			return tok.next
+		}
+		tok = tok.next
+	}
+	if tok == start && tok.kind != TK_EOF {
+		return tok.next
+	}
+	return tok
+}
+
+// block-item = type-decl | declaration | stmt
+//
+// Returns nil for a type declaration.
+
+func blockItem(rest **Token, tok *Token) *Node {
+	if equal(tok, "type") {
+		for _, ty := range typeDecl(rest, tok) {
+			resolveType(ty)
+		}
+		return nil
+	}
+	if equal(tok, "const") {
+		constDecl(rest, tok)
+		return nil
+	}
+	var node *Node
+	if equal(tok, "var") {
+		node = declaration(rest, tok)
+	} else {
+		node = stmt(rest, tok)
+	}
+	addType(node)
+	return node
+}
+
+// expr-stmt = simple-stmt? ";"
+
+func exprStmt(rest **Token, tok *Token) *Node {
+	if equal(tok, ";") {
+		*rest = tok.next
+		return newNode(ND_BLOCK, tok)
+	}
+
+	node := simpleStmt(&tok, tok)
+	*rest = skipSemicolon(tok)
+	return node
+}
+
+// simple-stmt = short-var-decl
+//             | operand ("," operand)* "=" expr-list
+//             | expr assign-op expr
+//             | expr ("++" | "--")
+//             | expr "<-" expr
+//             | expr
+// operand     = "_" | expr
+// assign-op   = "+=" | "-=" | "*=" | "/=" | "%=" | "&=" | "|=" | "^="
+//             | "<<=" | ">>=" | "&^="
+
+func simpleStmt(rest **Token, tok *Token) *Node {
+	if isShortVarDecl(tok) {
+		return shortVarDecl(rest, tok)
+	}
+
+	start := tok
+	var lhs []*Node
+	if isBlank(tok) {
+		lhs = append(lhs, nil)
+		tok = tok.next
+	} else {
+		lhs = append(lhs, expr(&tok, tok))
+
+		if equal(tok, "++") || equal(tok, "--") {
+			op := "+="
+			if equal(tok, "--") {
+				op = "-="
+			}
+			node := compoundAssign(lhs[0], newNum(1, tok), op, tok)
+			*rest = tok.next
+			return newUnary(ND_EXPR_STMT, node, start)
+		}
+
+		if equal(tok, "<-") {
+			op := tok
+			node := newSend(lhs[0], expr(&tok, tok.next), op)
+			*rest = tok
+			return newUnary(ND_EXPR_STMT, node, start)
+		}
+
+		if isAssignOp(tok) {
+			op := tok
+			node := compoundAssign(lhs[0], expr(&tok, tok.next), getPunct(op), op)
+			*rest = tok
+			return newUnary(ND_EXPR_STMT, node, start)
+		}
+
+		if !equal(tok, ",") {
+			node := newUnary(ND_EXPR_STMT, lhs[0], start)
+			*rest = tok
+			return node
+		}
+	}
+
+	for equal(tok, ",") {
+		tok = tok.next
+		if isBlank(tok) {
+			lhs = append(lhs, nil)
+			tok = tok.next
+		} else {
+			lhs = append(lhs, logOr(&tok, tok))
+		}
+	}
+
+	op := tok
+	tok = skip(tok, "=")
+	unpack, values := unpackTuple(exprList(&tok, tok), len(lhs), op)
+
+	node := newNode(ND_BLOCK, start)
+	if unpack != nil {
+		unpack.next = tupleAssign(lhs, values, op)
+		node.body = unpack
+	} else {
+		node.body = tupleAssign(lhs, values, op)
+	}
+	*rest = tok
+	return node
+}
+
+func isAssignOp(tok *Token) bool {
+	for _, op := range []string{"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^="} {
+		if equal(tok, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// Converts `A op= B` to `tmp = &A, *tmp = *tmp op B`, so that the
+// operands of A are evaluated only once. `A++` and `A--` are `A += 1`
+// and `A -= 1`.
+
+func compoundAssign(lhs *Node, rhs *Node, op string, tok *Token) *Node {
+	addType(lhs)
+	if isStringIndex(lhs) {
+		errorTok(lhs.tok, "cannot assign to string element")
+	}
+	checkMapElement(lhs)
+
+	target := lhs
+	var init *Node
+	if lhs.kind != ND_VAR {
+		vr := newLvar("", pointerTo(lhs.ty))
+		init = newBinary(ND_ASSIGN, newVarNode(vr, tok), newUnary(ND_ADDR, lhs, tok), tok)
+		target = newUnary(ND_DEREF, newVarNode(vr, tok), tok)
+	}
+
+	var val *Node
+	switch op {
+	case "+=":
+		val = newAdd(target, rhs, tok)
+	case "-=":
+		val = newSub(target, rhs, tok)
+	case "*=":
+		val = newBinary(ND_MUL, target, rhs, tok)
+	case "/=":
+		val = newBinary(ND_DIV, target, rhs, tok)
+	case "%=":
+		val = newBinary(ND_MOD, target, rhs, tok)
+	case "&=":
+		val = newBinary(ND_BITAND, target, rhs, tok)
+	case "|=":
+		val = newBinary(ND_BITOR, target, rhs, tok)
+	case "^=":
+		val = newBinary(ND_BITXOR, target, rhs, tok)
+	case "<<=":
+		val = newBinary(ND_SHL, target, rhs, tok)
+	case ">>=":
+		val = newBinary(ND_SHR, target, rhs, tok)
+	case "&^=":
+		val = newBinary(ND_ANDNOT, target, rhs, tok)
+	}
+
+	node := newBinary(ND_ASSIGN, target, val, tok)
+	if init != nil {
+		node = newBinary(ND_COMMA, init, node, tok)
+	}
+	return node
+}
+
+// expr = assign
+
+func expr(rest **Token, tok *Token) *Node {
+	return assign(rest, tok)
+}
+
+// assign = log-or ("=" assign)?
+
+func assign(rest **Token, tok *Token) *Node {
+	node := logOr(&tok, tok)
+	if equal(tok, "=") {
+		rhs := assign(rest, tok.next)
+		addType(node)
+		checkAssign(rhs, node.ty)
+		return newBinary(ND_ASSIGN, node, rhs, tok)
+	}
+	*rest = tok
+	return node
+}
+
+// log-or = log-and ("||" log-and)*
+
+func logOr(rest **Token, tok *Token) *Node {
+	node := logAnd(&tok, tok)
+	for equal(tok, "||") {
+		start := tok
+		node = newBinary(ND_LOGOR, node, logAnd(&tok, tok.next), start)
+	}
+	*rest = tok
+	return node
+}
+
+// log-and = relational ("&&" relational)*
+
+func logAnd(rest **Token, tok *Token) *Node {
+	node := relational(&tok, tok)
+	for equal(tok, "&&") {
+		start := tok
+		node = newBinary(ND_LOGAND, node, relational(&tok, tok.next), start)
+	}
+	*rest = tok
+	return node
+}
+
+// relational = add ("==" add | "!=" add | "<" add | "<=" add | ">" add | ">=" add)*
+
+func relational(rest **Token, tok *Token) *Node {
+	node := add(&tok, tok)
+
+	for {
+		start := tok
+		if equal(tok, "==") {
+			node = newBinary(ND_EQ, node, add(&tok, tok.next), start)
+			continue
+		}
+		if equal(tok, "!=") {
+			node = newBinary(ND_NE, node, add(&tok, tok.next), start)
+			continue
+		}
+		if equal(tok, "<") {
+			node = newBinary(ND_LT, node, add(&tok, tok.next), start)
+			continue
+		}
+		if equal(tok, "<=") {
+			node = newBinary(ND_LE, node, add(&tok, tok.next), start)
+			continue
+		}
+		if equal(tok, ">") {
+			node = newBinary(ND_LT, add(&tok, tok.next), node, start)
+			continue
+		}
+		if equal(tok, ">=") {
+			node = newBinary(ND_LE, add(&tok, tok.next), node, start)
+			continue
+		}
+		*rest = tok
+		return node
+	}
+}
+
+// In Go, '+' and '-' operators are not overloaded to perform the pointer arithmetic
+
+func newAdd(lhs *Node, rhs *Node, tok *Token) *Node {
+	addType(lhs)
+	addType(rhs)
+
+	// string + string
+	if lhs.ty.kind == TY_STRING || rhs.ty.kind == TY_STRING {
+		if !identical(lhs.ty, rhs.ty) {
+			errorTok(tok, "invalid operation: mismatched types %s and %s",
+				typeString(lhs.ty), typeString(rhs.ty))
+		}
+		if isStringLiteral(lhs) && isStringLiteral(rhs) {
+			return newStringConst(lhs.tok.str+rhs.tok.str, lhs.ty, tok)
+		}
+		return runtimeCall("runtime_concatstrings", lhs.ty, tok, lhs, rhs)
+	}
+
+	// num + num
+	if isNumeric(lhs.ty) && isNumeric(rhs.ty) {
+		return newBinary(ND_ADD, lhs, rhs, tok)
+	}
+
+	if lhs.ty.base != nil && rhs.ty.base != nil ||
+		lhs.ty.kind == TY_SLICE || rhs.ty.kind == TY_SLICE {
+		errorTok(tok, "invalid operands")
+	}
+
+	if lhs.ty.base == nil && rhs.ty.base == nil {
+		ty := lhs.ty
+		if isNumeric(ty) {
+			ty = rhs.ty
+		}
+		errorTok(tok, "invalid operation: operator + not defined on %s", typeString(ty))
+	}
+
+	// Canonicalize `num + ptr` to `ptr + num`.
+	if lhs.ty.base == nil && rhs.ty.base != nil {
+		tmp := lhs
+		lhs = rhs
+		rhs = tmp
+	}
+
+	// Only the runtime adds to pointers.
+	if !inRuntime(tok) {
+		errorTok(tok, "invalid operation: operator + not defined on %s", typeString(lhs.ty))
+	}
+	return newPtrAdd(lhs, rhs, tok)
+}
+
+// Returns the address of the element `idx` of the array or the array
+// pointed to by `ptr`. Unlike an addition in the program, the operands
+// are not type-checked.
+
+func newPtrAdd(ptr *Node, idx *Node, tok *Token) *Node {
+	addType(ptr)
+	idx = newBinary(ND_MUL, idx, newNum(ptr.ty.base.size, tok), tok)
+	addType(idx)
+	node := newBinary(ND_ADD, ptr, idx, tok)
+	node.ty = ptr.ty
+	return node
+}
+
+func newSub(lhs *Node, rhs *Node, tok *Token) *Node {
+	addType(lhs)
+	addType(rhs)
+
+	// num + num
+	if isNumeric(lhs.ty) && isNumeric(rhs.ty) {
+		return newBinary(ND_SUB, lhs, rhs, tok)
+	}
+
+	if lhs.ty.base != nil || rhs.ty.base != nil {
+		errorTok(tok, "invalid operands: pointer arithmetic is not supported in Go")
+	}
+
+	errorTok(tok, "invalid operands")
+	return nil
+}
+
+// add = mul ("+" mul | "-" mul | "|" mul | "^" mul)*
+
+func add(rest **Token, tok *Token) *Node {
+	node := mul(&tok, tok)
+
+	for {
+		start := tok
+		if equal(tok, "+") {
+			node = newAdd(node, mul(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "-") {
+			node = newSub(node, mul(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "|") {
+			node = newBinary(ND_BITOR, node, mul(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "^") {
+			node = newBinary(ND_BITXOR, node, mul(&tok, tok.next), start)
+			continue
+		}
+
+		*rest = tok
+		return node
+	}
+}
+
+// mul = unary ("*" unary | "/" unary | "%" unary | "<<" unary | ">>" unary | "&" unary | "&^" unary)*
+
+func mul(rest **Token, tok *Token) *Node {
+	node := unary(&tok, tok)
+
+	for {
+		start := tok
+		if equal(tok, "*") {
+			node = newBinary(ND_MUL, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "/") {
+			node = newBinary(ND_DIV, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "%") {
+			node = newBinary(ND_MOD, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "<<") {
+			node = newBinary(ND_SHL, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, ">>") {
+			node = newBinary(ND_SHR, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "&") {
+			node = newBinary(ND_BITAND, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		if equal(tok, "&^") {
+			node = newBinary(ND_ANDNOT, node, unary(&tok, tok.next), start)
+			continue
+		}
+
+		*rest = tok
+		return node
+	}
+}
+
+// unary = ("+" | "-" | "!" | "^" | "*" | "&" | "<-") unary
+//       | postfix
+
+func unary(rest **Token, tok *Token) *Node {
+	if equal(tok, "+") {
+		return unary(rest, tok.next)
+	}
+	if equal(tok, "-") {
+		return newUnary(ND_NEG, unary(rest, tok.next), tok)
+	}
+	if equal(tok, "!") {
+		return newUnary(ND_NOT, unary(rest, tok.next), tok)
+	}
+	if equal(tok, "^") {
+		return newUnary(ND_BITNOT, unary(rest, tok.next), tok)
+	}
+	if equal(tok, "&") {
+		node := unary(rest, tok.next)
+		if isMapIndex(node) || inMapElement(node) {
+			errorTok(tok, "cannot take address of map index expression")
+		}
+		if isStringIndex(node) {
+			errorTok(tok, "cannot take address of string element")
+		}
+		if node.kind == ND_COMPLIT {
+			// Each evaluation of &T{...} creates a distinct variable.
+			vr := newLvar("", node.ty)
+			vr.isBoxed = true
+			init := initComplit(newVarNode(vr, tok), node)
+			node = newBinary(ND_COMMA, newDecl(vr, tok), init, tok)
+		} else if vr := addressedVar(node); vr != nil && vr.isLocal {
+			vr.isBoxed = true
+		}
+		return newUnary(ND_ADDR, node, tok)
+	}
+	if equal(tok, "*") {
+		return newUnary(ND_DEREF, unary(rest, tok.next), tok)
+	}
+	if equal(tok, "<-") && !equal(tok.next, "chan") {
+		return newRecv(unary(rest, tok.next), tok)
+	}
+
+	return postfix(rest, tok)
+}
+
+// Returns the variable containing the object designated by `node`,
+// or nil if the object does not belong to a variable.
+
+func addressedVar(node *Node) *Obj {
+	addType(node)
+	switch node.kind {
+	case ND_VAR:
+		return node.vr
+	case ND_MEMBER:
+		return addressedVar(node.lhs)
+	case ND_DEREF:
+		if node.lhs.kind == ND_ADD && node.lhs.lhs.ty.kind == TY_ARRAY {
+			return addressedVar(node.lhs.lhs)
+		}
+	}
+	return nil
+}
+
+// postfix = primary ("[" expr "]" | "[" slice "]" | "." ident func-args? | "." "(" declarator ")" | func-args)*
+// slice   = expr? ":" expr?
+//         | expr? ":" expr ":" expr
+
+func postfix(rest **Token, tok *Token) *Node {
+	node := primary(&tok, tok)
+
+	for {
+		if equal(tok, "[") {
+			start := tok
+			var lo, hi, max *Node
+			tok = tok.next
+			if !equal(tok, ":") {
+				lo = expr(&tok, tok)
+			}
+			if !equal(tok, ":") {
+				tok = skip(tok, "]")
+				node = newIndex(node, lo, start)
+				continue
+			}
+
+			tok = tok.next
+			if !equal(tok, ":") && !equal(tok, "]") {
+				hi = expr(&tok, tok)
+			}
+			if equal(tok, ":") {
+				if hi == nil {
+					errorTok(tok, "middle index required in 3-index slice")
+				}
+				if equal(tok.next, "]") {
+					errorTok(tok.next, "final index required in 3-index slice")
+				}
+				max = expr(&tok, tok.next)
+			}
+			tok = skip(tok, "]")
+			node = sliceExpr(node, lo, hi, max, start)
+			continue
+		}
+
+		if equal(tok, ".") && equal(tok.next, "(") {
+			// The guard of a type switch
+			if equal(tok.next.next, "type") {
+				if !equal(tok.next.next.next.next, "{") {
+					errorTok(tok.next.next, "use of .(type) outside type switch")
+				}
+				*rest = tok
+				return node
+			}
+
+			addType(node)
+			if node.ty.kind != TY_INTERFACE {
+				errorTok(node.tok, "%s is not an interface", typeString(node.ty))
+			}
+			start := tok
+			ty := declarator(&tok, tok.next.next)
+			tok = skip(tok, ")")
+			node = newTypeAssert(node, ty, start)
+			continue
+		}
+
+		if equal(tok, ".") {
+			addType(node)
+			if findMethod(node.ty, getIdent(tok.next)) != nil {
+				if equal(tok.next.next, "(") {
+					node = methodCall(&tok, tok.next.next, node, tok.next)
+					continue
+				}
+				node = methodValue(node, tok.next)
+				tok = tok.next.next
+				continue
+			}
+			node = structRef(node, tok)
+			tok = tok.next.next
+			continue
+		}
+
+		if equal(tok, "(") {
+			addType(node)
+			if node.ty.kind != TY_FUNC {
+				errorTok(tok, "cannot call non-function %s", typeString(node.ty))
+			}
+			node = funcValueCall(&tok, tok, node)
+			continue
+		}
+
+		*rest = tok
+		return node
+	}
+}
+
+// Returns an index expression `x[idx]`. x[y] is short for *(x+y),
+// and s[i] for a slice or string s is short for
+// *(s.ptr + runtime_checkIndex(i, s.len)).
+
+func newIndex(node *Node, idx *Node, tok *Token) *Node {
+	addType(node)
+	if node.ty.kind == TY_PTR && node.ty.base.kind == TY_ARRAY {
+		node = newUnary(ND_DEREF, node, tok)
+		addType(node)
+	}
+	if node.ty.kind == TY_MAP {
+		return newMapIndex(node, idx, tok)
+	}
+	if node.ty.kind != TY_SLICE && node.ty.kind != TY_STRING {
+		return newUnary(ND_DEREF, newPtrAdd(node, idx, tok), tok)
+	}
+
+	init, s := evalOnce(node, tok)
+	check := runtimeCall("runtime_checkIndex", nil, tok, idx, sliceMember(s, 1, tok))
+	elem := newUnary(ND_DEREF, newPtrAdd(sliceMember(s, 0, tok), check, tok), tok)
+	if init == nil {
+		return elem
+	}
+	return newBinary(ND_COMMA, init, elem, tok)
+}
+
+// Returns a map index expression `m[key]`, which is lowered to
+//
+//   *(tmp = key, runtime_mapaccess1(m, &tmp, size))
+//
+// The call is replaced with runtime_mapassign if the expression is
+// assigned to.
+
+func newMapIndex(m *Node, key *Node, tok *Token) *Node {
+	addType(m)
+	init, addr := mapKey(m.ty, key, tok)
+	call := runtimeCall("runtime_mapaccess1", pointerTo(m.ty.base), tok, m, addr, newNum(m.ty.base.size, tok))
+	return newUnary(ND_DEREF, newBinary(ND_COMMA, init, call, tok), tok)
+}
+
+// Returns a call allocating a map of the type `ty`. The keys are hashed
+// and compared by the functions of the key type, so that strings are
+// compared by their contents.
+
+func makeMap(ty *Type, hint *Node, tok *Token) *Node {
+	// The key type may have been incomplete when the map type was
+	// parsed.
+	if !isComparable(ty.key) {
+		errorTok(tok, "invalid map key type %s", typeString(ty.key))
+	}
+	tf := getTypeFuncs(ty.key, tok)
+	return runtimeCall("runtime_makemap", ty, tok, hint, newNum(ty.key.size, tok),
+		funcValue(tf.hash, tok), funcValue(tf.equal, tok), newNum(ty.base.size, tok))
+}
+
+// Returns an expression storing a key of a map of the type `ty` to a
+// temporary variable and the address of the variable.
+
+func mapKey(ty *Type, key *Node, tok *Token) (*Node, *Node) {
+	tmp := newLvar("", ty.key)
+	init := newInit(newVarNode(tmp, tok), key, tok)
+	return init, newUnary(ND_ADDR, newVarNode(tmp, tok), tok)
+}
+
+func isMapIndex(node *Node) bool {
+	if node == nil || node.kind != ND_DEREF || node.lhs.kind != ND_COMMA {
+		return false
+	}
+	call := node.lhs.rhs
+	return call.kind == ND_FUNCALL &&
+		(call.funcname == "runtime_mapaccess1" || call.funcname == "runtime_mapassign")
+}
+
+// Returns true if `node` is an element of a string, which is
+// immutable.
+
+func isStringIndex(node *Node) bool {
+	if node.kind == ND_COMMA {
+		node = node.rhs
+	}
+	return node.kind == ND_DEREF && node.lhs.kind == ND_ADD && node.lhs.lhs.kind == ND_MEMBER &&
+		node.lhs.lhs.lhs.ty.kind == TY_STRING
+}
+
+// Makes a map index expression yield the value to be assigned.
+
+func mapAssign(node *Node) {
+	node.lhs.rhs.funcname = "runtime_mapassign"
+}
+
+// Rewrites a map index expression into a pair of the value and a
+// boolean reporting whether the key is in the map.
+//
+//   tmp = key, res.1 = runtime_mapaccess2(m, &tmp, &res.0, size), res
+
+func mapCommaOk(node *Node) {
+	tok := node.tok
+	init := node.lhs.lhs
+	call := node.lhs.rhs
+	m, key := call.args, call.args.next
+
+	val := copyType(call.ty.base)
+	val.next = copyType(tyBool)
+	res := newLvar("", tupleType(val))
+	dst := newUnary(ND_MEMBER, newVarNode(res, tok), tok)
+	dst.member = res.ty.members
+	ok := newUnary(ND_MEMBER, newVarNode(res, tok), tok)
+	ok.member = res.ty.members.next
+
+	access := runtimeCall("runtime_mapaccess2", nil, tok, m, key,
+		newUnary(ND_ADDR, dst, tok), newNum(val.size, tok))
+	*node = *newBinary(ND_COMMA, init,
+		newBinary(ND_COMMA, newBinary(ND_ASSIGN, ok, access, tok), newVarNode(res, tok), tok), tok)
+	node.retBuffer = res
+	addType(node)
+}
+
+// Returns a receive expression `<-ch`, which is lowered to
+//
+//   runtime_chanrecv(ch, &tmp), tmp
+
+func newRecv(ch *Node, tok *Token) *Node {
+	addType(ch)
+	if ch.ty.kind != TY_CHAN {
+		errorTok(tok, "invalid operation: cannot receive from non-channel %s", typeString(ch.ty))
+	}
+	if ch.ty.dir == CHAN_SEND {
+		errorTok(tok, "invalid operation: cannot receive from send-only channel %s", typeString(ch.ty))
+	}
+	tmp := newLvar("", ch.ty.base)
+	tmp.isTemp = true
+	call := runtimeCall("runtime_chanrecv", nil, tok, ch, newUnary(ND_ADDR, newVarNode(tmp, tok), tok))
+	return newBinary(ND_COMMA, call, newVarNode(tmp, tok), tok)
+}
+
+func isChanRecv(node *Node) bool {
+	return node.kind == ND_COMMA && node.lhs.kind == ND_FUNCALL && node.lhs.funcname == "runtime_chanrecv"
+}
+
+// Rewrites a receive expression into a pair of the value and a boolean
+// reporting whether the value was sent rather than the zero value of
+// a closed channel.
+//
+//   res.1 = runtime_chanrecv(ch, &res.0), res
+
+func chanCommaOk(node *Node) {
+	tok := node.tok
+	ch := node.lhs.args
+
+	val := copyType(node.rhs.vr.ty)
+	val.next = copyType(tyBool)
+	res := newLvar("", tupleType(val))
+	dst := newUnary(ND_MEMBER, newVarNode(res, tok), tok)
+	dst.member = res.ty.members
+	ok := newUnary(ND_MEMBER, newVarNode(res, tok), tok)
+	ok.member = res.ty.members.next
+
+	recv := runtimeCall("runtime_chanrecv", nil, tok, ch, newUnary(ND_ADDR, dst, tok))
+	*node = *newBinary(ND_COMMA, newBinary(ND_ASSIGN, ok, recv, tok), newVarNode(res, tok), tok)
+	node.retBuffer = res
+	addType(node)
+}
+
+// Returns a send statement `ch <- val`, which is lowered to
+//
+//   tmp = val, runtime_chansend(ch, &tmp)
+
+func newSend(ch *Node, val *Node, tok *Token) *Node {
+	addType(ch)
+	if ch.ty.kind != TY_CHAN {
+		errorTok(tok, "invalid operation: cannot send to non-channel %s", typeString(ch.ty))
+	}
+	if ch.ty.dir == CHAN_RECV {
+		errorTok(tok, "invalid operation: cannot send to receive-only channel %s", typeString(ch.ty))
+	}
+	init, c := evalOnce(ch, tok)
+	tmp := newLvar("", ch.ty.base)
+	node := newBinary(ND_COMMA, newInit(newVarNode(tmp, tok), val, tok),
+		runtimeCall("runtime_chansend", nil, tok, c, newUnary(ND_ADDR, newVarNode(tmp, tok), tok)), tok)
+	if init == nil {
+		return node
+	}
+	return newBinary(ND_COMMA, init, node, tok)
+}
+
+// Returns a slice expression `node[lo:hi:max]` of an array, a pointer
+// to an array, a slice or a string. The omitted indices default to zero, the
+// length and the capacity.
+
+func sliceExpr(node *Node, lo *Node, hi *Node, max *Node, tok *Token) *Node {
+	addType(node)
+	if node.ty.kind == TY_PTR && node.ty.base.kind == TY_ARRAY {
+		node = newUnary(ND_DEREF, node, tok)
+		addType(node)
+	}
+
+	// An array is sliced as if it were a slice of the whole array.
+	var init, s *Node
+	switch node.ty.kind {
+	case TY_ARRAY:
+		if !isAddressable(node) {
+			errorTok(tok, "invalid operation: %s (slice of unaddressable value)", typeString(node.ty))
+		}
+		tmp := newLvar("", sliceOf(node.ty.base))
+		s = newVarNode(tmp, tok)
+		init = newBinary(ND_COMMA,
+			newBinary(ND_ASSIGN, sliceMember(s, 0, tok), newUnary(ND_ADDR, node, tok), tok),
+			newBinary(ND_COMMA,
+				newBinary(ND_ASSIGN, sliceMember(s, 1, tok), newNum(node.ty.arrayLen, tok), tok),
+				newBinary(ND_ASSIGN, sliceMember(s, 2, tok), newNum(node.ty.arrayLen, tok), tok), tok), tok)
+	case TY_SLICE:
+		init, s = evalOnce(node, tok)
+	case TY_STRING:
+		if max != nil {
+			errorTok(tok, "invalid operation: 3-index slice of string")
+		}
+		init, s = evalOnce(node, tok)
+		if lo == nil {
+			lo = newNum(0, tok)
+		}
+		if hi == nil {
+			hi = sliceMember(s, 1, tok)
+		}
+		call := runtimeCall("runtime_slicestring", node.ty, tok, s, lo, hi)
+		if init == nil {
+			return call
+		}
+		return newBinary(ND_COMMA, init, call, tok)
+	default:
+		errorTok(tok, "cannot slice %s", typeString(node.ty))
+	}
+
+	if lo == nil {
+		lo = newNum(0, tok)
+	}
+	if hi == nil {
+		hi = sliceMember(s, 1, tok)
+	}
+	if max == nil {
+		max = sliceMember(s, 2, tok)
+	}
+	ty := sliceOf(node.ty.base)
+	call := runtimeCall("runtime_sliceExpr", ty, tok, s, lo, hi, max, newNum(ty.base.size, tok))
+	if init == nil {
+		return call
+	}
+	return newBinary(ND_COMMA, init, call, tok)
+}
+
+// Returns the i-th member of the slice or string `node`, which is
+// the pointer, the length or the capacity.
+
+func sliceMember(node *Node, i int, tok *Token) *Node {
+	addType(node)
+	mem := node.ty.members
+	for ; i > 0; i-- {
+		mem = mem.next
+	}
+	member := newUnary(ND_MEMBER, node, tok)
+	member.member = mem
+	return member
+}
+
+// Returns an expression which copies the value of `node` to a
+// temporary variable and an expression which refers to the copy.
+// The first is nil if `node` is a variable, which can be referred to
+// as many times as needed.
+
+func evalOnce(node *Node, tok *Token) (*Node, *Node) {
+	if node.kind == ND_VAR {
+		return nil, node
+	}
+	addType(node)
+	tmp := newLvar("", node.ty)
+	return newInit(newVarNode(tmp, tok), node, tok), newVarNode(tmp, tok)
+}
+
+// Returns a call of the runtime function `name`. If `ty` is not nil,
+// it overrides the result type of the function, which is how the
+// runtime returns slices of any element type.
+
+func runtimeCall(name string, ty *Type, tok *Token, args ...*Node) *Node {
+	var fn *Obj
+	for vr := globals; vr != nil; vr = vr.next {
+		if vr.isFunction && vr.name == name {
+			fn = vr
+		}
+	}
+
+	// The runtime is declared after the constants and the types of
+	// the program, so it cannot be called in them.
+	if fn == nil {
+		errorTok(tok, "expression is not constant")
+	}
+
+	node := newNode(ND_FUNCALL, tok)
+	node.funcname = name
+	node.funcTy = fn.ty
+	if isAggregate(fn.ty.returnTy) {
+		node.retBuffer = newLvar("", fn.ty.returnTy)
+	}
+	head := new(Node)
+	cur := head
+	for _, arg := range args {
+		// The arguments are linked through `next`, so they are copied
+		// in case a node is shared with another expression.
+		a := *arg
+		a.next = nil
+		cur.next = &a
+		cur = cur.next
+	}
+	node.args = head.next
+	addType(node)
+	if ty != nil {
+		node.ty = ty
+	}
+	return node
+}
+
+// composite-lit = "{" (element ("," element)* ","?)? "}"
+// element       = (key ":")? (expr | composite-lit)
+// key           = ident | num | expr | composite-lit
+//
+// The type of a nested composite literal may be elided. The keys of
+// a map literal are expressions, and are required.
+
+func compositeLit(rest **Token, tok *Token, ty *Type) *Node {
+	node := newNode(ND_COMPLIT, tok)
+	node.ty = ty
+	tok = skip(tok, "{")
+
+	if ty.kind != TY_ARRAY && ty.kind != TY_STRUCT && ty.kind != TY_SLICE && ty.kind != TY_MAP {
+		errorTok(tok, "invalid composite literal type")
+	}
+
+	head := new(Node)
+	cur := head
+	idx := 0
+	mem := ty.members
+	keyed := false
+
+	for !equal(tok, "}") {
+		if cur != head {
+			tok = skip(tok, ",")
+			if equal(tok, "}") {
+				break
+			}
+		}
+
+		elem := newNode(ND_INIT, tok)
+		if ty.kind == TY_MAP {
+			if equal(tok, "{") {
+				elem.rhs = compositeLit(&tok, tok, ty.key)
+			} else {
+				elem.rhs = assign(&tok, tok)
+			}
+			if !equal(tok, ":") {
+				errorTok(tok, "missing key in map literal")
+			}
+			tok = tok.next
+		} else if ty.kind == TY_STRUCT && equal(tok.next, ":") {
+			if cur != head && !keyed {
+				errorTok(tok, "mixture of field:value and value elements in struct literal")
+			}
+			mem = getStructMember(ty, tok)
+			for e := head.next; e != nil; e = e.next {
+				if e.member == mem {
+					errorTok(tok, "duplicate field name %s in struct literal", getIdent(tok))
+				}
+			}
+			keyed = true
+			tok = tok.next.next
+		} else if ty.kind != TY_STRUCT && isKeyedElement(tok) {
+			idx = constInt(&tok, tok)
+			tok = skip(tok, ":")
+		} else if ty.kind == TY_STRUCT && keyed {
+			errorTok(tok, "mixture of field:value and value elements in struct literal")
+		}
+
+		var elemTy *Type
+		if ty.kind == TY_MAP {
+			elemTy = ty.base
+		} else if ty.kind == TY_STRUCT {
+			if mem == nil {
+				errorTok(tok, "too many values in struct literal")
+			}
+			elem.member = mem
+			elemTy = mem.ty
+			mem = mem.next
+		} else {
+			if idx < 0 || (ty.kind == TY_ARRAY && ty.arrayLen >= 0 && idx >= ty.arrayLen) {
+				errorTok(tok, "array index %d out of bounds [0:%d]", idx, ty.arrayLen)
+			}
+			elem.val = idx
+			elemTy = ty.base
+			idx++
+		}
+
+		if equal(tok, "{") {
+			elem.lhs = compositeLit(&tok, tok, elemTy)
+		} else {
+			elem.lhs = assign(&tok, tok)
+		}
+		cur.next = elem
+		cur = cur.next
+	}
+
+	// Unless they are keyed, either all the fields are given or none.
+	if ty.kind == TY_STRUCT && !keyed && cur != head && mem != nil {
+		errorTok(tok, "too few values in struct literal")
+	}
+	*rest = tok.next
+	node.body = head.next
+	return node
+}
+
+// Returns true if the element of a composite literal at `tok` has
+// a key, i.e. it has a ":" outside of parentheses and brackets.
+
+func isKeyedElement(tok *Token) bool {
+	depth := 0
+	for ; tok.kind != TK_EOF; tok = tok.next {
+		if equal(tok, "(") || equal(tok, "[") || equal(tok, "{") {
+			depth++
+		} else if equal(tok, ")") || equal(tok, "]") || equal(tok, "}") {
+			if depth == 0 {
+				return false
+			}
+			depth--
+		} else if depth == 0 && equal(tok, ",") {
+			return false
+		} else if depth == 0 && equal(tok, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of elements of an array or slice composite
+// literal, which is one more than the largest index.
+
+func complitLen(lit *Node) int {
+	len := 0
+	for elem := lit.body; elem != nil; elem = elem.next {
+		if len < elem.val+1 {
+			len = elem.val + 1
+		}
+	}
+	return len
+}
+
+// Returns an expression which zero-clears `lhs` and stores the
+// elements of a composite literal to it. A slice literal allocates
+// a new array, and a map literal a new hash table.
+
+func initComplit(lhs *Node, lit *Node) *Node {
+	node := newUnary(ND_MEMZERO, lhs, lit.tok)
+	if lit.ty.kind == TY_MAP {
+		n := 0
+		for elem := lit.body; elem != nil; elem = elem.next {
+			n++
+		}
+		node = newBinary(ND_ASSIGN, lhs, makeMap(lit.ty, newNum(n, lit.tok), lit.tok), lit.tok)
+	}
+	if lit.ty.kind == TY_SLICE {
+		n := complitLen(lit)
+		call := runtimeCall("runtime_makeslice", lit.ty, lit.tok,
+			newNum(n, lit.tok), newNum(n, lit.tok), newNum(lit.ty.base.size, lit.tok))
+		node = newBinary(ND_ASSIGN, lhs, call, lit.tok)
+	}
+	return newBinary(ND_COMMA, node, initElements(lhs, lit), lit.tok)
+}
+
+func initElements(lhs *Node, lit *Node) *Node {
+	var inits []*Node
+	for elem := lit.body; elem != nil; elem = elem.next {
+		var target *Node
+		if lit.ty.kind == TY_MAP {
+			checkAssign(elem.lhs, lit.ty.base)
+			inits = append(inits, newBinary(ND_ASSIGN, newMapIndex(lhs, elem.rhs, elem.tok), elem.lhs, elem.tok))
+			continue
+		}
+		if lit.ty.kind == TY_STRUCT {
+			target = newUnary(ND_MEMBER, lhs, elem.tok)
+			target.member = elem.member
+		} else if lit.ty.kind == TY_SLICE {
+			ptr := sliceMember(lhs, 0, elem.tok)
+			target = newUnary(ND_DEREF, newPtrAdd(ptr, newNum(elem.val, elem.tok), elem.tok), elem.tok)
+		} else {
+			target = newUnary(ND_DEREF, newPtrAdd(lhs, newNum(elem.val, elem.tok), elem.tok), elem.tok)
+		}
+
+		if elem.lhs.kind == ND_COMPLIT && (elem.lhs.ty.kind == TY_SLICE || elem.lhs.ty.kind == TY_MAP) {
+			inits = append(inits, initComplit(target, elem.lhs))
+		} else if elem.lhs.kind == ND_COMPLIT {
+			inits = append(inits, initElements(target, elem.lhs))
+		} else {
+			addType(target)
+			checkAssign(elem.lhs, target.ty)
+			inits = append(inits, newBinary(ND_ASSIGN, target, elem.lhs, elem.tok))
+		}
+	}
+
+	node := lhs
+	for i := len(inits) - 1; i >= 0; i-- {
+		node = newBinary(ND_COMMA, inits[i], node, inits[i].tok)
+	}
+	return node
+}
+
+// Rewrites a composite literal used as an expression into
+// a store to a temporary variable.
+
+func lowerComplit(node *Node) {
+	vr := newLvar("", node.ty)
+	vr.isTemp = true
+	lhs := newVarNode(vr, node.tok)
+	init := initComplit(lhs, node)
+	init.next = node.next
+	*node = *init
+	node.ty = nil
+}
+
+// A string literal is a string header pointing to an anonymous array
+// of the bytes.
+//
+//   tmp.ptr = &lit, tmp.len = n, tmp
+
+func stringLiteral(tok *Token) *Node {
+	vr := newStringLiteral(tok.str, tok.ty)
+	tmp := newLvar("", tyString)
+	ptr := newBinary(ND_ASSIGN, sliceMember(newVarNode(tmp, tok), 0, tok),
+		newUnary(ND_ADDR, newVarNode(vr, tok), tok), tok)
+	len := newBinary(ND_ASSIGN, sliceMember(newVarNode(tmp, tok), 1, tok),
+		newNum(tok.ty.arrayLen, tok), tok)
+	return newBinary(ND_COMMA, ptr, newBinary(ND_COMMA, len, newVarNode(tmp, tok), tok), tok)
+}
+
+func isStringLiteral(node *Node) bool {
+	return node.kind == ND_COMMA && node.tok.kind == TK_STR
+}
+
+// Returns a string constant of the value `str` and the type `ty`,
+// which is the result of an operation on string constants at `tok`.
+
+func newStringConst(str string, ty *Type, tok *Token) *Node {
+	t := *tok
+	t.kind = TK_STR
+	t.str = str
+	t.ty = arrayOf(tyUint8, len(str))
+	node := stringLiteral(&t)
+	addType(node)
+	node.ty = ty
+	return node
+}
+
+// Returns a conversion of `node` to `ty`. A value is converted to an
+// interface type as it is assigned to it. Strings are converted to and
+// from slices of bytes and runes by copying, and an integer to the
+// UTF-8 encoding of the rune. Numbers are converted to each other.
+// Otherwise the types must be identical except for their names.
+
+func newConversion(node *Node, ty *Type, tok *Token) *Node {
+	addType(node)
+	if ty.kind == TY_INTERFACE {
+		checkAssign(node, ty)
+		return convertValue(node, ty)
+	}
+
+	from := node.ty
+	if ty.kind == TY_STRING {
+		if from.kind == TY_SLICE && from.base.kind == TY_UINT8 {
+			return runtimeCall("runtime_slicebytetostring", ty, tok, node)
+		}
+		if from.kind == TY_SLICE && from.base.kind == TY_INT32 {
+			return runtimeCall("runtime_slicerunetostring", ty, tok, node)
+		}
+		if isInteger(from) {
+			return runtimeCall("runtime_intstring", ty, tok, node)
+		}
+	}
+	if from.kind == TY_STRING && ty.kind == TY_SLICE {
+		if ty.base.kind == TY_UINT8 {
+			return runtimeCall("runtime_stringtoslicebyte", ty, tok, node)
+		}
+		if ty.base.kind == TY_INT32 {
+			return runtimeCall("runtime_stringtoslicerune", ty, tok, node)
+		}
+	}
+
+	// An integer is converted to another integer type by truncating
+	// or extending it, and a floating-point number to an integer by
+	// truncating it toward zero. A constant must be representable by
+	// the type.
+	if isNumeric(from) && isNumeric(ty) {
+		if isConst(node) && isFlonum(ty) {
+			v, ok := roundFloat(floatOf(node), ty)
+			if !ok {
+				errorTok(tok, "cannot convert %s (constant of type %s) to type %s",
+					constString(node), typeString(from), typeString(ty))
+			}
+			return newFloatConst(v, ty, tok)
+		}
+		if isConst(node) {
+			if node.fval != nil && !node.fval.IsInt() {
+				errorTok(tok, "cannot convert %s (constant of type %s) to type %s (truncated)",
+					constString(node), typeString(from), typeString(ty))
+			}
+			if !representable(constOf(node), ty) {
+				errorTok(tok, "cannot convert %s (constant of type %s) to type %s",
+					constOf(node), typeString(from), typeString(ty))
+			}
+			return newConst(constOf(node), ty, tok)
+		}
+		conv := newUnary(ND_CAST, node, tok)
+		conv.ty = ty
+		return conv
+	}
+
+	t1, t2 := *ty, *from
+	t1.typeName, t2.typeName = nil, nil
+	if !identical(&t1, &t2) && !assignable(&t2, &t1) {
+		errorTok(tok, "cannot convert %s to type %s", typeString(from), typeString(ty))
+	}
+	conv := *node
+	conv.ty = ty
+	return &conv
+}
+
+func isBuiltin(tok *Token) bool {
+	return equal(tok, "len") || equal(tok, "cap") || equal(tok, "append") ||
+		equal(tok, "copy") || equal(tok, "make") || equal(tok, "delete") ||
+		equal(tok, "close") || equal(tok, "panic") || equal(tok, "recover")
+}
+
+// builtin-call = ("len" | "cap") "(" assign ")"
+//              | "append" "(" assign ("," assign)* ("," assign "...")? ","? ")"
+//              | "copy" "(" assign "," assign ")"
+//              | "delete" "(" assign "," assign ")"
+//              | "close" "(" assign ")"
+//              | "make" "(" declarator ("," assign ("," assign)?)? ")"
+//              | "panic" "(" assign ")"
+//              | "recover" "(" ")"
+
+func builtinCall(rest **Token, tok *Token) *Node {
+	start := tok
+	tok = skip(tok.next, "(")
+
+	if equal(start, "make") {
+		ty := declarator(&tok, tok)
+		if ty.kind == TY_MAP {
+			hint := newNum(0, start)
+			if consume(&tok, tok, ",") {
+				hint = assign(&tok, tok)
+			}
+			*rest = skip(tok, ")")
+			return makeMap(ty, hint, start)
+		}
+		if ty.kind == TY_CHAN {
+			size := newNum(0, start)
+			if consume(&tok, tok, ",") {
+				size = assign(&tok, tok)
+			}
+			*rest = skip(tok, ")")
+			return runtimeCall("runtime_makechan", ty, start, newNum(ty.base.size, start), size)
+		}
+		if ty.kind != TY_SLICE {
+			errorTok(start, "invalid argument: cannot make %s", typeString(ty))
+		}
+		tok = skip(tok, ",")
+		len := assign(&tok, tok)
+		var cap *Node
+		if consume(&tok, tok, ",") {
+			cap = assign(&tok, tok)
+		}
+		*rest = skip(tok, ")")
+
+		// tmp = len, makeslice(tmp, tmp, size)
+		var init *Node
+		if cap == nil {
+			addType(len)
+			tmp := newLvar("", len.ty)
+			init = newBinary(ND_ASSIGN, newVarNode(tmp, start), len, start)
+			len = newVarNode(tmp, start)
+			cap = newVarNode(tmp, start)
+		}
+		node := runtimeCall("runtime_makeslice", ty, start, len, cap, newNum(ty.base.size, start))
+		if init == nil {
+			return node
+		}
+		return newBinary(ND_COMMA, init, node, start)
+	}
+
+	var args []*Node
+	spread := false
+	for !equal(tok, ")") {
+		if len(args) > 0 {
+			tok = skip(tok, ",")
+			if equal(tok, ")") {
+				break
+			}
+		}
+		args = append(args, assign(&tok, tok))
+		if equal(start, "append") && len(args) == 2 && consume(&tok, tok, "...") {
+			spread = true
+		}
+	}
+	*rest = skip(tok, ")")
+
+	for _, arg := range args {
+		addType(arg)
+	}
+	if equal(start, "recover") {
+		if len(args) != 0 {
+			errorTok(args[0].tok, "too many arguments for recover")
+		}
+		return runtimeCall("runtime_gorecover", interfaceType(), start)
+	}
+	if len(args) == 0 {
+		errorTok(start, "not enough arguments for %s", getIdent(start))
+	}
+
+	switch getIdent(start) {
+	case "len", "cap":
+		if len(args) != 1 {
+			errorTok(start, "wrong number of arguments for %s", getIdent(start))
+		}
+		ty := args[0].ty
+		if ty.kind == TY_PTR && ty.base.kind == TY_ARRAY {
+			ty = ty.base
+		}
+		if ty.kind == TY_ARRAY {
+			return newNum(ty.arrayLen, start)
+		}
+		if ty.kind == TY_MAP && equal(start, "len") {
+			return runtimeCall("runtime_maplen", nil, start, args[0])
+		}
+		if ty.kind == TY_CHAN {
+			return runtimeCall("runtime_chan"+getIdent(start), nil, start, args[0])
+		}
+		if ty.kind == TY_STRING && equal(start, "len") {
+			return sliceMember(args[0], 1, start)
+		}
+		if ty.kind != TY_SLICE {
+			errorTok(args[0].tok, "invalid argument: %s for built-in %s", typeString(ty), getIdent(start))
+		}
+		if equal(start, "len") {
+			return sliceMember(args[0], 1, start)
+		}
+		return sliceMember(args[0], 2, start)
+	case "copy":
+		if len(args) != 2 {
+			errorTok(start, "wrong number of arguments for copy")
+		}
+		if args[0].ty.kind != TY_SLICE || args[1].ty.kind != TY_SLICE ||
+			!identical(args[0].ty.base, args[1].ty.base) {
+			errorTok(start, "arguments to copy have different element types")
+		}
+		return runtimeCall("runtime_slicecopy", nil, start, args[0], args[1],
+			newNum(args[0].ty.base.size, start))
+	case "delete":
+		if len(args) != 2 {
+			errorTok(start, "wrong number of arguments for delete")
+		}
+		if args[0].ty.kind != TY_MAP {
+			errorTok(args[0].tok, "invalid argument: %s is not a map", typeString(args[0].ty))
+		}
+		init, key := mapKey(args[0].ty, args[1], start)
+		return newBinary(ND_COMMA, init, runtimeCall("runtime_mapdelete", nil, start, args[0], key), start)
+	case "close":
+		if len(args) != 1 {
+			errorTok(start, "wrong number of arguments for close")
+		}
+		if args[0].ty.kind != TY_CHAN {
+			errorTok(args[0].tok, "invalid argument: %s is not a channel", typeString(args[0].ty))
+		}
+		if args[0].ty.dir == CHAN_RECV {
+			errorTok(args[0].tok, "invalid operation: cannot close receive-only channel %s", typeString(args[0].ty))
+		}
+		return runtimeCall("runtime_closechan", nil, start, args[0])
+	case "panic":
+		if len(args) != 1 {
+			errorTok(start, "wrong number of arguments for panic")
+		}
+		return runtimeCall("runtime_gopanic", nil, start, convertValue(args[0], interfaceType()))
+	}
+	return newAppend(args, spread, start)
+}
+
+// Returns `append(s, elems...)`, which is lowered to
+//
+//   tmp = s, tmp = runtime_growslice(tmp, n, size),
+//   tmp.ptr[tmp.len-n] = elem0, ..., tmp
+//
+// If the last argument is a slice followed by "...", its elements are
+// appended instead.
+
+func newAppend(args []*Node, spread bool, tok *Token) *Node {
+	ty := args[0].ty
+	if ty.kind != TY_SLICE {
+		errorTok(args[0].tok, "invalid argument: %s is not a slice", typeString(ty))
+	}
+	tmp := newLvar("", ty)
+	node := newBinary(ND_ASSIGN, newVarNode(tmp, tok), args[0], tok)
+	push := func(expr *Node) {
+		node = newBinary(ND_COMMA, node, expr, tok)
+	}
+
+	if spread {
+		if args[1].ty.kind != TY_SLICE || !identical(args[1].ty.base, ty.base) {
+			errorTok(args[1].tok, "cannot use %s as %s value in append", typeString(args[1].ty), typeString(ty))
+		}
+		src := newLvar("", args[1].ty)
+		n := func() *Node { return sliceMember(newVarNode(src, tok), 1, tok) }
+		push(newBinary(ND_ASSIGN, newVarNode(src, tok), args[1], tok))
+		push(newBinary(ND_ASSIGN, newVarNode(tmp, tok),
+			runtimeCall("runtime_growslice", ty, tok, newVarNode(tmp, tok), n(), newNum(ty.base.size, tok)), tok))
+		dst := newPtrAdd(sliceMember(newVarNode(tmp, tok), 0, tok),
+			newSub(sliceMember(newVarNode(tmp, tok), 1, tok), n(), tok), tok)
+		push(runtimeCall("memmove", nil, tok, dst, sliceMember(newVarNode(src, tok), 0, tok),
+			newBinary(ND_MUL, n(), newNum(ty.base.size, tok), tok)))
+		push(newVarNode(tmp, tok))
+		return node
+	}
+
+	elems := args[1:]
+	n := len(elems)
+	push(newBinary(ND_ASSIGN, newVarNode(tmp, tok),
+		runtimeCall("runtime_growslice", ty, tok, newVarNode(tmp, tok), newNum(n, tok), newNum(ty.base.size, tok)), tok))
+	for i, elem := range elems {
+		idx := newSub(sliceMember(newVarNode(tmp, tok), 1, tok), newNum(n-i, tok), tok)
+		target := newUnary(ND_DEREF, newPtrAdd(sliceMember(newVarNode(tmp, tok), 0, tok), idx, tok), tok)
+		push(newBinary(ND_ASSIGN, target, elem, tok))
+	}
+	push(newVarNode(tmp, tok))
+	return node
+}
+
+// funcall = ident func-args
+
+func funcall(rest **Token, tok *Token) *Node {
+	node := newNode(ND_FUNCALL, tok)
+	node.funcname = getIdent(tok)
+
+	// Functions not declared in the program, such as the ones in
+	// libc, are assumed to return int.
+	var params *Type
+	if fn := findVar(tok); fn != nil && fn.isFunction {
+		node.funcname = fn.name
+		node.funcTy = fn.ty
+		params = fn.ty.params
+		if isAggregate(fn.ty.returnTy) {
+			node.retBuffer = newLvar("", fn.ty.returnTy)
+		}
+	}
+	node.args = funcArgs(rest, tok.next, params)
+	return node
+}
+
+// func-args = "(" (assign ("," assign)*)? ")"
+//
+// Arguments are converted to the types of the parameters.
+
+func funcArgs(rest **Token, tok *Token, params *Type) *Node {
+	tok = skip(tok, "(")
+
+	head := new(Node)
+	cur := head
+
+	for !equal(tok, ")") {
+		if cur != head {
+			tok = skip(tok, ",")
+		}
+		arg := assign(&tok, tok)
+		if params != nil {
+			checkAssign(arg, params)
+			arg = convertValue(arg, params)
+			params = params.next
+		}
+		cur.next = arg
+		cur = cur.next
+	}
+
+	*rest = skip(tok, ")")
+	return head.next
+}
+
+// Returns a call of the method `name` of `lhs`. A method of an
+// interface is called through the itab of the interface value.
+
+func methodCall(rest **Token, tok *Token, lhs *Node, name *Token) *Node {
+	m := findMethod(lhs.ty, getIdent(name))
+	node := newNode(ND_FUNCALL, name)
+	node.funcTy = m.ty
+	if isAggregate(m.ty.returnTy) {
+		node.retBuffer = newLvar("", m.ty.returnTy)
+	}
+	args := funcArgs(rest, tok, m.ty.params)
+
+	if lhs.ty.kind != TY_INTERFACE {
+		node.funcname = m.fn.name
+		node.funcTy = m.fn.ty
+		recv := receiver(lhs, m, name)
+		recv.next = args
+		node.args = recv
+		return node
+	}
+
+	// tmp = &lhs, (*tmp.tab[i+1])(tmp.data, args...)
+	idx := 1
+	for im := lhs.ty.methods; im != m; im = im.next {
+		idx++
+	}
+	tmp := newLvar("", pointerTo(lhs.ty))
+	init := newBinary(ND_ASSIGN, newVarNode(tmp, name), newUnary(ND_ADDR, lhs, name), name)
+
+	tab := newUnary(ND_MEMBER, newUnary(ND_DEREF, newVarNode(tmp, name), name), name)
+	tab.member = ifaceTab
+	node.lhs = newUnary(ND_DEREF, newPtrAdd(tab, newNum(idx, name), name), name)
+
+	data := newUnary(ND_MEMBER, newUnary(ND_DEREF, newVarNode(tmp, name), name), name)
+	data.member = ifaceData
+	data.next = args
+	node.args = data
+
+	// The results of a tuple are read from the buffer of the call.
+	comma := newBinary(ND_COMMA, init, node, name)
+	comma.funcname = m.name
+	comma.retBuffer = node.retBuffer
+	return comma
+}
+
+// Returns the receiver passed to the method `m` of `lhs`. If `lhs` is
+// a pointer and the method has a value receiver, it is dereferenced.
+// Conversely, `x.M()` is short for `(&x).M()` if the method has a
+// pointer receiver and `x` is addressable.
+
+func receiver(lhs *Node, m *Method, tok *Token) *Node {
+	if m.ptrRecv && lhs.ty.kind != TY_PTR {
+		if !isAddressable(lhs) {
+			errorTok(tok, "cannot call pointer method %s on %s", m.name, typeString(lhs.ty))
+		}
+		lhs = newUnary(ND_ADDR, lhs, tok)
+	} else if !m.ptrRecv && lhs.ty.kind == TY_PTR {
+		lhs = newUnary(ND_DEREF, lhs, tok)
+	}
+	addType(lhs)
+	return lhs
+}
+
+// Returns true if the address of `node` can be taken, which is the
+// case for variables, pointer indirections, and members and elements
+// of addressable values. The value of a composite literal is not
+// addressable, though &T{...} is allowed.
+
+func isAddressable(node *Node) bool {
+	switch node.kind {
+	case ND_VAR:
+		return node.vr.initData == "" && !node.vr.isTemp
+	case ND_DEREF:
+		if node.lhs.kind == ND_ADD && node.lhs.lhs.ty.kind == TY_ARRAY {
+			return isAddressable(node.lhs.lhs)
+		}
+		return !isMapIndex(node) && !isStringIndex(node)
+	case ND_MEMBER:
+		return isAddressable(node.lhs)
+	case ND_COMMA:
+		return isAddressable(node.rhs)
+	}
+	return false
+}
+
+// Returns true if `node` is a field or an array element of a map
+// element, such as `m[k].f` or `m[k][i]`.
+
+func inMapElement(node *Node) bool {
+	switch node.kind {
+	case ND_DEREF:
+		if node.lhs.kind == ND_ADD && node.lhs.lhs.ty.kind == TY_ARRAY {
+			return isMapIndex(node.lhs.lhs) || inMapElement(node.lhs.lhs)
+		}
+	case ND_MEMBER:
+		return isMapIndex(node.lhs) || inMapElement(node.lhs)
+	}
+	return false
+}
+
+// Reports an error if `node` is in a map element. Unlike the map
+// element itself, it cannot be assigned to, because a map element is
+// not addressable.
+
+func checkMapElement(node *Node) {
+	if !inMapElement(node) {
+		return
+	}
+	if node.kind == ND_MEMBER {
+		errorTok(node.tok, "cannot assign to struct field in map")
+	}
+	errorTok(node.tok, "cannot assign to array element in map")
+}
+
+// Returns a method value `lhs.name`, which is a function value bound
+// to the receiver. The receiver is evaluated and copied when the
+// method value is created.
+
+func methodValue(lhs *Node, name *Token) *Node {
+	m := findMethod(lhs.ty, getIdent(name))
+	node := newNode(ND_CLOSURE, name)
+	node.method = m
+	node.ty = m.ty
+	if lhs.ty.kind == TY_INTERFACE {
+		node.lhs = lhs
+	} else {
+		node.lhs = receiver(lhs, m, name)
+	}
+	return node
+}
+
+// method-expr = typedef-name "." ident
+//             | "(" "*" typedef-name ")" "." ident
+//
+// A method expression is a function whose first parameter is the
+// receiver.
+
+func methodExpr(rest **Token, tok *Token) *Node {
+	var ty *Type
+	if equal(tok, "(") {
+		ty = pointerTo(findTypedef(tok.next.next))
+		tok = skip(tok.next.next.next, ")")
+	} else {
+		ty = findTypedef(tok)
+		tok = tok.next
+	}
+	tok = skip(tok, ".")
+
+	m := findMethod(ty, getIdent(tok))
+	if m == nil || ty.kind == TY_INTERFACE {
+		errorTok(tok, "%s has no method %s", typeString(ty), getIdent(tok))
+	}
+	if m.ptrRecv && ty.kind != TY_PTR {
+		errorTok(tok, "invalid method expression %s.%s (needs pointer receiver (*%s).%s)",
+			typeString(ty), m.name, typeString(ty), m.name)
+	}
+
+	recv := copyType(ty)
+	recv.next = m.ty.params
+	fnTy := copyType(m.ty)
+	fnTy.params = recv
+
+	node := newNode(ND_CLOSURE, tok)
+	node.method = m
+	node.ty = fnTy
+	*rest = tok.next
+	return node
+}
+
+// Returns a call of the function value `fn`.
+
+func funcValueCall(rest **Token, tok *Token, fn *Node) *Node {
+	node := newNode(ND_FUNCALL, tok)
+	node.lhs = fn
+	node.funcTy = fn.ty
+	if isAggregate(fn.ty.returnTy) {
+		node.retBuffer = newLvar("", fn.ty.returnTy)
+	}
+	node.args = funcArgs(rest, tok, fn.ty.params)
+	return node
+}
+
+// Converts `node` to the type `ty` of a variable or a parameter it is
+// assigned to. Values are converted to interfaces, and nil to a
+// zero aggregate.
+
+func convertValue(node *Node, ty *Type) *Node {
+	addType(node)
+	if isUntyped(node.ty) {
+		convertConst(node, ty)
+	}
+	if ty.kind == TY_INTERFACE {
+		return toInterface(node, ty)
+	}
+	addType(node)
+	if node.ty == tyNil && isAggregate(ty) {
+		vr := newLvar("", ty)
+		node = newBinary(ND_COMMA, newMemzero(vr, node.tok), newVarNode(vr, node.tok), node.tok)
+		addType(node)
+	}
+	return node
+}
+
+// Reports an error unless the value `node` written in the program can
+// be assigned to a variable of type `ty`. An untyped constant gets the
+// type.
+
+func checkAssign(node *Node, ty *Type) {
+	addType(node)
+	if isUntyped(node.ty) {
+		convertConst(node, ty)
+	}
+	if ty.kind == TY_INTERFACE || assignable(node.ty, ty) || inRuntime(node.tok) {
+		return
+	}
+	errorTok(node.tok, "cannot use a value of type %s as %s value",
+		typeString(node.ty), typeString(ty))
+}
+
+// Returns true if `tok` is in the runtime. The runtime has no
+// unsafe.Pointer, so it assigns values of different types with the
+// same layout to each other as C does, such as a string to a
+// runtime_string or a pointer to any other pointer.
+
+func inRuntime(tok *Token) bool {
+	return fset.file(tok.pos).name == "<runtime>"
+}
+
+// Converts `node` to the interface type `ty`. The type of `node`
+// must implement the interface.
+
+func toInterface(node *Node, ty *Type) *Node {
+	addType(node)
+	if identical(node.ty, ty) {
+		return node
+	}
+	if node.ty != tyNil {
+		if m := missingMethod(node.ty, ty); m != nil {
+			if fn := findMethod(node.ty, m.name); fn != nil && fn.ptrRecv {
+				errorTok(node.tok, "%s does not implement %s (method %s has pointer receiver)",
+					typeString(node.ty), typeString(ty), m.name)
+			}
+			errorTok(node.tok, "%s does not implement %s (missing method %s)",
+				typeString(node.ty), typeString(ty), m.name)
+		}
+	}
+	// The type descriptor refers to the functions comparing the
+	// values held by interface values.
+	if node.ty != tyNil && node.ty.kind != TY_INTERFACE && isComparable(node.ty) {
+		getTypeFuncs(node.ty, node.tok)
+	}
+	conv := newUnary(ND_IFACE, node, node.tok)
+	conv.ty = ty
+	conv.retBuffer = newLvar("", ty)
+	return conv
+}
+
+// The functions hashing and comparing values of a comparable type,
+// which take the values by address. The maps whose keys have the type
+// and the type descriptor refer to them.
+
+type TypeFuncs struct {
+	ty    *Type
+	hash  *Obj
+	equal *Obj
+}
+
+// Returns the functions hashing and comparing values of the type `ty`,
+// which are created when first used.
+//
+//   func hash(p *T, h int) int  { return <h continued with *p> }
+//   func equal(p *T, q *T) bool { return *p == *q }
+
+func getTypeFuncs(ty *Type, tok *Token) *TypeFuncs {
+	for _, tf := range typeFuncs {
+		if identical(tf.ty, ty) {
+			return tf
+		}
+	}
+
+	tf := &TypeFuncs{ty: ty}
+	typeFuncs = append(typeFuncs, tf)
+	n := len(typeFuncs)
+	tf.hash = newTypeFunc(fmt.Sprintf("type.hash.%d", n), tyInt, pointerTo(ty), tyInt, tok,
+		func(p *Obj, h *Obj) *Node {
+			val := func() *Node { return newUnary(ND_DEREF, newVarNode(p, tok), tok) }
+			return hashValue(val, ty, newVarNode(h, tok), tok)
+		})
+	tf.equal = newTypeFunc(fmt.Sprintf("type.eq.%d", n), tyBool, pointerTo(ty), pointerTo(ty), tok,
+		func(p *Obj, q *Obj) *Node {
+			return newBinary(ND_EQ, newUnary(ND_DEREF, newVarNode(p, tok), tok),
+				newUnary(ND_DEREF, newVarNode(q, tok), tok), tok)
+		})
+	return tf
+}
+
+// Returns a function `name` with two parameters of the types `t1` and
+// `t2`, which returns the value of the expression made by `body` from
+// the parameters.
+
+func newTypeFunc(name string, returnTy *Type, t1 *Type, t2 *Type, tok *Token,
+	body func(p *Obj, q *Obj) *Node) *Obj {
+	ty := funcType(returnTy)
+	ty.params = copyType(t1)
+	ty.params.next = copyType(t2)
+	fn := newGvar(name, ty)
+	fn.isFunction = true
+	fn.isDefinition = true
+
+	p := &Obj{ty: t1, isLocal: true, isParam: true, fn: fn}
+	q := &Obj{ty: t2, isLocal: true, isParam: true, fn: fn}
+	p.next = q
+	fn.params = p
+
+	savedFn, savedLocals := currentFn, locals
+	currentFn, locals = fn, p
+	node := newNode(ND_RETURN, tok)
+	node.lhs = convertValue(body(p, q), returnTy)
+	addType(node)
+	fn.body = node
+	fn.locals = locals
+	currentFn, locals = savedFn, savedLocals
+	return fn
+}
+
+// Returns the value of the function `fn` declared at the package level.
+
+func funcValue(fn *Obj, tok *Token) *Node {
+	node := newNode(ND_CLOSURE, tok)
+	node.funcname = fn.name
+	node.ty = fn.ty
+	return node
+}
+
+// Returns a type assertion `lhs.(ty)`.
+
+func newTypeAssert(lhs *Node, ty *Type, tok *Token) *Node {
+	addType(lhs)
+	if ty.kind != TY_INTERFACE && missingMethod(ty, lhs.ty) != nil {
+		errorTok(tok, "impossible type assertion: %s does not implement %s",
+			typeString(ty), typeString(lhs.ty))
+	}
+	node := newUnary(ND_TYPEASSERT, lhs, tok)
+	node.target = ty
+	if ty.kind == TY_INTERFACE {
+		node.retBuffer = newLvar("", ty)
+	}
+	return node
+}
+
+// Makes a type assertion yield a pair of the value and a boolean
+// reporting success instead of panicking on failure.
+
+func commaOk(node *Node) {
+	val := copyType(node.target)
+	val.next = copyType(tyBool)
+	node.ty = tupleType(val)
+	node.retBuffer = newLvar("", node.ty)
+}
+
+// primary = method-expr
+//         | func-lit
+//         | "(" expr ")"
+//         | ("[" "..." "]" declarator | declarator) composite-lit
+//         | declarator "(" expr ")"
+//         | ident func-args?
+//         | str
+//         | num
+
+func primary(rest **Token, tok *Token) *Node {
+	// Method expression
+	if findTypedef(tok) != nil && equal(tok.next, ".") ||
+		equal(tok, "(") && equal(tok.next, "*") && findTypedef(tok.next.next) != nil &&
+			equal(tok.next.next.next, ")") && equal(tok.next.next.next.next, ".") {
+		return methodExpr(rest, tok)
+	}
+
+	if equal(tok, "(") {
+		node := expr(&tok, tok.next)
+		*rest = skip(tok, ")")
+		return node
+	}
+
+	// Function literal
+	if equal(tok, "func") {
+		return funcLit(rest, tok)
+	}
+
+	// Composite literal or conversion
+	if equal(tok, "[") || isTypename(tok) {
+		if equal(tok, "[") && equal(tok.next, "...") {
+			start := tok
+			tok = skip(tok.next.next, "]")
+			base := declarator(&tok, tok)
+			node := compositeLit(rest, tok, arrayOf(base, -1))
+			node.ty = arrayOf(base, complitLen(node))
+			node.tok = start
+			return node
+		}
+		ty := declarator(&tok, tok)
+		if equal(tok, "(") {
+			start := tok
+			node := expr(&tok, tok.next)
+			*rest = skip(tok, ")")
+			return newConversion(node, ty, start)
+		}
+		return compositeLit(rest, tok, ty)
+	}
+
+	if tok.kind == TK_IDENT {
+		// Constant
+		if con := findConst(tok); con != nil {
+			*rest = tok.next
+			return constUse(con, tok)
+		}
+		if equal(tok, "iota") && findVar(tok) == nil {
+			if constIota < 0 {
+				errorTok(tok, "cannot use iota outside constant declaration")
+			}
+			*rest = tok.next
+			return newConst(big.NewInt(int64(constIota)), tyUntypedInt, tok)
+		}
 
-// In Go, '+' and '-' operators are not overloaded to perform the pointer arithmetic
+		// Built-in function call
+		if findVar(tok) == nil && equal(tok.next, "(") && isBuiltin(tok) {
+			return builtinCall(rest, tok)
+		}
 
-func newAdd(lhs *Node, rhs *Node, tok *Token) *Node {
-	addType(lhs)
-	addType(rhs)
+		// Function call
+		if vr := findVar(tok); equal(tok.next, "(") && (vr == nil || vr.isFunction) {
+			return funcall(rest, tok)
+		}
 
-	// num + num
-	if isInteger(lhs.ty) && isInteger(rhs.ty) {
-		return newBinary(ND_ADD, lhs, rhs, tok)
+		// Variable
+		vr := findVar(tok)
+		if vr == nil && equal(tok, "nil") {
+			node := newNum(0, tok)
+			node.ty = tyNil
+			*rest = tok.next
+			return node
+		}
+		if vr == nil {
+			errorTok(tok, "undefined variable")
+		}
+		if vr.ty == tyInvalid {
+			// The error has been reported at the declaration.
+			panic(bailout{})
+		}
+		*rest = tok.next
+		if vr.isFunction {
+			return funcValue(vr, tok)
+		}
+		return newVarNode(captureVar(vr, currentFn), tok)
 	}
 
-	if lhs.ty.base != nil && rhs.ty.base != nil {
-		errorTok(tok, "invalid operands")
+	if tok.kind == TK_STR {
+		*rest = tok.next
+		return stringLiteral(tok)
 	}
 
-	// Canonicalize `num + ptr` to `ptr + num`.
-	if lhs.ty.base == nil && rhs.ty.base != nil {
-		tmp := lhs
-		lhs = rhs
-		rhs = tmp
+	if tok.kind == TK_NUM {
+		if tok.imag {
+			errorTok(tok, "complex numbers are not supported")
+		}
+		*rest = tok.next
+		if tok.fval != nil {
+			return newFloatConst(tok.fval, tok.ty, tok)
+		}
+		return newConst(tok.val, tok.ty, tok)
 	}
 
-	// ptr + num
-	rhs = newBinary(ND_MUL, rhs, newNum(lhs.ty.base.size, tok), tok)
-	return newBinary(ND_ADD, lhs, rhs, tok)
+	errorTok(tok, "expected an expression")
+	return nil
 }
 
-func newSub(lhs *Node, rhs *Node, tok *Token) *Node {
-	addType(lhs)
-	addType(rhs)
+// func-decl = "func" func-params? signature
+//
+// The optional parameter is the receiver of a method, which becomes
+// the first parameter of the function. Returns the type of the
+// function and the named type of the receiver, if any.
 
-	// num + num
-	if isInteger(lhs.ty) && isInteger(rhs.ty) {
-		return newBinary(ND_SUB, lhs, rhs, tok)
+func funcDecl(rest **Token, tok *Token) (*Type, *Type) {
+	tok = skip(tok, "func")
+	if !equal(tok, "(") {
+		return signature(rest, tok), nil
 	}
 
-	if lhs.ty.base != nil || rhs.ty.base != nil {
-		errorTok(tok, "invalid operands: pointer arithmetic is not supported in Go")
+	recv := funcParams(&tok, tok)
+	if recv == nil || recv.next != nil {
+		errorTok(tok, "method has multiple receivers")
+	}
+	base := recv
+	if base.kind == TY_PTR && base.typeName == nil {
+		base = base.base
+	}
+	if base.typeName == nil || base.kind == TY_PTR || base.kind == TY_INTERFACE {
+		errorTok(tok, "invalid receiver type %s", typeString(recv))
+	}
+	if base.origin != nil {
+		base = base.origin
 	}
 
-	errorTok(tok, "invalid operands")
-	return nil
+	ty := signature(rest, tok)
+	recv.next = ty.params
+	ty.params = recv
+	return ty, base
 }
 
-// add = mul ("+" mul | "-" mul)*
-
-func add(rest **Token, tok *Token) *Node {
-	node := mul(&tok, tok)
+// Declares a method of the named type `base`. The name of the method
+// is qualified by the name of the type, e.g. "Point.Len".
 
-	for {
-		start := tok
-		if equal(tok, "+") {
-			node = newAdd(node, mul(&tok, tok.next), start)
-			continue
+func declareMethod(base *Type, ty *Type) {
+	name := getIdent(ty.name)
+	if findMethod(base, name) != nil {
+		errorTok(ty.name, "method %s.%s already declared", getIdent(base.typeName), name)
+	}
+	for mem := base.members; base.kind == TY_STRUCT && mem != nil; mem = mem.next {
+		if getIdent(mem.name) == name {
+			errorTok(ty.name, "field and method with the same name %s", name)
 		}
+	}
 
-		if equal(tok, "-") {
-			node = newSub(node, mul(&tok, tok.next), start)
-			continue
-		}
+	fn := newGvar(getIdent(base.typeName)+"."+name, ty)
+	setSymbol(fn, ty.name)
+	fn.isFunction = true
 
-		*rest = tok
-		return node
+	sig := copyType(ty)
+	sig.params = ty.params.next
+	m := &Method{name: name, ty: sig, fn: fn, ptrRecv: ty.params.kind == TY_PTR}
+	link := &base.methods
+	for *link != nil {
+		link = &(*link).next
 	}
+	*link = m
 }
 
-// mul = unary ("*" unary | "/" unary)*
+// function = func-decl ("{" compound-stmt | ";")
 
-func mul(rest **Token, tok *Token) *Node {
-	node := unary(&tok, tok)
-
-	for {
-		start := tok
-		if equal(tok, "*") {
-			node = newBinary(ND_MUL, node, unary(&tok, tok.next), start)
-			continue
-		}
+func function(rest **Token, tok *Token) *Token {
+	ty, recv := funcDecl(&tok, tok)
 
-		if equal(tok, "/") {
-			node = newBinary(ND_DIV, node, unary(&tok, tok.next), start)
-			continue
-		}
+	// The function has been declared by declareFunctions.
+	var fn *Obj
+	if recv != nil {
+		fn = findMethod(recv, getIdent(ty.name)).fn
+	} else {
+		fn = findVar(ty.name)
+	}
+	fn.ty = ty
 
-		*rest = tok
-		return node
+	// A function declared without a body is defined outside of the
+	// program, like the functions in libc.
+	if equal(tok, ";") {
+		return tok.next
 	}
+
+	currentFn = fn
+	tok = funcBody(fn, tok)
+	currentFn = nil
+	return tok
 }
 
-// unary = ("+" | "-" | "*" | "&") unary
-//       | postfix
+// Parses the body of the function `fn` and returns the token after
+// it. Parameters and result variables captured by function literals
+// are moved to the heap when the function starts. A captured parameter
+// is received in a variable of its own, and then copied.
 
-func unary(rest **Token, tok *Token) *Node {
-	if equal(tok, "+") {
-		return unary(rest, tok.next)
-	}
-	if equal(tok, "-") {
-		return newUnary(ND_NEG, unary(rest, tok.next), tok)
+func funcBody(fn *Obj, tok *Token) *Token {
+	fn.isDefinition = true
+	locals = nil
+	enterScope()
+	createParamLvars(fn.ty.params)
+	fn.params = locals
+	zero := createResultLvars(fn)
+	if returnsViaPointer(fn.ty.returnTy) {
+		fn.retPtr = newLvar("", pointerTo(fn.ty.returnTy))
 	}
-	if equal(tok, "&") {
-		return newUnary(ND_ADDR, unary(rest, tok.next), tok)
+
+	tok = skip(tok, "{")
+	body := componentStmt(&tok, tok)
+	resolveGotoLabels()
+
+	head := new(Node)
+	cur := head
+	isParam := false
+	var boxed []*Obj
+	for link := &locals; *link != nil; link = &(*link).next {
+		vr := *link
+		if vr == fn.params {
+			isParam = true
+		}
+		if !vr.isParam || !vr.isBoxed {
+			continue
+		}
+		cur.next = newUnary(ND_EXPR_STMT, newDecl(vr, body.tok), body.tok)
+		cur = cur.next
+		if !isParam {
+			continue
+		}
+		arg := new(Obj)
+		*arg = *vr
+		arg.isBoxed = false
+		*link = arg
+		if fn.params == vr {
+			fn.params = arg
+		}
+		boxed = append(boxed, vr)
+		cur.next = newUnary(ND_EXPR_STMT,
+			newBinary(ND_ASSIGN, newVarNode(vr, body.tok), newVarNode(arg, body.tok), body.tok), body.tok)
+		cur = cur.next
 	}
-	if equal(tok, "*") {
-		return newUnary(ND_DEREF, unary(rest, tok.next), tok)
+	cur.next = zero
+	for cur.next != nil {
+		cur = cur.next
 	}
 
-	return postfix(rest, tok)
+	if head.next != nil {
+		cur.next = body
+		body = newNode(ND_BLOCK, body.tok)
+		body.body = head.next
+		addType(body)
+	}
+	for _, vr := range append(boxed, fn.captures...) {
+		vr.next = locals
+		locals = vr
+	}
+	fn.body = body
+	fn.locals = locals
+	leaveScope()
+	return tok
 }
 
-// postfix = primary ("[" expr "]")*
+// func-lit = "func" func-signature "{" compound-stmt
+//
+// A function literal is compiled into a function of its own. The
+// local variables of the enclosing functions it refers to are
+// captured by reference: they are moved to the heap, and the closure
+// holds pointers to them.
 
-func postfix(rest **Token, tok *Token) *Node {
-	node := primary(&tok, tok)
+func funcLit(rest **Token, tok *Token) *Node {
+	start := tok
+	ty := funcSignature(&tok, tok.next)
 
-	for equal(tok, "[") {
-		// x[y] is short for *(x+y)
-		start := tok
-		idx := expr(&tok, tok.next)
-		tok = skip(tok, "]")
-		node = newUnary(ND_DEREF, newAdd(node, idx, start), start)
+	var name string
+	if currentFn != nil {
+		currentFn.numFuncLits++
+		name = fmt.Sprintf("%s.func%d", currentFn.name, currentFn.numFuncLits)
+	} else {
+		numInitFuncLits++
+		name = fmt.Sprintf("main.init.func%d", numInitFuncLits)
 	}
-	*rest = tok
-	return node
-}
+	fn := newGvar(name, ty)
+	fn.isFunction = true
+	fn.parent = currentFn
 
-// funcall = ident "(" (assign ("," assign)*)? ")"
+	// Parse the body as a function of its own, and resume the
+	// enclosing function.
+	outerLocals, outerGotos, outerLabels := locals, gotos, labels
+	outerBrk, outerCont := brkLabel, contLabel
+	gotos, labels = nil, nil
+	brkLabel, contLabel = "", ""
+	currentFn = fn
+	*rest = funcBody(fn, tok)
+	currentFn = fn.parent
+	locals, gotos, labels = outerLocals, outerGotos, outerLabels
+	brkLabel, contLabel = outerBrk, outerCont
 
-func funcall(rest **Token, tok *Token) *Node {
-	start := tok
-	tok = tok.next.next
+	node := newNode(ND_CLOSURE, start)
+	node.funcname = fn.name
+	node.vr = fn
+	node.ty = ty
+	return node
+}
 
-	head := new(Node)
-	cur := head
+// Returns the variable `vr` as seen from the function `fn`. A local
+// variable of an enclosing function is captured by the function
+// literal through a pointer in the context of the closure, which is
+// loaded into a variable of the function literal when it starts.
 
-	for !equal(tok, ")") {
-		if cur != head {
-			tok = skip(tok, ",")
+func captureVar(vr *Obj, fn *Obj) *Obj {
+	if !vr.isLocal || vr.fn == fn {
+		return vr
+	}
+	outer := captureVar(vr, fn.parent)
+	for _, c := range fn.captures {
+		if c.outer == outer {
+			return c
 		}
-		cur.next = assign(&tok, tok)
-		cur = cur.next
 	}
+	outer.isBoxed = true
+	c := &Obj{name: vr.name, ty: vr.ty, isLocal: true, isBoxed: true, fn: fn, outer: outer}
+	fn.captures = append(fn.captures, c)
+	return c
+}
 
-	*rest = skip(tok, ")")
+// Returns the first token of the next top-level declaration. Only
+// braces are counted, so that a declaration with an unclosed
+// parenthesis does not hide the ones after it. A declaration does not
+// extend past the end of its file, which is followed by the next file.
 
-	node := newNode(ND_FUNCALL, start)
-	node.funcname = currentInput[start.loc : start.loc+start.len]
-	node.args = head.next
-	return node
+func skipDecl(tok *Token) *Token {
+	if tok.kind == TK_EOF {
+		return tok.next
+	}
+	depth := 0
+	for {
+		if equal(tok, "{") {
+			depth++
+		} else if equal(tok, "}") && depth > 0 {
+			depth--
+		}
+		next := tok.next
+		if next.kind == TK_EOF {
+			return next
+		}
+		if depth == 0 && (equal(tok, ";") || equal(tok, "}")) &&
+			(equal(next, "func") || equal(next, "var") || equal(next, "type") || equal(next, "const")) {
+			return next
+		}
+		tok = next
+	}
 }
 
-// primary = "(" expr ")" | ident func-args? | str | num
+// Declare types, constants and functions ahead of the rest of the
+// program so that they can be referred to before their definitions.
 
-func primary(rest **Token, tok *Token) *Node {
-	if equal(tok, "(") {
-		node := expr(&tok, tok.next)
-		*rest = skip(tok, ")")
-		return node
+func declareFunctions(tok *Token) {
+	var types []*Type
+	for t := tok; t != nil; t = skipDecl(t) {
+		if equal(t, "type") {
+			var rest *Token
+			scope = fileScope(t)
+			tryParse(func() { types = append(types, typeDecl(&rest, t)...) })
+		}
 	}
 
-	if tok.kind == TK_IDENT {
-		// Function call
-		if equal(tok.next, "(") {
-			return funcall(rest, tok)
+	for t := tok; t != nil; t = skipDecl(t) {
+		if equal(t, "const") {
+			var rest *Token
+			scope = fileScope(t)
+			tryParse(func() { constDecl(&rest, t) })
 		}
+	}
+	for _, ty := range types {
+		scope = fileScope(ty.typeName)
+		tryParse(func() { resolveType(ty) })
+	}
 
-		// Variable
-		vr := findVar(tok)
-		if vr == nil {
-			errorTok(tok, "undefined variable")
+	for t := tok; t != nil; t = skipDecl(t) {
+		if equal(t, "func") {
+			scope = fileScope(t)
+			tryParse(func() {
+				var rest *Token
+				ty, recv := funcDecl(&rest, t)
+				if recv != nil {
+					declareMethod(recv, ty)
+					return
+				}
+				if findVar(ty.name) != nil {
+					errorTok(ty.name, "%s redeclared", getIdent(ty.name))
+				}
+				fn := newGvar(getIdent(ty.name), ty)
+				fn.isFunction = true
+
+				// A function without a body is defined outside of
+				// the program, and has its own name.
+				if !equal(rest, ";") {
+					setSymbol(fn, ty.name)
+				}
+			})
 		}
-		*rest = tok.next
-		return newVarNode(vr, tok)
 	}
+}
 
-	if tok.kind == TK_STR {
-		vr := newStringLiteral(tok.str, tok.ty)
-		*rest = tok.next
-		return newVarNode(vr, tok)
-	}
+// Returns the scope of the package-level declarations in the file of
+// `tok`.
 
-	if tok.kind == TK_NUM {
-		node := newNum(tok.val, tok)
-		*rest = tok.next
-		return node
+func fileScope(tok *Token) *Scope {
+	if inRuntime(tok) {
+		return runtimeScope
 	}
+	return programScope
+}
 
-	errorTok(tok, "expected an expression")
-	return nil
+// Sets the assembly symbol of the package-level function, method or
+// variable `vr` declared at `tok`. The symbols of the program are
+// qualified by the package name, so that they do not clash with the
+// ones of the runtime and libc, except for the entry point main. The
+// ones not exported are local to the assembly file.
+
+func setSymbol(vr *Obj, tok *Token) {
+	if inRuntime(tok) || vr.name == "main" {
+		return
+	}
+	vr.name = "main." + vr.name
+	vr.isStatic = !isExported(getIdent(tok))
 }
 
 func createParamLvars(param *Type) {
 	if param != nil {
 		createParamLvars(param.next)
-		newLvar(getIdent(param.name), param)
+		name := ""
+		if param.name != nil {
+			name = getIdent(param.name)
+		}
+		vr := newLvar(name, param)
+		vr.isParam = true
 	}
 }
 
-// function = "func" ident type-suffix "{"
+// signature = ident func-signature
 
-func function(rest **Token, tok *Token) *Token {
-	if !equal(tok, "func") {
-		errorTok(tok, "unexpected declaration is found")
-	}
-	tok = tok.next
+func signature(rest **Token, tok *Token) *Type {
 	if tok.kind != TK_IDENT {
-		errorTok(tok, "expected a variable name")
+		errorTok(tok, "expected a function name")
 	}
+	name := tok
 
-	params := typeSuffix(rest, tok.next)
-	returnTy := declarator(rest, *rest)
-	ty := funcType(returnTy)
+	ty := funcSignature(rest, tok.next)
+	ty.name = name
+	return ty
+}
+
+// func-signature = func-params? result?
+// result         = func-params | declarator
+//
+// A result is a type, or a list of them in parentheses.
+
+func funcSignature(rest **Token, tok *Token) *Type {
+	var params *Type
+	if equal(tok, "(") {
+		params = funcParams(&tok, tok)
+	}
+
+	var results *Type
+	if equal(tok, "(") {
+		results = funcParams(&tok, tok)
+	} else if isTypename(tok) || equal(tok, "*") || equal(tok, "[") {
+		results = copyType(declarator(&tok, tok))
+	}
+
+	var ty *Type
+	if results == nil {
+		ty = funcType(tyVoid)
+	} else if results.next == nil {
+		ty = funcType(results)
+	} else {
+		ty = funcType(tupleType(results))
+	}
 	ty.params = params
-	ty.name = tok
-	locals = nil
-	fn := newGvar(getIdent(ty.name), ty)
-	fn.isFunction = true
-	enterScope()
-	createParamLvars(ty.params)
-	fn.params = locals
+	*rest = tok
+	return ty
+}
 
-	tok = skip(*rest, "{")
-	fn.body = componentStmt(&tok, tok)
-	fn.locals = locals
-	leaveScope()
-	return tok
+// Returns the types of the results of a function.
+
+func funcResults(ty *Type) *Type {
+	if ty.returnTy.kind == TY_TUPLE {
+		head := new(Type)
+		cur := head
+		for mem := ty.returnTy.members; mem != nil; mem = mem.next {
+			cur.next = copyType(mem.ty)
+			cur = cur.next
+			cur.name = mem.name
+		}
+		return head.next
+	}
+	if ty.returnTy.kind == TY_VOID {
+		return nil
+	}
+	return ty.returnTy
+}
+
+// Creates local variables which hold the results of a function
+// returning more than a scalar or having named results, and returns
+// statements zero-clearing them.
+
+func createResultLvars(fn *Obj) *Node {
+	returnTy := fn.ty.returnTy
+	if returnTy.kind == TY_VOID ||
+		(returnTy.kind != TY_TUPLE && returnTy.name == nil && !isAggregate(returnTy)) {
+		return nil
+	}
+
+	createParamLvars(funcResults(fn.ty))
+	fn.results = locals
+
+	// Results are zero-cleared when the function starts.
+	head := new(Node)
+	cur := head
+	vr := fn.results
+	for t := funcResults(fn.ty); t != nil; t = t.next {
+		cur.next = newUnary(ND_EXPR_STMT, newMemzero(vr, fn.ty.name), fn.ty.name)
+		cur = cur.next
+		vr = vr.next
+	}
+	return head.next
 }
 
 func storeIdentTemp(rest **Token, tok *Token) *Node {
@@ -758,7 +4633,7 @@ func storeIdentTemp(rest **Token, tok *Token) *Node {
 		vrs_cur.next = vrs
 		vrs_cur = vrs_cur.next
 		tok = tok.next
-		if isTypename(tok) || equal(tok, "*") || equal(tok, "[") {
+		if isTypename(tok) || equal(tok, "*") || equal(tok, "[") || equal(tok, "=") {
 			break
 		}
 		tok = skip(tok, ",")
@@ -767,59 +4642,143 @@ func storeIdentTemp(rest **Token, tok *Token) *Node {
 	return vrs_head.next
 }
 
+// Initializers of global variables are compiled into a function
+// which is called at the beginning of main.
+var initFn *Obj
+var initLocals *Obj
+var initStmts = new(Node)
+var initLast = initStmts
+
+// Number of function literals in the initializers
+var numInitFuncLits int
+
 func globalVariable(tok *Token) *Token {
 	vrs_head := storeIdentTemp(&tok, tok)
-	ty := declarator(&tok, tok)
+	var pending []*pendingDecl
+	for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
+		if isDeclared(vr_cur.tok) {
+			errorTok(vr_cur.tok, "%s redeclared", getIdent(vr_cur.tok))
+		}
+		pending = append(pending, addPending(vr_cur.tok))
+	}
+	var ty *Type
+	if !equal(tok, "=") {
+		ty = declarator(&tok, tok)
+		for _, pd := range pending {
+			pd.ty = ty
+		}
+	}
 	if consume(&tok, tok, "=") {
+		// Temporary variables created for the initializers are
+		// locals of the init function.
+		locals = initLocals
+		op := tok
+		unpack, values := unpackTuple(exprList(&tok, tok), countNodes(vrs_head), op)
+		if unpack != nil {
+			initLast.next = unpack
+			initLast = unpack
+		}
+		i := 0
 		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
-			vr := newGvar(getIdent(vr_cur.tok), ty)
-			lhs := newVarNode(vr, ty.name)
-			rhs := assign(&tok, tok)
-			head := new(Node)
-			cur := head
-			if rhs.ty != nil && rhs.ty.kind == TY_ARRAY {
-				i := 0
-				for cur_node := rhs; cur_node != nil; cur_node = cur_node.next {
-					tmp := newNum(i, tok)
-					addType(tmp)
-					new_lhs := newUnary(ND_DEREF, newAdd(lhs, tmp, tok), tok)
-					node := newBinary(ND_ASSIGN, new_lhs, rhs, tok)
-					i += 1
-					cur.next = newUnary(ND_EXPR_STMT, node, tok)
-					cur = cur.next
-					rhs = rhs.next
-				}
-				vr.init = head.next
-			} else {
-				node := newBinary(ND_ASSIGN, lhs, rhs, tok)
-				vr.init = node
-				consume(&tok, tok, ",")
+			rhs := values[i]
+			i++
+			vrTy := ty
+			if vrTy == nil {
+				vrTy = inferType(rhs)
 			}
+			vr := newGvar(getIdent(vr_cur.tok), vrTy)
+			setSymbol(vr, vr_cur.tok)
+			node := newUnary(ND_EXPR_STMT, newInit(newVarNode(vr, vr_cur.tok), rhs, op), op)
+			addType(node)
+			initLast.next = node
+			initLast = node
 		}
+		initLocals = locals
 	} else {
 		for vr_cur := vrs_head; vr_cur != nil; vr_cur = vr_cur.next {
-			newGvar(getIdent(vr_cur.tok), ty)
+			vr := newGvar(getIdent(vr_cur.tok), ty)
+			setSymbol(vr, vr_cur.tok)
 		}
 	}
 	tok = skip(tok, ";")
 	return tok
 }
 
-// program = (function-definition | global-variable)*
+func createInitFunction() {
+	if initStmts.next == nil {
+		return
+	}
+	fn := newGvar("main.init", funcType(nil))
+	fn.isFunction = true
+	fn.isDefinition = true
+	fn.body = newNode(ND_BLOCK, initStmts.next.tok)
+	fn.body.body = initStmts.next
+	fn.locals = initLocals
+	initFn = fn
+}
+
+// program = (function-definition | type-decl | global-variable)*
 
 func parse(tok *Token) *Obj {
 	globals = nil
 
-	for tok.kind != TK_EOF {
+	// Predeclared types
+	pushScope("int8", nil).typeDef = tyInt8
+	pushScope("int16", nil).typeDef = tyInt16
+	pushScope("int32", nil).typeDef = tyInt32
+	pushScope("int64", nil).typeDef = tyInt64
+	pushScope("uint8", nil).typeDef = tyUint8
+	pushScope("uint16", nil).typeDef = tyUint16
+	pushScope("uint32", nil).typeDef = tyUint32
+	pushScope("uint64", nil).typeDef = tyUint64
+	pushScope("uint", nil).typeDef = tyUint
+	pushScope("uintptr", nil).typeDef = tyUintptr
+	pushScope("float32", nil).typeDef = tyFloat32
+	pushScope("float64", nil).typeDef = tyFloat64
+	pushScope("bool", nil).typeDef = tyBool
+
+	// Predeclared constants
+	pushScope("true", nil).con = newBool(true, tok)
+	pushScope("false", nil).con = newBool(false, tok)
+	pushScope("byte", nil).typeDef = tyUint8
+	pushScope("rune", nil).typeDef = tyInt32
+
+	programScope = &Scope{next: scope}
+	runtimeScope = &Scope{next: scope}
+	declareFunctions(tok)
+
+	for tok != nil {
+		// Empty declaration, such as the semicolon inserted after
+		// a function body, or the end of a file
+		if equal(tok, ";") || tok.kind == TK_EOF {
+			tok = tok.next
+			continue
+		}
+
+		// A declaration with an error is skipped.
+		start := tok
+		scope = fileScope(tok)
+
 		// Function
 		if equal(tok, "func") {
-			tok = function(&tok, tok)
+			if !tryParse(func() { tok = function(&tok, tok) }) {
+				tok = skipDecl(start)
+			}
+			continue
+		}
+
+		// Type or constant
+		if equal(tok, "type") || equal(tok, "const") {
+			tok = skipDecl(tok)
 			continue
 		}
 
 		// Global variable
-		tok = globalVariable(tok)
+		if !tryParse(func() { tok = globalVariable(tok) }) {
+			tok = skipDecl(start)
+		}
 	}
 
+	createInitFunction()
 	return globals
 }
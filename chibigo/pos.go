@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//
+// Source positions
+//
+
+// A position in the source files. The contents of the files are
+// concatenated in `currentInput`, and a Pos is an offset into it.
+
+type Pos int
+
+// A source file added to the file set. `lines` holds the offsets in
+// the file of the first bytes of its lines.
+
+type File struct {
+	name  string
+	base  Pos
+	size  int
+	lines []int
+}
+
+// The set of source files, which resolves a Pos to a file name, a line
+// and a column. The lines of each file are recorded once when it is
+// added.
+
+type FileSet struct {
+	files []*File
+}
+
+var fset = new(FileSet)
+
+// A resolved position. The line and the column in bytes start at 1.
+
+type Position struct {
+	filename string
+	line     int
+	column   int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.filename, p.line, p.column)
+}
+
+// Adds a file with the contents `src` to the file set, and returns it.
+// The contents are appended to `currentInput`.
+
+func (s *FileSet) addFile(name string, src string) *File {
+	f := &File{name: name, base: Pos(len(currentInput)), size: len(src), lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' && i+1 < len(src) {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	s.files = append(s.files, f)
+	currentInput += src
+	return f
+}
+
+// Returns the file containing `pos`. The end of a file belongs to it.
+
+func (s *FileSet) file(pos Pos) *File {
+	for _, f := range s.files {
+		if f.base <= pos && int(pos-f.base) <= f.size {
+			return f
+		}
+	}
+	panic("invalid position")
+}
+
+// Returns the file, the line and the column of `pos`. The end of a
+// file is just after the end of its last line.
+
+func (s *FileSet) position(pos Pos) Position {
+	f := s.file(pos)
+	line := f.line(pos)
+	column := int(pos-f.base) - f.lines[line-1] + 1
+	if n := len(f.lineText(line)) + 1; column > n {
+		column = n
+	}
+	return Position{f.name, line, column}
+}
+
+// Returns the number of the line containing `pos`.
+
+func (f *File) line(pos Pos) int {
+	offset := int(pos - f.base)
+	return sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+}
+
+// Returns the text of the line `n` without the newline.
+
+func (f *File) lineText(n int) string {
+	start := int(f.base) + f.lines[n-1]
+	end := int(f.base) + f.size
+	if n < len(f.lines) {
+		end = int(f.base) + f.lines[n]
+	}
+	text := currentInput[start:end]
+	if len(text) > 0 && text[len(text)-1] == '\n' {
+		text = text[:len(text)-1]
+	}
+	return text
+}
@@ -0,0 +1,824 @@
+package main
+
+//
+// Runtime
+//
+
+// The runtime is written in chibigo and compiled with every program.
+// The compiler calls the functions whose names start with "runtime_"
+// to implement the language. A slice is passed to and returned from
+// them as runtime_slice, which has the same layout as any slice, and
+// a string as runtime_string. A map is a pointer to runtime_hmap, and
+// a channel to runtime_hchan. Keys and values are passed by address.
+
+var runtimeSource = `
+func calloc(n int, size int) *byte;
+func memmove(dst *byte, src *byte, n int) *byte;
+func write(fd int, buf *byte, n int) int;
+func memset(p *byte, c int, n int) *byte;
+func exit(code int);
+
+type runtime_slice struct {
+	ptr *byte;
+	len int;
+	cap int;
+};
+
+type runtime_string struct {
+	ptr *byte;
+	len int;
+};
+
+func runtime_printstring(s runtime_string) {
+	write(2, s.ptr, s.len);
+}
+
+func runtime_printuint(n uint64) {
+	var buf [20]byte;
+	i := len(buf);
+	for {
+		i--;
+		buf[i] = byte(n%10) + 48;
+		n /= 10;
+		if n == 0 {
+			break;
+		}
+	}
+	write(2, &buf[i], len(buf)-i);
+}
+
+func runtime_printint(n int64) {
+	if n < 0 {
+		runtime_printstring("-");
+		runtime_printuint(uint64(-n));
+		return;
+	}
+	runtime_printuint(uint64(n));
+}
+
+func runtime_printhex(n uint64) {
+	var buf [16]byte;
+	i := len(buf);
+	for {
+		i--;
+		buf[i] = "0123456789abcdef"[n%16];
+		n /= 16;
+		if n == 0 {
+			break;
+		}
+	}
+	runtime_printstring("0x");
+	write(2, &buf[i], len(buf)-i);
+}
+
+// An interface value points to an itab, whose first word points to
+// the type descriptor of the dynamic type. The data word points to the
+// value, or is the value if it is a pointer. Values of a comparable
+// type are hashed and compared by the functions of the type, which
+// take them by address.
+
+type runtime_type struct {
+	itabs *byte;
+	name string;
+	hash func(p *byte, h int) int;
+	equal func(p *byte, q *byte) bool;
+	direct bool;
+};
+
+type runtime_itab struct {
+	typ *runtime_type;
+};
+
+type runtime_iface struct {
+	tab *runtime_itab;
+	data *byte;
+};
+
+type runtime_error interface {
+	Error() string;
+};
+
+type runtime_stringer interface {
+	String() string;
+};
+
+// The value of a panic raised by the runtime.
+
+type runtime_Error struct {
+	msg string;
+};
+
+func (e runtime_Error) Error() string {
+	return "runtime error: " + e.msg;
+}
+
+func runtime_throw(msg string) {
+	runtime_gopanic(runtime_Error{msg});
+}
+
+// The value of a panic raised by the runtime whose message is not
+// prefixed with "runtime error: ".
+
+type runtime_plainError struct {
+	msg string;
+};
+
+func (e runtime_plainError) Error() string {
+	return e.msg;
+}
+
+// Exits after printing an error which cannot be recovered.
+
+func runtime_fatal(msg string) {
+	runtime_printstring("fatal error: " + msg + "\n");
+	exit(2);
+}
+
+// Calls deferred by "defer" statements form a stack. Each of them
+// records the frame of the function which deferred it, and the code
+// resuming that function when a panic is recovered.
+
+type runtime_defer struct {
+	link *runtime_defer;
+	fn func();
+	frame *byte;
+	pc *byte;
+};
+
+type runtime_panic struct {
+	link *runtime_panic;
+	arg interface{};
+	recovered bool;
+	frame *byte;
+};
+
+var runtime_defers *runtime_defer;
+var runtime_panicking *runtime_panic;
+
+func runtime_resume(frame *byte, pc *byte);
+func runtime_getframe() *byte;
+
+func runtime_deferproc(fn func(), frame *byte, pc *byte) {
+	runtime_defers = &runtime_defer{runtime_defers, fn, frame, pc};
+}
+
+// Runs the calls deferred by the function whose frame is frame.
+
+func runtime_deferreturn(frame *byte) {
+	for runtime_defers != nil && runtime_defers.frame == frame {
+		d := runtime_defers;
+		runtime_defers = d.link;
+		d.fn();
+	}
+}
+
+// Runs the deferred calls until one of them recovers the panic, in
+// which case the function which deferred it returns normally.
+// Otherwise, the program exits after printing the value of the panic.
+
+func runtime_gopanic(arg interface{}) {
+	p := &runtime_panic{runtime_panicking, arg, false, runtime_getframe()};
+	runtime_panicking = p;
+	for runtime_defers != nil {
+		d := runtime_defers;
+		runtime_defers = d.link;
+		d.fn();
+		if p.recovered {
+			runtime_panicking = p.link;
+			runtime_resume(d.frame, d.pc);
+		}
+	}
+	runtime_printpanics(p);
+	exit(2);
+}
+
+// Stops the panic if called directly by the deferred function that
+// runtime_gopanic is running. Each frame starts with the frame of its
+// caller, so the frame of the caller of recover must be followed by
+// the frame of the wrapper of the deferred call, and then by the frame
+// of runtime_gopanic.
+
+func runtime_gorecover() interface{} {
+	p := runtime_panicking;
+	if p == nil || p.recovered {
+		return nil;
+	}
+	var frame **byte = runtime_getframe();
+	var caller **byte = *frame;
+	var wrapper **byte = *caller;
+	if *wrapper != p.frame {
+		return nil;
+	}
+	p.recovered = true;
+	return p.arg;
+}
+
+// Prints the panics in the order they were raised.
+
+func runtime_printpanics(p *runtime_panic) {
+	if p.link != nil {
+		runtime_printpanics(p.link);
+		runtime_printstring("\t");
+	}
+	runtime_printstring("panic: ");
+	runtime_printpanicval(p.arg);
+	runtime_printstring("\n");
+}
+
+func runtime_printpanicval(arg interface{}) {
+	switch v := arg.(type) {
+	case nil:
+		runtime_printstring("nil");
+	case runtime_error:
+		runtime_printstring(v.Error());
+	case runtime_stringer:
+		runtime_printstring(v.String());
+	case string:
+		runtime_printstring(v);
+	case bool:
+		if v {
+			runtime_printstring("true");
+		} else {
+			runtime_printstring("false");
+		}
+	case int:
+		runtime_printint(int64(v));
+	case int8:
+		runtime_printint(int64(v));
+	case int16:
+		runtime_printint(int64(v));
+	case int32:
+		runtime_printint(int64(v));
+	case int64:
+		runtime_printint(v);
+	case uint:
+		runtime_printuint(uint64(v));
+	case uint8:
+		runtime_printuint(uint64(v));
+	case uint16:
+		runtime_printuint(uint64(v));
+	case uint32:
+		runtime_printuint(uint64(v));
+	case uint64:
+		runtime_printuint(v);
+	case uintptr:
+		runtime_printuint(uint64(v));
+	default:
+		var e *runtime_iface = &arg;
+		runtime_printstring("(" + e.tab.typ.name + ") ");
+		var data *uint64 = &e.data;
+		runtime_printhex(*data);
+	}
+}
+
+// Returns the address of the value of a non-nil interface value.
+
+func runtime_ifacedata(x *runtime_iface) *byte {
+	if x.tab.typ.direct {
+		return &x.data;
+	}
+	return x.data;
+}
+
+// Returns true if both interface values are nil, or if they have the
+// same dynamic type and their values are equal.
+
+func runtime_ifaceeq(a interface{}, b interface{}) bool {
+	var x *runtime_iface = &a;
+	var y *runtime_iface = &b;
+	if x.tab == nil || y.tab == nil {
+		return x.tab == y.tab;
+	}
+	t := x.tab.typ;
+	if t != y.tab.typ {
+		return false;
+	}
+	if t.equal == nil {
+		runtime_throw("comparing uncomparable type " + t.name);
+	}
+	return t.equal(runtime_ifacedata(x), runtime_ifacedata(y));
+}
+
+// Continues the hash h with the value of an interface value.
+
+func runtime_ifacehash(h int, a interface{}) int {
+	var x *runtime_iface = &a;
+	if x.tab == nil {
+		return h;
+	}
+	t := x.tab.typ;
+	if t.hash == nil {
+		runtime_throw("hash of unhashable type " + t.name);
+	}
+	return t.hash(runtime_ifacedata(x), h);
+}
+
+func runtime_checkIndex(i int, len int) int {
+	if i < 0 || i >= len {
+		runtime_throw("index out of range");
+	}
+	return i;
+}
+
+func runtime_makeslice(len int, cap int, size int) runtime_slice {
+	if len < 0 {
+		runtime_throw("makeslice: len out of range");
+	}
+	if cap < len {
+		runtime_throw("makeslice: cap out of range");
+	}
+	return runtime_slice{calloc(cap, size), len, cap};
+}
+
+func runtime_sliceExpr(s runtime_slice, lo int, hi int, max int, size int) runtime_slice {
+	if lo < 0 || hi < lo || max < hi || s.cap < max {
+		runtime_throw("slice bounds out of range");
+	}
+	return runtime_slice{s.ptr + lo*size, hi - lo, max - lo};
+}
+
+// Extends the length of a slice by n, reallocating the array if the
+// capacity is not large enough.
+
+func runtime_growslice(s runtime_slice, n int, size int) runtime_slice {
+	len := s.len + n;
+	if len > s.cap {
+		cap := s.cap * 2;
+		if cap < len {
+			cap = len;
+		}
+		ptr := calloc(cap, size);
+		memmove(ptr, s.ptr, s.len*size);
+		s.ptr = ptr;
+		s.cap = cap;
+	}
+	s.len = len;
+	return s;
+}
+
+func runtime_slicecopy(dst runtime_slice, src runtime_slice, size int) int {
+	n := dst.len;
+	if src.len < n {
+		n = src.len;
+	}
+	memmove(dst.ptr, src.ptr, n*size);
+	return n;
+}
+
+// A map is a hash table with open addressing. Each entry consists of
+// a key followed by a value, and its state is kept separately:
+// 0 for an empty entry, 1 for a used one and 2 for a deleted one.
+// The keys are hashed and compared by the functions of the key type.
+
+type runtime_hmap struct {
+	count int;
+	used int;
+	nbuckets int;
+	keySize int;
+	hash func(p *byte, h int) int;
+	equal func(p *byte, q *byte) bool;
+	valSize int;
+	valOffset int;
+	entrySize int;
+	states *int;
+	entries *byte;
+};
+
+var runtime_zero *byte;
+var runtime_zeroSize int;
+
+// Returns a pointer to n zero bytes, which is the value of a key
+// not in a map.
+
+func runtime_zeroval(n int) *byte {
+	if n > runtime_zeroSize {
+		runtime_zero = calloc(n, 1);
+		runtime_zeroSize = n;
+	}
+	return runtime_zero;
+}
+
+// FNV-1a, continuing the hash h
+func runtime_hash(h int, key *byte, n int) int {
+	for i := 0; i < n; i++ {
+		h = (h ^ key[i]) * 1099511628211;
+	}
+	return h;
+}
+
+// Continues the hash h with a floating-point number. Both zeros are
+// equal, so they are hashed alike. NaN is not equal to any number, so
+// a NaN key is never found.
+
+func runtime_f64hash(h int, f float64) int {
+	if f == 0 {
+		f = 0;
+	}
+	return runtime_hash(h, &f, 8);
+}
+
+func runtime_strhash(h int, s runtime_string) int {
+	return runtime_hash(h, s.ptr, s.len);
+}
+
+// Returns the hash of a key.
+
+func runtime_keyhash(m *runtime_hmap, key *byte) int {
+	return m.hash(key, -3750763034362895579);
+}
+
+func runtime_memequal(a *byte, b *byte, n int) bool {
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return false;
+		}
+	}
+	return true;
+}
+
+func runtime_mapalloc(m *runtime_hmap, n int) {
+	m.count = 0;
+	m.used = 0;
+	m.nbuckets = n;
+	m.states = calloc(n, 8);
+	m.entries = calloc(n, m.entrySize);
+}
+
+func runtime_makemap(hint int, keySize int, hash func(p *byte, h int) int,
+	equal func(p *byte, q *byte) bool, valSize int) *runtime_hmap {
+	var m *runtime_hmap = calloc(1, 88);
+	m.keySize = keySize;
+	m.hash = hash;
+	m.equal = equal;
+	m.valSize = valSize;
+	m.valOffset = (keySize + 7) &^ 7;
+	m.entrySize = m.valOffset + ((valSize + 7) &^ 7);
+	n := 8;
+	for n*3 < hint*4 {
+		n = n * 2;
+	}
+	runtime_mapalloc(m, n);
+	return m;
+}
+
+// Returns the index of the entry of a key, or -1 if there is none.
+
+func runtime_mapfind(m *runtime_hmap, key *byte) int {
+	if m == nil || m.count == 0 {
+		return -1;
+	}
+	mask := m.nbuckets - 1;
+	i := runtime_keyhash(m, key) & mask;
+	for m.states[i] != 0 {
+		if m.states[i] == 1 && m.equal(m.entries+i*m.entrySize, key) {
+			return i;
+		}
+		i = (i + 1) & mask;
+	}
+	return -1;
+}
+
+// Rehashes the entries into a table twice as large.
+
+func runtime_mapgrow(m *runtime_hmap) {
+	n := m.nbuckets;
+	states := m.states;
+	entries := m.entries;
+	runtime_mapalloc(m, n*2);
+	for i := 0; i < n; i++ {
+		if states[i] == 1 {
+			e := entries + i*m.entrySize;
+			memmove(runtime_mapassign(m, e, m.valSize), e+m.valOffset, m.valSize);
+		}
+	}
+}
+
+// Returns a pointer to the value of a key, which is zero if the
+// key is not in the map.
+
+func runtime_mapaccess1(m *runtime_hmap, key *byte, valSize int) *byte {
+	i := runtime_mapfind(m, key);
+	if i < 0 {
+		return runtime_zeroval(valSize);
+	}
+	return m.entries + i*m.entrySize + m.valOffset;
+}
+
+// Copies the value of a key to dst and returns true if the key is in
+// the map. Otherwise zero-clears dst and returns false.
+
+func runtime_mapaccess2(m *runtime_hmap, key *byte, dst *byte, valSize int) bool {
+	i := runtime_mapfind(m, key);
+	if i < 0 {
+		memset(dst, 0, valSize);
+		return false;
+	}
+	memmove(dst, m.entries+i*m.entrySize+m.valOffset, valSize);
+	return true;
+}
+
+// Returns a pointer to the value of a key to be assigned, adding the
+// key with a zero value if it is not in the map.
+
+func runtime_mapassign(m *runtime_hmap, key *byte, valSize int) *byte {
+	if m == nil {
+		runtime_throw("assignment to entry in nil map");
+	}
+	i := runtime_mapfind(m, key);
+	if i >= 0 {
+		return m.entries + i*m.entrySize + m.valOffset;
+	}
+	if (m.used+1)*4 > m.nbuckets*3 {
+		runtime_mapgrow(m);
+	}
+	mask := m.nbuckets - 1;
+	i = runtime_keyhash(m, key) & mask;
+	for m.states[i] == 1 {
+		i = (i + 1) & mask;
+	}
+	if m.states[i] == 0 {
+		m.used++;
+	}
+	m.states[i] = 1;
+	m.count++;
+	e := m.entries + i*m.entrySize;
+	memmove(e, key, m.keySize);
+	memset(e+m.valOffset, 0, m.valSize);
+	return e + m.valOffset;
+}
+
+func runtime_mapdelete(m *runtime_hmap, key *byte) {
+	i := runtime_mapfind(m, key);
+	if i >= 0 {
+		m.states[i] = 2;
+		m.count--;
+	}
+}
+
+func runtime_maplen(m *runtime_hmap) int {
+	if m == nil {
+		return 0;
+	}
+	return m.count;
+}
+
+// Returns the index of the first used entry at or after i, or -1 if
+// there is none. A range loop over a map visits the entries in the
+// order of their indices.
+
+func runtime_mapnext(m *runtime_hmap, i int) int {
+	if m == nil {
+		return -1;
+	}
+	for ; i < m.nbuckets; i++ {
+		if m.states[i] == 1 {
+			return i;
+		}
+	}
+	return -1;
+}
+
+func runtime_mapkey(m *runtime_hmap, i int) *byte {
+	return m.entries + i*m.entrySize;
+}
+
+func runtime_mapval(m *runtime_hmap, i int) *byte {
+	return m.entries + i*m.entrySize + m.valOffset;
+}
+
+// A channel is a ring buffer of cap elements, of which count elements
+// starting at recvx are buffered. There are no other goroutines to
+// communicate with, so an operation which would block never completes.
+
+type runtime_hchan struct {
+	buf *byte;
+	elemSize int;
+	cap int;
+	count int;
+	recvx int;
+	closed bool;
+};
+
+func runtime_makechan(elemSize int, size int) *runtime_hchan {
+	if size < 0 {
+		runtime_gopanic(runtime_plainError{"makechan: size out of range"});
+	}
+	return &runtime_hchan{calloc(size, elemSize), elemSize, size, 0, 0, false};
+}
+
+func runtime_block() {
+	runtime_fatal("all goroutines are asleep - deadlock!");
+}
+
+func runtime_chansend(c *runtime_hchan, elem *byte) {
+	if c == nil {
+		runtime_block();
+	}
+	if c.closed {
+		runtime_gopanic(runtime_plainError{"send on closed channel"});
+	}
+	if c.count == c.cap {
+		runtime_block();
+	}
+	i := (c.recvx + c.count) % c.cap;
+	memmove(c.buf+i*c.elemSize, elem, c.elemSize);
+	c.count++;
+}
+
+// Copies the next value to dst and returns true. If the channel is
+// closed and empty, zero-clears dst and returns false.
+
+func runtime_chanrecv(c *runtime_hchan, dst *byte) bool {
+	if c == nil {
+		runtime_block();
+	}
+	if c.count == 0 {
+		if !c.closed {
+			runtime_block();
+		}
+		memset(dst, 0, c.elemSize);
+		return false;
+	}
+	memmove(dst, c.buf+c.recvx*c.elemSize, c.elemSize);
+	c.recvx = (c.recvx + 1) % c.cap;
+	c.count--;
+	return true;
+}
+
+func runtime_closechan(c *runtime_hchan) {
+	if c == nil {
+		runtime_gopanic(runtime_plainError{"close of nil channel"});
+	}
+	if c.closed {
+		runtime_gopanic(runtime_plainError{"close of closed channel"});
+	}
+	c.closed = true;
+}
+
+func runtime_chanlen(c *runtime_hchan) int {
+	if c == nil {
+		return 0;
+	}
+	return c.count;
+}
+
+func runtime_chancap(c *runtime_hchan) int {
+	if c == nil {
+		return 0;
+	}
+	return c.cap;
+}
+
+func runtime_concatstrings(a runtime_string, b runtime_string) runtime_string {
+	if a.len == 0 {
+		return b;
+	}
+	if b.len == 0 {
+		return a;
+	}
+	p := calloc(a.len+b.len, 1);
+	memmove(p, a.ptr, a.len);
+	memmove(p+a.len, b.ptr, b.len);
+	return runtime_string{p, a.len + b.len};
+}
+
+// Returns a negative number, zero or a positive number if a is less
+// than, equal to or greater than b.
+
+func runtime_cmpstring(a runtime_string, b runtime_string) int {
+	for i := 0; i < a.len && i < b.len; i++ {
+		x := int(a.ptr[i]);
+		y := int(b.ptr[i]);
+		if x != y {
+			return x - y;
+		}
+	}
+	return a.len - b.len;
+}
+
+func runtime_slicestring(s runtime_string, lo int, hi int) runtime_string {
+	if lo < 0 || hi < lo || s.len < hi {
+		runtime_throw("slice bounds out of range");
+	}
+	return runtime_string{s.ptr + lo, hi - lo};
+}
+
+func runtime_slicebytetostring(b runtime_slice) runtime_string {
+	p := calloc(b.len, 1);
+	memmove(p, b.ptr, b.len);
+	return runtime_string{p, b.len};
+}
+
+func runtime_stringtoslicebyte(s runtime_string) runtime_slice {
+	b := runtime_makeslice(s.len, s.len, 1);
+	memmove(b.ptr, s.ptr, s.len);
+	return b;
+}
+
+// Decodes the UTF-8 sequence at s[i], and returns the rune and the
+// index of the next one. An invalid sequence is decoded to U+FFFD
+// and its first byte is skipped.
+
+func runtime_decoderune(s runtime_string, i int) (rune, int) {
+	c := rune(s.ptr[i]);
+	if c < 128 {
+		return c, i + 1;
+	}
+	n := 0;
+	var r rune;
+	var min rune;
+	if c >= 192 && c < 224 {
+		n = 1;
+		r = c & 31;
+		min = 128;
+	}
+	if c >= 224 && c < 240 {
+		n = 2;
+		r = c & 15;
+		min = 2048;
+	}
+	if c >= 240 && c < 248 {
+		n = 3;
+		r = c & 7;
+		min = 65536;
+	}
+	if n == 0 || i+n >= s.len {
+		return 65533, i + 1;
+	}
+	for j := 1; j <= n; j++ {
+		b := rune(s.ptr[i+j]);
+		if b < 128 || b >= 192 {
+			return 65533, i + 1;
+		}
+		r = r<<6 | b&63;
+	}
+	if r < min || r > 1114111 || r >= 55296 && r <= 57343 {
+		return 65533, i + 1;
+	}
+	return r, i + n + 1;
+}
+
+// Encodes a rune in UTF-8 to p, which may be nil, and returns the
+// number of bytes. An invalid rune is encoded as U+FFFD.
+
+func runtime_encoderune(p *byte, r rune) int {
+	if r < 0 || r > 1114111 || r >= 55296 && r <= 57343 {
+		r = 65533;
+	}
+	if r < 128 {
+		if p != nil {
+			p[0] = r;
+		}
+		return 1;
+	}
+	n := 4;
+	if r < 2048 {
+		n = 2;
+	} else if r < 65536 {
+		n = 3;
+	}
+	if p != nil {
+		for i := n - 1; i > 0; i-- {
+			p[i] = 128 | r&63;
+			r = r >> 6;
+		}
+		p[0] = byte(3840>>n) | byte(r);
+	}
+	return n;
+}
+
+func runtime_intstring(r rune) runtime_string {
+	n := runtime_encoderune(nil, r);
+	p := calloc(n, 1);
+	runtime_encoderune(p, r);
+	return runtime_string{p, n};
+}
+
+func runtime_stringtoslicerune(s runtime_string) runtime_slice {
+	n := 0;
+	for i := 0; i < s.len; n++ {
+		_, i = runtime_decoderune(s, i);
+	}
+	b := runtime_makeslice(n, n, 4);
+	var p *rune = b.ptr;
+	j := 0;
+	for i := 0; i < s.len; j++ {
+		p[j], i = runtime_decoderune(s, i);
+	}
+	return b;
+}
+
+func runtime_slicerunetostring(b runtime_slice) runtime_string {
+	var p *rune = b.ptr;
+	n := 0;
+	for i := 0; i < b.len; i++ {
+		n += runtime_encoderune(nil, p[i]);
+	}
+	s := calloc(n, 1);
+	n = 0;
+	for i := 0; i < b.len; i++ {
+		n += runtime_encoderune(s+n, p[i]);
+	}
+	return runtime_string{s, n};
+}
+`
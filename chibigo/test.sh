@@ -14,7 +14,7 @@ assert() {
   input="$2"
 
   echo "$input" | ./chibigo - > tmp.s || exit
-  cc -o tmp tmp.s tmp2.o
+  cc -o tmp tmp.s tmp2.o -lm
   ./tmp
   actual="$?"
 
@@ -26,6 +26,61 @@ assert() {
   fi
 }
 
+# Checks that the compilation of the input fails, and that the errors
+# are the expected lines of the form "file:line:col: message".
+assert_error() {
+  input="$1"
+  expected="$2"
+
+  echo "$input" | ./chibigo - > tmp.s 2> tmp.err
+  status="$?"
+  if [ "$status" != 1 ]; then
+    echo "$input => exit status 1 expected, but got $status"
+    exit 1
+  fi
+
+  # Each error is printed as the position and the source line, followed
+  # by the caret and the message.
+  actual=$(awk 'p { sub(/^[ \t]*\^ /, ""); print p " " $0; p = ""; next }
+                /^-:[0-9]+:[0-9]+: / { p = $1; next }
+                { print }' tmp.err)
+  if [ "$actual" = "$expected" ]; then
+    echo "$input => $actual"
+  else
+    echo "$input => $expected expected, but got $actual"
+    exit 1
+  fi
+}
+
+# Checks the line with the caret under the first error.
+assert_caret() {
+  input="$1"
+  expected="$2"
+
+  echo "$input" | ./chibigo - > tmp.s 2> tmp.err
+  actual=$(sed -n 2p tmp.err)
+  if [ "$actual" = "$expected" ]; then
+    echo "$input => $actual"
+  else
+    echo "$input => '$expected' expected, but got '$actual'"
+    exit 1
+  fi
+}
+
+# Checks that the assembly has the line.
+assert_asm() {
+  input="$1"
+  expected="$2"
+
+  echo "$input" | ./chibigo - > tmp.s || exit
+  if grep -qxF -- "$expected" tmp.s; then
+    echo "$input => $expected"
+  else
+    echo "$input => '$expected' expected in the assembly"
+    exit 1
+  fi
+}
+
 assert 0 'func main() int { return 0; }'
 assert 42 'func main() int { return 42; }'
 assert 21 'func main() int { return 5+20-4; }'
@@ -37,24 +92,24 @@ assert 10 'func main() int { return -10+20; }'
 assert 10 'func main() int { return - -10; }'
 assert 10 'func main() int { return - - +10; }'
 
-assert 0 'func main() int { return 0==1; }'
-assert 1 'func main() int { return 42==42; }'
-assert 1 'func main() int { return 0!=1; }'
-assert 0 'func main() int { return 42!=42; }'
-
-assert 1 'func main() int { return 0<1; }'
-assert 0 'func main() int { return 1<1; }'
-assert 0 'func main() int { return 2<1; }'
-assert 1 'func main() int { return 0<=1; }'
-assert 1 'func main() int { return 1<=1; }'
-assert 0 'func main() int { return 2<=1; }'
-
-assert 1 'func main() int { return 1>0; }'
-assert 0 'func main() int { return 1>1; }'
-assert 0 'func main() int { return 1>2; }'
-assert 1 'func main() int { return 1>=0; }'
-assert 1 'func main() int { return 1>=1; }'
-assert 0 'func main() int { return 1>=2; }'
+assert 0 'func main() int { if 0==1 { return 1 }; return 0; }'
+assert 1 'func main() int { if 42==42 { return 1 }; return 0; }'
+assert 1 'func main() int { if 0!=1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 42!=42 { return 1 }; return 0; }'
+
+assert 1 'func main() int { if 0<1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 1<1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 2<1 { return 1 }; return 0; }'
+assert 1 'func main() int { if 0<=1 { return 1 }; return 0; }'
+assert 1 'func main() int { if 1<=1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 2<=1 { return 1 }; return 0; }'
+
+assert 1 'func main() int { if 1>0 { return 1 }; return 0; }'
+assert 0 'func main() int { if 1>1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 1>2 { return 1 }; return 0; }'
+assert 1 'func main() int { if 1>=0 { return 1 }; return 0; }'
+assert 1 'func main() int { if 1>=1 { return 1 }; return 0; }'
+assert 0 'func main() int { if 1>=2 { return 1 }; return 0; }'
 
 assert 3 'func main() int { var a int; a=3; return a; }'
 assert 3 'func main() int { var a int=3; return a; }'
@@ -126,14 +181,14 @@ assert 1 'func main() char { var x char=1; return x; }'
 assert 1 'func main() char { var x char=1; var y char=2; return x; }'
 assert 2 'func main() char { var x char=1; var y char=2; return y; }'
 
-assert 1 'func main() char { return subChar(7, 3, 3); } func subChar(a char, b char, c char) int { return a-b-c; }'
+assert 1 'func main() int { return subChar(7, 3, 3); } func subChar(a char, b char, c char) int { return int(a-b-c); }'
 
-assert 0 'func main() int { return ""[0]; }'
+assert 2 'func main() int { return int(""[0]); }'
 
-assert 97 'func main() int { return "abc"[0]; }'
-assert 98 'func main() int { return "abc"[1]; }'
-assert 99 'func main() int { return "abc"[2]; }'
-assert 0 'func main() int { return "abc"[3]; }'
+assert 97 'func main() int { return int("abc"[0]); }'
+assert 98 'func main() int { return int("abc"[1]); }'
+assert 99 'func main() int { return int("abc"[2]); }'
+assert 2 'func main() int { return int("abc"[3]); }'
 
 assert 2 'func main() int { /* return 1; */ return 2; }'
 assert 2 'func main() int { // return 1;
@@ -149,10 +204,10 @@ assert 2 'var x int = 1; func main() int { {x = 2;} return x;}'
 assert 2 'var x [3]int; func main() int { x[1] = 2; return x[1];}'
 assert 3 'var x, y int = 1, 3; func main() int { x=2; return y;}'
 
-assert 97 'var x [3]char = "abc"; func main char { return x[0]; }'
-assert 98 'var x [3]char = "abc"; func main char { return x[1]; }'
-assert 99 'var x [3]char = "abc"; func main char { return x[2]; }'
-assert 97 'var x [3]char = "abc"; func main char { x[2] = "a"; return x[2]; }'
+assert 97 "var x [3]char = [3]char{'a', 'b', 'c'}; func main char { return x[0]; }"
+assert 98 "var x [3]char = [3]char{'a', 'b', 'c'}; func main char { return x[1]; }"
+assert 99 "var x [3]char = [3]char{'a', 'b', 'c'}; func main char { return x[2]; }"
+assert 97 "var x [3]char = [3]char{'a', 'b', 'c'}; func main char { x[2] = 'a'; return x[2]; }"
 
 assert 1 'func main() int { var x [2]int = [2]int{0, 1}; return x[1]; }'
 assert 1 'func main() int { var x [4]int = [4]int{1, 1, 2, 3}; return x[1]; }'
@@ -160,9 +215,9 @@ assert 1 'func main() int { var x [4]int = [4]int{1, 1, 2, 3}; return x[1]; }'
 assert 2 'func main() int { var x [4]int = [4]int{1, 1, 2, 3}; x[1] = 2; return x[1]; }'
 assert 1 'func main() int { var x [4]int = [4]int{1, 1, 2, 3}; x[2] = 3; return x[1]; }'
 
-assert 97 'func main() char { var x [3]char = "abc"; return x[0]; }'
-assert 98 'func main() char { var x [2]char = [2]char{"a", "b"}; return x[1]; }'
-assert 99 'func main() char { var x [2]char = [3]char{"a", "b", "c"}; return x[2]; }'
+assert 97 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; return x[0]; }"
+assert 98 "func main() char { var x [2]char = [2]char{'a', 'b'}; return x[1]; }"
+assert 99 "func main() char { var x [2]char = [3]char{'a', 'b', 'c'}; return x[2]; }"
 
 assert 1 'var x [2]int = [2]int{1, 2}; func main() int { return x[0]; }'
 assert 2 'var x [2]int = [2]int{1, 2}; func main() int { return x[1]; }'
@@ -173,13 +228,1024 @@ assert 3 'var x [2]int = [2]int{1, 2}; var y [2]int = [2]int{3, 4}; func main()
 assert 1 'var x [2]int = [2]int{1, 2}; func main() int { x[1] = 3; return x[0]; }'
 assert 3 'var x [2]int = [2]int{1, 2}; func main() int { x[1] = 3; return x[1]; }'
 
-assert 97 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; return x[0]; }'
-assert 98 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; return x[1]; }'
-assert 99 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; return x[2]; }'
+assert 97 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; return x[0]; }"
+assert 98 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; return x[1]; }"
+assert 99 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; return x[2]; }"
 
-assert 99 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; x[0] = "c"; return x[0]; }'
+assert 99 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; x[0] = 'c'; return x[0]; }"
 # ToDo: array element has unexpected value after modification
-#   assert 98 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; x[0] = "c"; return x[1]; }'
-#   assert 99 'func main() char { var x [3]char = [3]char{"a", "b", "c"}; x[0] = "c"; return x[2]; }'
+#   assert 98 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; x[0] = 'c'; return x[1]; }"
+#   assert 99 "func main() char { var x [3]char = [3]char{'a', 'b', 'c'}; x[0] = 'c'; return x[2]; }"
+
+
+assert 3 'func main() int { x := 3; return x; }'
+assert 8 'func main() int { a, b := 3, 5; return a+b; }'
+assert 5 'func main() int { a := 3; a, b := 2, 3; return a+b; }'
+assert 3 'func main() int { x := 3; { x := 5; x = x+1; } return x; }'
+assert 6 'func main() int { x := 3; { x := x+3; return x; } }'
+assert 3 'func main() int { var x = 3; return x; }'
+assert 8 'func main() int { var a, b = 3, 5; return a+b; }'
+assert 3 'func main() int { x := 3; y := &x; return *y; }'
+assert 3 'var x = 3; func main() int { return x; }'
+assert 55 'func main() int { j := 0; for i := 0; i <= 10; i = i+1 { j = j+i; } return j; }'
+assert 10 'func main() int { i := 0; for ; i < 10; { i = i+1; } return i; }'
+assert 3 'func main() int { if x := 3; x > 2 { return x; } return 5; }'
+assert 5 'func main() int { if x := 1; x > 2 { return x; } return 5; }'
+
+assert 1 'type P struct { x, y int; }; func main() int { var p P; p.x = 1; p.y = 2; return p.x; }'
+assert 2 'type P struct { x, y int; }; func main() int { var p P; p.x = 1; p.y = 2; return p.y; }'
+assert 0 'type P struct { x, y int; }; func main() int { var p P; return p.x+p.y; }'
+assert 3 'type P struct { a char; b char; c char }; func main() int { var p [2]P; p[1].c = 3; return int(p[1].c); }'
+assert 5 'func main() int { var p struct { x int; y [2]int }; p.y[1] = 5; return p.y[1]; }'
+assert 7 'type P struct { x, y int; }; func main() int { p := P{3, 4}; return p.x+p.y; }'
+assert 4 'type P struct { x, y int; }; func main() int { p := P{y: 4}; return p.x+p.y; }'
+assert 6 'type P struct { x, y int; }; func main() int { var p P = P{y: 4, x: 2}; return p.x+p.y; }'
+assert 9 'type P struct { x, y int; }; func main() int { p := P{3, 4}; q := &p; q.y = 6; return p.x+p.y; }'
+assert 3 'type P struct { x, y int; }; func main() int { p := P{3, 4}; q := p; q.x = 5; return p.x; }'
+assert 5 'type P struct { x, y int; }; func main() int { p := P{3, 4}; var q P; q = p; q.x = 5; return q.x; }'
+assert 4 'type P struct { x, y int; }; func main() int { p := P{3, 4}; var q P; q = p; q.x = 5; return q.y; }'
+assert 2 'type P struct { x, y int; }; func main() int { var p P; p = P{1, 2}; return p.y; }'
+assert 8 'type P struct { x, y int; }; type L struct { a, b P }; func main() int { l := L{P{1, 2}, P{3, 4}}; return l.a.x+l.b.y+l.a.y+l.b.x-2; }'
+assert 10 'type P struct { x, y int; }; func main() int { a := [2]P{{1, 2}, {3, 4}}; return a[0].x+a[0].y+a[1].x+a[1].y; }'
+assert 3 'type P struct { x, y int; }; func main() int { return P{1, 2}.x + P{1, 2}.y; }'
+assert 7 'type P struct { x, y int; }; func sum(p P) int { p.x = 100; return 7; } func main() int { p := P{3, 4}; sum(p); return p.x+p.y; }'
+assert 7 'type P struct { x, y int; }; func sum(p P) int { return p.x+p.y; } func main() int { return sum(P{3, 4}); }'
+assert 11 'type P struct { x, y int; }; func sum(a int, p P, b int) int { return a+p.x+p.y+b; } func main() int { return sum(1, P{3, 4}, 3); }'
+assert 6 'type P struct { x, y int; }; func set(p *P) int { p.x = 6; return 0; } func main() int { var p P; set(&p); return p.x; }'
+assert 3 'type P struct { x, y int; }; var g P = P{1, 2}; func main() int { return g.x+g.y; }'
+assert 3 'type P struct { x, y int; }; var g = P{y: 3}; func main() int { return g.x+g.y; }'
+assert 6 'type P struct { a char; b int; c char }; func main() int { var p [2]P; p[0].c=1; p[1].a=2; p[1].b=3; return int(p[0].c+p[1].a)+p[1].b; }'
+assert 6 'func main() int { a := [...]int{1, 2, 3}; return a[0]+a[1]+a[2]; }'
+assert 5 'func main() int { a := [5]int{4: 5}; return a[4]+a[0]; }'
+assert 3 'func main() int { var a [3]int; a[2] = 3; var b [3]int; b = a; a[2] = 9; return b[2]; }'
+assert 0 'func main() int { var x int; return x; }'
+
+assert 7 'func f() (int, int) { return 3, 4; } func main() int { x, y := f(); return x+y; }'
+assert 1 'func f() (int, int) { return 3, 4; } func main() int { x, y := f(); return y-x; }'
+assert 4 'func f() (int, int) { return 3, 4; } func main() int { _, y := f(); return y; }'
+assert 6 'func f() (int, int, int) { return 1, 2, 3; } func main() int { a, b, c := f(); return a+b+c; }'
+assert 2 'func f() (int, int, int) { return 1, 2, 3; } func main() int { a, b, c := f(); return c*a*a/b+b-b+0*c+1; }'
+assert 21 'func f(a int, b int) (int, char) { return a*b, 1; } func main() int { x, y := f(3, 7); return x*int(y); }'
+assert 13 'func divmod(a, b int) (q, r int) { q = a/b; r = a-q*b; return; } func main() int { q, r := divmod(29, 10); return q*4+r+-2*q+0; }'
+assert 5 'func f() (x int) { x = 5; return; } func main() int { return f(); }'
+assert 6 'func f() (x int) { return 6; } func main() int { return f(); }'
+assert 0 'func f() (x, y int) { return; } func main() int { a, b := f(); return a+b; }'
+assert 2 'func main() int { a, b := 1, 2; a, b = b, a; return a; }'
+assert 1 'func main() int { a, b := 1, 2; a, b = b, a; return b; }'
+assert 3 'func main() int { var x [3]int; i := 0; i, x[i] = 1, 3; return x[0]; }'
+assert 8 'func f() (int, int) { return 3, 5; } func main() int { var a, b int; a, b = f(); return a+b; }'
+assert 8 'func f() (int, int) { return 3, 5; } func main() int { var a, b = f(); return a+b; }'
+assert 5 'func f() (int, int) { return 3, 5; } func g() (int, int) { return f(); } func main() int { _, b := g(); return b; }'
+assert 7 'type P struct { x, y int; }; func mk(x, y int) P { return P{x, y}; } func main() int { p := mk(3, 4); return p.x+p.y; }'
+assert 4 'type P struct { x, y int; }; func mk(x, y int) P { return P{x, y}; } func main() int { return mk(3, 4).y; }'
+assert 12 'type P struct { x, y int; }; func mk(n int) (P, int) { return P{n, n+1}, n+2; } func main() int { p, z := mk(2); return p.x+p.y+z+3; }'
+assert 15 'func f(a, b, c, d, e int) (int, int, int) { return a+b, c+d, e; } func main() int { x, y, z := f(1, 2, 3, 4, 5); return x+y+z; }'
+assert 3 'func f() { return; } func main() int { f(); return 3; }'
+assert 4 'var g int; func set() { g = 4; } func main() int { set(); return g; }'
+assert 3 'func main() int { _ = 5; return 3; }'
+assert 11 'func f() (int, int) { return 5, 6; } var a, b = f(); func main() int { return a+b; }'
+
+assert 2 'func main() int { return 17%5; }'
+assert 255 'func main() int { return -17%5+257; }'
+assert 1 'func main() int { a, b := true, true; if a && b { return 1; }; return 0; }'
+assert 0 'func main() int { a, b := true, false; if a && b { return 1; }; return 0; }'
+assert 1 'func main() int { a, b := false, true; if a || b { return 1; }; return 0; }'
+assert 0 'func main() int { a, b := false, false; if a || b { return 1; }; return 0; }'
+assert 3 'var x int; func set() bool { x = 3; return true; } func main() int { var b bool = false && set(); if b { return 9; }; return 3-x; }'
+assert 0 'var x int; func set() bool { x = 3; return true; } func main() int { var b bool = true && set(); if !b { return 9; }; return 3-x; }'
+assert 0 'var x int; func set() bool { x = 3; return true; } func main() int { var b bool = true || set(); if !b { return 9; }; return x; }'
+assert 1 'func main() int { a := false; if !a { return 1; }; return 0; }'
+assert 0 'func main() int { a := true; if !a { return 1; }; return 0; }'
+assert 6 'func main() int { return 7&14; }'
+assert 15 'func main() int { return 7|14; }'
+assert 9 'func main() int { return 7^14; }'
+assert 1 'func main() int { return 7&^14; }'
+assert 2 'func main() int { return ^-3; }'
+assert 40 'func main() int { return 5<<3; }'
+assert 5 'func main() int { return 40>>3; }'
+assert 255 'func main() int { return -8>>2+257; }'
+assert 1 'func main() int { x := 1; if x<<64 == 0 { return 1 }; return 0; }'
+assert 1 'func main() int { x := -1; if x>>100 == -1 { return 1 }; return 0; }'
+assert 1 'func main() int { x := 5; if x>>64 == 0 { return 1 }; return 0; }'
+assert 7 'func main() int { return 1+2*3; }'
+assert 9 'func main() int { return 1|2*4; }'
+assert 14 'func main() int { return 1<<3|6; }'
+assert 1 'func main() int { if 1+1 == 2 && 3 > 2 { return 1 }; return 0; }'
+assert 1 'func main() int { a, b, c := false, true, true; if a && b || c { return 1; }; return 0; }'
+assert 1 'func main() int { a, b, c := true, true, false; if a || b && c { return 1; }; return 0; }'
+assert 7 'func main() int { return 3 + 8 >> 1 - 0; }'
+assert 2 'func f(x int) int { return x; } func main() int { return f(1)+f(1); }'
+
+assert 7 'func main() int { i := 2; i += 5; return i; }'
+assert 3 'func main() int { i := 5; i -= 2; return i; }'
+assert 10 'func main() int { i := 5; i *= 2; return i; }'
+assert 2 'func main() int { i := 5; i /= 2; return i; }'
+assert 1 'func main() int { i := 5; i %= 2; return i; }'
+assert 4 'func main() int { i := 6; i &= 12; return i; }'
+assert 14 'func main() int { i := 6; i |= 12; return i; }'
+assert 10 'func main() int { i := 6; i ^= 12; return i; }'
+assert 24 'func main() int { i := 6; i <<= 2; return i; }'
+assert 1 'func main() int { i := 6; i >>= 2; return i; }'
+assert 2 'func main() int { i := 6; i &^= 12; return i; }'
+assert 6 'func main() int { i := 5; i++; return i; }'
+assert 4 'func main() int { i := 5; i--; return i; }'
+assert 45 'func main() int { j := 0; for i := 0; i < 10; i++ { j += i; } return j; }'
+assert 3 'func main() int { var a [2]int; p := &a[1]; *p += 3; return a[1]; }'
+assert 1 'var n int; func f() int { n++; return 0; } func main() int { var a [2]int; a[f()] += 5; return n; }'
+assert 5 'var n int; func f() int { n++; return 0; } func main() int { var a [2]int; a[f()] += 5; return a[0]; }'
+assert 1 'var n int; func f() int { n++; return 1; } func main() int { var a [2]int; a[f()]++; return n; }'
+assert 7 'type P struct { x, y int; }; func main() int { p := P{1, 2}; p.y += 5; return p.y; }'
+
+assert 3 'func main() int { i := 0; for { if i == 3 { break; } i++; } return i; }'
+assert 10 'func main() int { i := 0; for i < 10 { i++; if i < 100 { continue; } i = 100; } return i; }'
+assert 25 'func main() int { j := 0; for i := 0; i < 10; i++ { if i % 2 == 0 { continue; } j += i; } return j; }'
+assert 11 'func main() int { n := 0; outer: for i := 0; i < 5; i++ { for j := 0; j < 5; j++ { if j == 3 { continue outer; } if i == 3 { break outer; } n++; } } return n + 2; }'
+assert 6 'func main() int { n := 0; L: for i := 0; i < 3; i++ { for { n += 2; continue L; } } return n; }'
+assert 4 'func main() int { i := 0; loop: if i < 4 { i++; goto loop; } return i; }'
+assert 5 'func main() int { i := 5; goto end; i = 7; end: return i; }'
+assert 3 'func main() int { var x int; goto L; { y := 4; x = y; } L: return x + 3; }'
+assert 1 'func main() int { x := 1; { goto done; } x = 2; done: ; return x; }'
+assert 2 'func main() int { i := 0; L: { i++; if i < 2 { goto L; } } return i; }'
+
+assert 20 'func f(x int) int { switch x { case 1: return 10; case 2, 3: return 20; default: return 30; } return 0; } func main() int { return f(3); }'
+assert 30 'func f(x int) int { switch x { case 1: return 10; case 2, 3: return 20; default: return 30; } return 0; } func main() int { return f(4); }'
+assert 10 'func f(x int) int { switch x { case 1: return 10; case 2, 3: return 20; default: return 30; } return 0; } func main() int { return f(1); }'
+assert 5 'func main() int { x := 0; switch { case x > 0: x = 1; case x < 0: x = 2; default: x = 5; } return x; }'
+assert 2 'func main() int { x := -3; switch { case x > 0: x = 1; case x < 0: x = 2; } return x; }'
+assert 7 'func main() int { r := 0; switch x := 2; x { case 1: r = 1; case 2: r = 3; fallthrough; case 3: r += 4; case 4: r = 100; } return r; }'
+assert 9 'func main() int { r := 0; switch x := 4; { case x > 3: r = 5; fallthrough; default: r += 4; } return r; }'
+assert 1 'func main() int { r := 0; switch 1 { case 1: r = 1; break; r = 2; } return r; }'
+assert 6 'func main() int { n := 0; for i := 0; i < 10; i++ { switch i { case 3: continue; case 7: break; } if i == 7 { break; } n++; } return n; }'
+assert 3 'func main() int { n := 0; L: for i := 0; i < 10; i++ { switch i { case 3: break L; } n++; } return n; }'
+assert 0 'func main() int { switch 3 { } return 0; }'
+assert 4 'func main() int { x := 5; switch x { default: x = 4; case 1: x = 1; } return x; }'
+assert 1 'var n int; func f() int { n++; return 2; } func main() int { switch f() { case 1: case 2: case 3: } return n; }'
+assert 2 'func main() int { n := 0; for i := 0; i < 3; i++ { switch i { case 0: n += 0; case 1: n += 2; } } return n; }'
+assert 60 'func f(x int) int { switch x { case 10: return 11; case 11: return 22; case 12: return 33; case 14: return 44; case 15: return 55; default: return 60; } return 0; } func main() int { return f(13); }'
+assert 44 'func f(x int) int { switch x { case 10: return 11; case 11: return 22; case 12: return 33; case 14: return 44; case 15: return 55; default: return 60; } return 0; } func main() int { return f(14); }'
+assert 99 'func f(x int) int { switch x { case 10: return 11; case 11: return 22; case 12: return 33; case 14: return 44; case 15: return 55; } return 99; } func main() int { return f(16); }'
+assert 99 'func f(x int) int { switch x { case 10: return 11; case 11: return 22; case 12: return 33; case 14: return 44; case 15: return 55; } return 99; } func main() int { return f(-5); }'
+assert 22 'func f(x int) int { switch x { case 10: return 11; case 11: return 22; case 12: return 33; case 14: return 44; case 15: return 55; } return 99; } func main() int { return f(11); }'
+assert 12 'func f(x int) int { r := 0; switch x { case -1: r = 1; case 0: r = 2; case 1: r = 3; fallthrough; case 2: r += 9; } return r; } func main() int { return f(1); }'
+
+assert 6 'type Shape interface { Area() int; }; type Rect struct { w, h int; }; func (r Rect) Area() int { return r.w * r.h; } func main() int { var s Shape = Rect{2, 3}; return s.Area(); }'
+assert 21 'type Shape interface { Area() int; Perim() int; }; type Sq int; func (s Sq) Area() int { var x int = int(s); return x * x; } func (s Sq) Perim() int { var x int = int(s); return 4 * x; } func total(s Shape) int { return s.Area() + s.Perim(); } func main() int { var q Sq = 3; return total(q); }'
+assert 2 'type I interface { Inc() int; }; type C struct { n int; }; func (c *C) Inc() int { c.n++; return c.n; } func main() int { c := &C{}; var i I = c; i.Inc(); i.Inc(); return c.n; }'
+assert 7 'type I interface { Get() int; }; type C struct { n int; }; func (c *C) Get() int { return c.n; } func main() int { c := &C{3}; var i I = c; c.n = 7; return i.Get(); }'
+assert 3 'type T struct { a int; }; func (t T) Get() int { return t.a; } func main() int { t := T{3}; var e interface{} = t; t.a = 5; r, ok := e.(T); if ok { return r.a; }; return 0; }'
+assert 5 'type T int; func main() int { var t T = 5; var e interface{} = t; r := e.(T); var x int = int(r); return x; }'
+assert 0 'type T int; type U int; func main() int { var t T = 5; var e interface{} = t; r, ok := e.(U); var x int = int(r); if ok { return x + 1; }; return x; }'
+assert 1 'func main() int { var e interface{}; if e == nil { return 1 }; return 0; }'
+assert 0 'func main() int { var e interface{} = 1; if e == nil { return 1 }; return 0; }'
+assert 1 'func main() int { var e interface{} = 1; if e != nil { return 1 }; return 0; }'
+assert 42 'func main() int { var e interface{} = 42; return e.(int); }'
+assert 9 'type I interface { M() int; }; type A int; func (a A) M() int { return 9; } func main() int { var a A = 1; var e interface{} = a; i, ok := e.(I); if ok { return i.M(); }; return 0; }'
+assert 0 'type I interface { M() int; }; func main() int { var e interface{} = 1; _, ok := e.(I); if ok { return 1; }; return 0; }'
+assert 2 'type A int; type B struct { x int; }; func kind(e interface{}) int { switch e.(type) { case nil: return 0; case A: return 1; case B, *B: return 2; default: return 3; } return 4; } func main() int { return kind(B{}); }'
+assert 3 'type A int; type B struct { x int; }; func kind(e interface{}) int { switch e.(type) { case nil: return 0; case A: return 1; case B, *B: return 2; default: return 3; } return 4; } func main() int { return kind(5); }'
+assert 0 'type A int; type B struct { x int; }; func kind(e interface{}) int { switch e.(type) { case nil: return 0; case A: return 1; case B, *B: return 2; default: return 3; } return 4; } func main() int { return kind(nil); }'
+assert 2 'type A int; type B struct { x int; }; func kind(e interface{}) int { switch e.(type) { case nil: return 0; case A: return 1; case B, *B: return 2; default: return 3; } return 4; } func main() int { return kind(&B{}); }'
+assert 17 'type B struct { x int; }; func get(e interface{}) int { switch v := e.(type) { case int: return v; case B: return v.x + 10; case *B: return v.x + 20; } return 0; } func main() int { return get(B{7}); }'
+assert 27 'type B struct { x int; }; func get(e interface{}) int { switch v := e.(type) { case int: return v; case B: return v.x + 10; case *B: return v.x + 20; } return 0; } func main() int { return get(&B{7}); }'
+assert 4 'type B struct { x int; }; func get(e interface{}) int { switch v := e.(type) { case int: return v; case B: return v.x + 10; case *B: return v.x + 20; } return 0; } func main() int { return get(4); }'
+assert 8 'type I interface { M() int; }; type J interface { M() int; N() int; }; type A int; func (a A) M() int { return 3; } func (a A) N() int { return 5; } func main() int { var a A; var j J = a; var i I = j; return i.M() + j.N(); }'
+assert 30 'type S interface { Sum() int; }; type V struct { a, b, c int; }; func (v V) Sum() int { return v.a + v.b + v.c; } func mk() S { return V{5, 10, 15}; } func main() int { return mk().Sum(); }'
+
+assert 5 'type P struct { x, y int; }; func (p *P) Move(d int) { p.x += d; } func main() int { p := P{1, 2}; p.Move(4); return p.x; }'
+assert 7 'type P struct { x, y int; }; func (p P) Sum() int { return p.x + p.y; } func main() int { p := &P{3, 4}; return p.Sum(); }'
+assert 9 'type P struct { x, y int; }; func (p *P) Move(d int) { p.x += d; } func main() int { var a [2]P; a[1].Move(9); return a[1].x; }'
+assert 6 'type P struct { x, y int; }; type Q struct { p P; }; func (p *P) Move(d int) { p.x += d; } func main() int { var q Q; q.p.Move(6); return q.p.x; }'
+assert 3 'type N int; func (n *N) Inc() { *n = *n + 1; } func main() int { var n N = 2; n.Inc(); var x int = int(n); return x; }'
+assert 2 'type P struct { x int; }; func (p P) Get() int { return p.x; } func main() int { p := P{2}; f := p.Get; p.x = 5; return f(); }'
+assert 5 'type P struct { x int; }; func (p *P) Get() int { return p.x; } func main() int { p := P{2}; f := p.Get; p.x = 5; return f(); }'
+assert 12 'type P struct { x int; }; func (p P) Add(a int, b int) int { return p.x + a + b; } func main() int { p := P{2}; f := p.Add; return f(3, 7); }'
+assert 8 'type N int; func (n N) Twice() int { var x int = int(n); return x * 2; } func main() int { var n N = 4; f := n.Twice; return f(); }'
+assert 11 'type P struct { x int; }; func (p P) Add(a int) int { return p.x + a; } func main() int { f := P.Add; return f(P{4}, 7); }'
+assert 10 'type P struct { x int; }; func (p *P) Set(a int) { p.x = a; } func main() int { p := P{4}; f := (*P).Set; f(&p, 10); return p.x; }'
+assert 6 'type N int; func (n N) Twice() int { var x int = int(n); return x * 2; } func main() int { var n N = 3; f := (*N).Twice; return f(&n); }'
+assert 7 'type I interface { Get() int; }; type N int; func (n N) Get() int { var x int = int(n); return x; } func main() int { var n N = 7; var i I = n; f := i.Get; return f(); }'
+assert 9 'type T struct { a, b, c int; }; type P struct { x int; }; func (p P) Mk(v int) T { return T{p.x, v, 0}; } func main() int { p := P{4}; f := p.Mk; t := f(5); return t.a + t.b; }'
+assert 4 'type P struct { x int; }; func (p P) Get() int { return p.x; } func (p *P) Set(v int) { p.x = v; } func main() int { p := &P{}; p.Set(4); return p.Get(); }'
+
+assert 0 'func main() int { var s []int; return len(s); }'
+assert 1 'func main() int { var s []int; if s == nil { return 1; }; return 0; }'
+assert 3 'func main() int { s := []int{1, 2, 3}; return len(s); }'
+assert 6 'func main() int { s := []int{1, 2, 3}; return s[0] + s[1] + s[2]; }'
+assert 5 'func main() int { s := []int{2: 5}; return s[2] + s[0] + s[1]; }'
+assert 4 'func main() int { var s []int; s = append(s, 1, 3); return s[0] + s[1]; }'
+assert 45 'func main() int { var s []int; for i := 0; i < 10; i++ { s = append(s, i); }; x := 0; for i := 0; i < len(s); i++ { x += s[i]; }; return x; }'
+assert 16 'func main() int { var s []int; for i := 0; i < 10; i++ { s = append(s, i); }; return cap(s); }'
+assert 10 'func main() int { s := []int{1, 2}; t := []int{3, 4}; s = append(s, t...); return s[0] + s[1] + s[2] + s[3]; }'
+assert 2 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[1:3]; return len(s); }'
+assert 4 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[1:3]; return cap(s); }'
+assert 5 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[1:3]; return s[0] + s[1]; }'
+assert 9 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[1:3]; s[0] = 9; return a[1]; }'
+assert 3 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[1:3:4]; return cap(s); }'
+assert 5 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[:]; return len(s); }'
+assert 3 'func main() int { a := [5]int{1, 2, 3, 4, 5}; s := a[2:]; return len(s); }'
+assert 6 'func main() int { s := []int{1, 2, 3, 4, 5}; t := s[1:4]; u := t[:4]; return u[3] - len(t) + cap(t); }'
+assert 7 'func main() int { a := [3]int{1, 2, 3}; s := a[:1]; s = append(s, 7); return a[1]; }'
+assert 2 'func main() int { a := [3]int{1, 2, 3}; s := a[:1:1]; s = append(s, 7); return a[1]; }'
+assert 3 'func main() int { s := make([]int, 3); return len(s); }'
+assert 10 'func main() int { s := make([]int, 3, 10); return cap(s); }'
+assert 0 'func main() int { s := make([]int, 3); return s[0] + s[1] + s[2]; }'
+assert 2 'func main() int { s := []int{1, 2, 3}; t := make([]int, 2); n := copy(t, s); return n; }'
+assert 3 'func main() int { s := []int{1, 2, 3}; t := make([]int, 2); copy(t, s); return t[0] + t[1]; }'
+assert 4 'func main() int { s := []int{1, 2, 3, 4}; copy(s[1:], s); return s[0] + s[3]; }'
+assert 3 'func main() int { var s []char; s = append(s, 1, 2); return int(s[0] + s[1]); }'
+assert 6 'func main() int { s := [][]int{{1, 2}, {3}}; return s[0][0] + s[0][1] + s[1][0]; }'
+assert 3 'func main() int { s := [][]int{{1, 2}, {3}}; return len(s[0]) + len(s[1]); }'
+assert 5 'func main() int { a := [3]int{1, 2, 3}; p := &a; s := p[1:]; return s[0] + s[1]; }'
+assert 3 'func main() int { a := [3]int{1, 2, 3}; p := &a; return len(p); }'
+assert 6 'func sum(s []int) int { x := 0; for i := 0; i < len(s); i++ { x += s[i]; }; return x; } func main() int { return sum([]int{1, 2, 3}); }'
+assert 0 'func sum(s []int) int { return len(s); } func main() int { return sum(nil); }'
+assert 8 'func mk(n int) []int { s := make([]int, n); s[n-1] = 8; return s; } func main() int { return mk(3)[2]; }'
+assert 3 'func mk(n int) []int { return make([]int, n); } func main() int { return len(mk(3)); }'
+assert 1 'func main() int { s := []int{1}; s = nil; if s == nil { return 1; }; return 0; }'
+assert 0 'func main() int { s := make([]int, 0); if s == nil { return 1; }; return 0; }'
+assert 2 'func main() int { s := []int{1, 2, 3}; i := 3; return s[i]; }'
+assert 2 'func main() int { s := []int{1, 2, 3}; i := -1; return s[i]; }'
+assert 2 'func main() int { s := []int{1, 2, 3}; i := 4; t := s[:i]; return len(t); }'
+assert 2 'func main() int { s := make([]int, 2, 1); return len(s); }'
+assert 9 'type P struct { x, y int; }; func main() int { s := []P{{1, 2}, {3, 3}}; s = append(s, P{4, 5}); return s[2].x + s[2].y; }'
+
+assert 2 'func main() int { a := [3]int{1, 2, 3}; p := &a; return p[1]; }'
+
+assert 7 'type T struct { s []int; }; func main() int { var t T; t.s = make([]int, 2); t.s[1] = 7; return t.s[1]; }'
+assert 5 'type N int; func (n *N) Inc() { *n += 1; } func main() int { s := []N{1, 4}; s[1].Inc(); var x int = int(s[1]); return x; }'
+
+assert 0 'func main() int { var m map[int]int; return len(m); }'
+assert 1 'func main() int { var m map[int]int; if m == nil { return 1; }; return 0; }'
+assert 0 'func main() int { var m map[int]int; return m[3]; }'
+assert 3 'func main() int { m := make(map[int]int); m[1] = 3; return m[1]; }'
+assert 2 'func main() int { m := make(map[int]int); m[1] = 3; m[2] = 4; return len(m); }'
+assert 0 'func main() int { m := make(map[int]int); m[1] = 3; return m[2]; }'
+assert 7 'func main() int { m := make(map[int]int); m[1] = 3; m[1] = 7; return m[1]; }'
+assert 1 'func main() int { m := make(map[int]int); m[1] = 3; m[1] = 7; return len(m); }'
+assert 5 'func main() int { m := make(map[int]int, 100); m[1] += 2; m[1] += 3; return m[1]; }'
+assert 2 'func main() int { m := make(map[int]int); m[5]++; m[5]++; return m[5]; }'
+assert 1 'func main() int { m := map[int]int{1: 2, 3: 4}; delete(m, 1); return len(m); }'
+assert 0 'func main() int { m := map[int]int{1: 2, 3: 4}; delete(m, 1); return m[1]; }'
+assert 4 'func main() int { m := map[int]int{1: 2, 3: 4}; delete(m, 5); return m[3]; }'
+assert 6 'func main() int { m := map[int]int{1: 2, 3: 4}; return m[1] + m[3]; }'
+assert 3 'func main() int { m := map[int]int{1: 2}; v, ok := m[1]; if ok { return v + 1; }; return v; }'
+assert 0 'func main() int { m := map[int]int{1: 2}; v, ok := m[5]; if ok { return v + 1; }; return v; }'
+assert 1 'func main() int { m := map[int]int{1: 2}; if _, ok := m[1]; ok { return 1; }; return 0; }'
+assert 2 'func main() int { m := map[int]int{1: 2}; var v int; var ok bool; v, ok = m[1]; if ok { return v; }; return 0; }'
+assert 0 'func main() int { var m map[int]int; v, ok := m[1]; if ok { return v + 1; }; return v; }'
+assert 2 'func main() int { var m map[int]int; m[1] = 2; return 0; }'
+assert 199 'func main() int { m := make(map[int]int); for i := 0; i < 1000; i++ { m[i] = i * 2; }; return m[999] - 1799; }'
+assert 232 'func main() int { m := make(map[int]int); for i := 0; i < 1000; i++ { m[i] = i; }; return len(m); }'
+assert 10 'func main() int { m := make(map[int]int); for i := 0; i < 100; i++ { m[i] = i; }; for i := 0; i < 90; i++ { delete(m, i); }; return len(m); }'
+assert 99 'func main() int { m := make(map[int]int); for i := 0; i < 100; i++ { m[i] = i; }; for i := 0; i < 90; i++ { delete(m, i); }; return m[99]; }'
+assert 10 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; x := 0; for k := range m { x += k; }; return x - 1 + 2; }'
+assert 21 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; x := 0; for k, v := range m { x += k + v; }; return x; }'
+assert 12 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; x := 0; for _, v := range m { x += v; }; return x; }'
+assert 3 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; x := 0; for range m { x++; }; return x; }'
+assert 5 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; var k, v int; for k, v = range m { if k == 3 { break; }; }; return k + v - 2; }'
+assert 0 'func main() int { var m map[int]int; x := 0; for k, v := range m { x += k + v; }; return x; }'
+assert 1 'func main() int { m := map[int]int{1: 2, 3: 4, 5: 6}; for k := range m { delete(m, k); }; return len(m) + 1; }'
+assert 11 'func main() int { a, b := 1, 10; m := map[*int]int{&a: 1, &b: 10}; return m[&a] + m[&b]; }'
+assert 7 'type P struct { x, y int; }; func main() int { m := map[P]int{}; m[P{1, 2}] = 3; m[P{2, 1}] = 4; return m[P{1, 2}] + m[P{2, 1}]; }'
+assert 4 'type P struct { x, y int; }; func main() int { m := map[P]int{{1, 2}: 4}; return m[P{1, 2}]; }'
+assert 2 'type P struct { x, y int; }; func main() int { m := map[int]P{1: {2, 3}}; return m[1].x; }'
+assert 5 'func main() int { m := map[int][]int{}; m[1] = append(m[1], 2); m[1] = append(m[1], 3); return m[1][0] + m[1][1]; }'
+assert 3 'func main() int { m := map[int]map[int]int{1: {2: 3}}; return m[1][2]; }'
+assert 6 'func count(m map[int]int) int { return len(m); } func main() int { m := map[int]int{}; for i := 0; i < 6; i++ { m[i*7] = i; }; return count(m); }'
+assert 4 'func set(m map[int]int) { m[1] = 4; } func main() int { m := map[int]int{}; set(m); return m[1]; }'
+assert 3 'func main() int { m := map[char]int{}; m[1] = 1; m[2] = 2; return m[1] + m[2]; }'
+
+assert 3 'func main() int { s := "abc"; return len(s); }'
+assert 0 'func main() int { var s string; return len(s); }'
+assert 98 'func main() int { s := "abc"; return int(s[1]); }'
+assert 2 'func main() int { s := "abc"; i := 5; return int(s[i]); }'
+assert 6 'func main() int { s := "abc" + "def"; return len(s); }'
+assert 100 'func main() int { s := "abc" + "def"; return int(s[3]); }'
+assert 5 'func main() int { s := "ab"; s += "cde"; return len(s); }'
+assert 101 'func main() int { s := "ab"; s += "cde"; return int(s[4]); }'
+assert 1 'func main() int { if "abc" == "abc" { return 1 }; return 0; }'
+assert 0 'func main() int { if "abc" == "abd" { return 1 }; return 0; }'
+assert 1 'func main() int { if "abc" != "ab" { return 1 }; return 0; }'
+assert 1 'func main() int { if "abc" < "abd" { return 1 }; return 0; }'
+assert 1 'func main() int { if "ab" < "abc" { return 1 }; return 0; }'
+assert 0 'func main() int { if "b" < "abc" { return 1 }; return 0; }'
+assert 1 'func main() int { if "b" > "abc" { return 1 }; return 0; }'
+assert 1 'func main() int { if "abc" <= "abc" { return 1 }; return 0; }'
+assert 1 'func main() int { if "abc" >= "abc" { return 1 }; return 0; }'
+assert 1 'func main() int { a := "x"; b := "x"; if a + b == "xx" { return 1 }; return 0; }'
+assert 2 'func main() int { s := "hello"; t := s[1:3]; return len(t); }'
+assert 108 'func main() int { s := "hello"; t := s[2:]; return int(t[1]); }'
+assert 1 'func main() int { s := "hello"; if s[:2] == "he" { return 1 }; return 0; }'
+assert 1 'func main() int { s := "hello"; if s[:] == s { return 1 }; return 0; }'
+assert 2 'func main() int { s := "hello"; t := s[3:9]; return len(t); }'
+assert 3 'func main() int { b := []byte("abc"); return len(b); }'
+assert 98 'func main() int { b := []byte("abc"); return int(b[1]); }'
+assert 97 'func main() int { s := "abc"; b := []byte(s); b[0] = 120; return int(s[0]); }'
+assert 1 'func main() int { b := []byte{104, 105}; if string(b) == "hi" { return 1 }; return 0; }'
+assert 1 'func main() int { b := []byte("hi"); s := string(b); b[0] = 120; if s == "hi" { return 1 }; return 0; }'
+assert 3 'func main() int { r := []rune("héé"); return len(r); }'
+assert 233 'func main() int { r := []rune("héé"); return int(r[1]); }'
+assert 5 'func main() int { return len("héé"); }'
+assert 1 'func main() int { r := []rune("h€llo"); if string(r) == "h€llo" { return 1 }; return 0; }'
+assert 1 'func main() int { if string(233) == "é" { return 1 }; return 0; }'
+assert 3 'func main() int { return len(string(8364)); }'
+assert 1 'func main() int { if string(65) == "A" { return 1 }; return 0; }'
+assert 1 'func main() int { r := []rune{104, 233}; if string(r) == "hé" { return 1 }; return 0; }'
+assert 3 'func f(s string) int { return len(s); } func main() int { return f("abc"); }'
+assert 1 'func f() string { return "abc"; } func main() int { if f() == "abc" { return 1 }; return 0; }'
+assert 1 'func cat(a, b string) string { return a + b; } func main() int { if cat("ab", "cd") == "abcd" { return 1 }; return 0; }'
+assert 3 'var g string = "xyz"; func main() int { return len(g); }'
+assert 3 'func main() int { m := map[string]int{"a": 1, "b": 2}; return m["a"] + m["b"]; }'
+assert 7 'func main() int { m := map[string]int{}; k := "ab"; m[k] = 7; return m["a" + "b"]; }'
+assert 2 'func main() int { m := map[string]int{}; m["x"] = 1; m["x" + ""] = 2; return len(m) + m["x"] - 1; }'
+assert 1 'func main() int { m := map[string]int{"abc": 1}; delete(m, "ab" + "c"); return len(m) + 1; }'
+assert 2 'func main() int { s := "b"; switch s { case "a": return 1; case "b": return 2; }; return 3; }'
+assert 4 'type T struct { name string; n int; }; func main() int { t := T{"abcd", 1}; return len(t.name); }'
+assert 1 'type I interface { }; func main() int { var i I = "abc"; s, ok := i.(string); if ok && s == "abc" { return 1; }; return 0; }'
+assert 3 'func main() int { s := []string{"a", "bb"}; return len(s[0]) + len(s[1]); }'
+
+assert 10 'func main() int { a := [4]int{1, 2, 3, 4}; x := 0; for _, v := range a { x += v; }; return x; }'
+assert 6 'func main() int { a := [4]int{1, 2, 3, 4}; x := 0; for i := range a { x += i; }; return x; }'
+assert 20 'func main() int { a := [4]int{1, 2, 3, 4}; x := 0; for i, v := range a { x += i * v; }; return x; }'
+assert 10 'func main() int { a := [4]int{1, 2, 3, 4}; x := 0; for _, v := range a { a[3] = 0; x += v; }; return x; }'
+assert 10 'func main() int { a := [4]int{1, 2, 3, 4}; p := &a; x := 0; for _, v := range p { x += v; }; return x; }'
+assert 15 'func main() int { s := []int{1, 2, 3, 4, 5}; x := 0; for _, v := range s { x += v; }; return x; }'
+assert 3 'func main() int { s := []int{1, 2, 3}; for range s { s = append(s, 0); }; return len(s) - 3; }'
+assert 0 'func main() int { var s []int; x := 0; for i, v := range s { x += i + v; }; return x; }'
+assert 3 'func main() int { s := []int{5, 6, 7}; var i, v int; for i, v = range s { if v == 7 { break; }; }; return i + 1; }'
+assert 9 'func main() int { s := []int{1, 2, 3}; for i := range s { s[i] *= 2; }; x := 0; for _, v := range s { if v%2 == 0 { x += 1; }; }; return x * 3; }'
+assert 6 'func main() int { x := 0; for i, c := range "abc" { x += i; if c == 98 { x += 3; }; }; return x; }'
+assert 5 'func main() int { n := 0; for range "héllo" { n++; }; return n; }'
+assert 233 'func main() int { var r rune; for i, c := range "héllo" { if i == 1 { r = c; }; }; return int(r); }'
+assert 3 'func main() int { var idx int; for i := range "héllo" { if i == 3 { idx = i; }; }; return idx; }'
+assert 45 'func main() int { x := 0; for i := range 10 { x += i; }; return x; }'
+assert 3 'func main() int { x := 0; for range 3 { x++; }; return x; }'
+assert 0 'func main() int { x := 0; for i := range 0 { x += i + 1; }; return x; }'
+assert 4 'func main() int { n := 5; x := 0; for i := range n { x = i; }; return x; }'
+assert 3 'func main() int { var ps []*int; for i := range 3 { ps = append(ps, &i); }; return *ps[0] + *ps[1] + *ps[2]; }'
+assert 3 'func main() int { var ps []*int; for i := 0; i < 3; i++ { ps = append(ps, &i); }; return *ps[0] + *ps[1] + *ps[2]; }'
+assert 6 'func main() int { var ps []*int; for _, v := range []int{1, 2, 3} { ps = append(ps, &v); }; return *ps[0] + *ps[1] + *ps[2]; }'
+assert 3 'func main() int { var ps []*int; for i := 0; i < 3; i++ { x := i; ps = append(ps, &x); }; return *ps[0] + *ps[1] + *ps[2]; }'
+assert 7 'type P struct { x int; }; func main() int { var ps []*P; for i := range 3 { ps = append(ps, &P{i + 1}); }; return ps[0].x + ps[1].x*3 + ps[2].x - 3; }'
+assert 42 'func f() *int { x := 42; return &x; } func g() int { y := 1; return y; } func main() int { p := f(); g(); return *p; }'
+assert 5 'func main() int { x := 5; p := &x; for i := range 3 { *p += i; }; return x - 3; }'
+
+assert 3 'const N = 3; func main() int { return N; }'
+assert 12 'const N = 3; func main() int { var a [N * 4]int; return len(a); }'
+assert 8 'func main() int { const N = 2; var a [N << 2]int; return len(a); }'
+assert 3 'const ( A = iota; B; C; D ); func main() int { return D; }'
+assert 7 'const ( A = 1 << iota; B; C; D ); func main() int { return A + B + C - D + 8; }'
+assert 5 'const ( _ = iota; A; _; B ); func main() int { return A + B + 1; }'
+assert 2 'const ( A, B = iota, iota * 10; C, D ); func main() int { return A + B + C + D - 10 + 1; }'
+assert 6 'const ( X = 2; Y; Z = 3 ); func main() int { return X + Y + Z - 1; }'
+assert 4 'const Big = 1 << 100; func main() int { return Big >> 98; }'
+assert 9 'const Big = 1000000000000000000000000000; func main() int { return Big / 100000000000000000000000000 - 1; }'
+assert 2 'const Huge = 1 << 200; const Small = Huge >> 199; func main() int { x := Small; return x; }'
+assert 6 'type Color int; const ( Red Color = iota; Green; Blue ); func main() int { var c Color = Blue; var x int = int(c); return x * 3; }'
+assert 4 'const A int = 4; func main() int { var x int = A; return x; }'
+assert 3 'func main() int { const s = "abc"; return len(s); }'
+assert 5 'const greeting = "hello"; func main() int { var x string = greeting; return len(x); }'
+assert 1 'const A = 10; func main() int { const A = 1; return A; }'
+assert 10 'const A = 10; func main() int { { const A = 1; }; return A; }'
+assert 24 'const N = 4; type Row [N]int; func main() int { var r [2]Row; return len(r) * len(r[0]) * 3; }'
+assert 7 'const N = 3; func main() int { a := [...]int{N: 7}; return a[N] + len(a) - 4; }'
+assert 9 'const N = 2; func main() int { a := []int{N + 1: 9}; return a[3]; }'
+assert 255 'func main() int { x := 0; x = ^x & 255; return x; }'
+assert 1 'func main() int { x := -7; return x / 2 + 4; }'
+assert 3 'func main() int { const c = -7 / 2; return c + 6; }'
+assert 1 'func main() int { const c = -7 % 2; return c + 2; }'
+assert 2 'func main() int { const c = 7 &^ 5; return c; }'
+assert 10 'func main() int { x := 3; return 1 + x + 6; }'
+assert 4 'type E int; const ( E0 E = iota * 2; E1; E2 ); func f(e E) int { var x int = int(e); return x; } func main() int { return f(E2); }'
+
+assert 128 'func main() int { var x int8 = 127; x++; return -int(x); }'
+assert 0 'func main() int { var x uint8 = 255; x++; return int(x); }'
+assert 255 'func main() int { var x uint8 = 0; x--; return int(x); }'
+assert 1 'func main() int { var x int8 = 127; if x+1 < 0 { return 1; }; return 0; }'
+assert 1 'func main() int { var x uint8 = 200; var y uint8 = 100; if x+y == 44 { return 1; }; return 0; }'
+assert 2 'func main() int { var x int16 = 32767; x += 3; return int(x) + 32768; }'
+assert 1 'func main() int { var x uint32 = 4294967295; x++; return int(x) + 1; }'
+assert 1 'func main() int { var x int32 = -1; var y uint32 = uint32(x); if y == 4294967295 { return 1; }; return 0; }'
+assert 1 'func main() int { var x uint64 = 18446744073709551615; if x > 0 { return 1; }; return 0; }'
+assert 1 'func main() int { var x uint = 1 << 63; var y uint = 1; if y < x { return 1; }; return 0; }'
+assert 3 'func main() int { var x uint64 = 18446744073709551615; return int(x / 6148914691236517205); }'
+assert 0 'func main() int { var x uint64 = 18446744073709551615; return int(x % 3); }'
+assert 3 'func main() int { var x int64 = -7; return int(-x / 2); }'
+assert 1 'func main() int { var x uint8 = 255; return int(x >> 7); }'
+assert 255 'func main() int { var x int8 = -1; return int(uint8(x)); }'
+assert 127 'func main() int { var x int = 383; return int(int8(x)); }'
+assert 16 'func main() int { var x int32 = 1 << 30; return int(x << 2) + 16; }'
+assert 240 'func main() int { var x uint8 = 15; return int(^x); }'
+assert 1 'func main() int { var x int8 = -128; x = -x; if x == -128 { return 1; }; return 0; }'
+assert 12 'type T struct { a int8; b int16; c int32; d int64; }; func main() int { var t T; t.a = 1; t.b = 2; t.c = 4; t.d = 5; return int(t.a) + int(t.b) + int(t.c) + int(t.d); }'
+assert 2 'type T struct { a uint8; b uint16; c uint32; }; func main() int { var t T; t.a = 255; t.b = 65535; t.c = 4294967295; t.a++; t.b++; t.c++; return int(t.a) + int(t.b) + int(t.c) + 2; }'
+assert 8 'func f(a int8, b uint16, c int32, d uint8) int { return int(a) + int(b) + int(c) + int(d); } func main() int { return f(-1, 2, 3, 4); }'
+assert 1 'func f(a uint8) uint8 { return a + 1; } func main() int { return int(f(0)); }'
+assert 0 'func f(a uint8) uint8 { return a + 1; } func main() int { return int(f(255)); }'
+assert 200 'func main() int { s := []byte{100, 200}; return int(s[1]); }'
+assert 195 'func main() int { s := "é"; return int(s[0]); }'
+assert 233 'func main() int { r := []rune("é"); return int(r[0]); }'
+assert 4 'func main() int { var r rune = 1; var b byte = 2; var p uintptr = 1; return int(r) + int(b) + int(p); }'
+assert 3 'type I interface { }; func main() int { var i I = int8(3); switch v := i.(type) { case int: return 1; case int8: return int(v); }; return 0; }'
+assert 2 'func main() int { m := map[int8]uint16{-1: 2}; return int(m[-1]); }'
+
+assert 4 'func main() int { x := 1.5; y := 2.5; return int(x + y); }'
+assert 7 'func main() int { var x float64 = 3; return int(x * 2.5); }'
+assert 5 'func main() int { var x float32 = 1.25; return int(x * 4); }'
+assert 35 'func main() int { x := 7.0; return int(x / 2 * 10); }'
+assert 1 'func main() int { x := 0.5; if x < 1 { return 1 }; return 0; }'
+assert 0 'func main() int { x := 0.5; if x > 1 { return 1 }; return 0; }'
+assert 1 'func main() int { x := 2.5; if x <= 2.5 { return 1 }; return 0; }'
+assert 1 'func main() int { x := 1.5; if x == 1.5 { return 1 }; return 0; }'
+assert 0 'func main() int { x := 1.5; if x != 1.5 { return 1 }; return 0; }'
+assert 1 'func main() int { var x float32 = 1.5; var y float32 = 2; if x < y { return 1 }; return 0; }'
+assert 5 'func main() int { x := 2.5; return int(-x * -2); }'
+assert 7 'func main() int { x := -3.7; return int(x) + 10; }'
+assert 16 'func main() int { return int(0x1p4); }'
+assert 3 'func main() int { return int(0x1.8p1); }'
+assert 100 'func main() int { return int(1e2); }'
+assert 2 'func main() int { return int(.5 * 4); }'
+assert 2 'func main() int { return int(2.5e-1 * 8); }'
+assert 35 'func main() int { n := 7; f := float64(n) / 2; return int(f * 10); }'
+assert 9 'func main() int { var u uint64 = 1 << 63; f := float64(u); return int(f / 1e18); }'
+assert 10 'func main() int { f := 1e19; var u uint64 = uint64(f); return int(u / 1000000000000000000); }'
+assert 1 'func main() int { var a float32 = 0.1; b := float64(a); if b != 0.1 { return 1 }; return 0; }'
+assert 5 'func main() int { var a float32 = 2.5; b := float64(a); return int(b * 2); }'
+assert 3 'func main() int { var a float64 = 3.9; b := float32(a); return int(b); }'
+assert 7 'func sqrt(x float64) float64; func main() int { return int(sqrt(49)); }'
+assert 32 'func pow(x float64, y float64) float64; func main() int { return int(pow(2, 5)); }'
+assert 4 'func half(x float64) float64 { return x / 2; } func main() int { return int(half(9)); }'
+assert 6 'func f(a int, x float64, b int, y float32) float64 { return float64(a)*x + float64(b) + float64(y); } func main() int { return int(f(2, 1.5, 3, 0.5)); }'
+assert 4 'func main() int { var a [3]float64; a[0] = 1.5; a[1] = 2.5; return int(a[0] + a[1] + a[2]); }'
+assert 3 'type P struct { x float32; y float64; }; func main() int { p := P{1.5, 1.5}; return int(p.x + float32(p.y)); }'
+assert 14 'func main() int { const pi = 3.14159; p := pi * 100; return int(p) - 300; }'
+assert 5 'func main() int { const x = 10 / 4.0; return int(x * 2); }'
+assert 2 'func main() int { const x = 10 / 4; return int(x * 1.0); }'
+assert 100 'func main() int { const big = 1e300 * 1e300; return int(big / 1e598); }'
+assert 5 'func main() int { var i interface{} = 2.5; f := i.(float64); return int(f * 2); }'
+assert 1 'func main() int { var i interface{} = float32(1.5); f, ok := i.(float32); if ok { return int(f); }; return 0; }'
+assert 3 'type F float64; func (f F) Twice() F { return f * 2; } func main() int { var f F = 1.5; return int(f.Twice()); }'
+assert 3 'type F float64; func (f F) Twice() F { return f * 2; } func main() int { var f F = 1.5; g := f.Twice; return int(g()); }'
+assert 7 'type F float64; type I interface { Add(x F, n int) F; }; func (f F) Add(x F, n int) F { return f + x + F(n); } func main() int { var f F = 1.5; var i I = f; return int(i.Add(2.5, 3)); }'
+assert 7 'type F float64; type I interface { Add(x F, n int) F; }; func (f F) Add(x F, n int) F { return f + x + F(n); } func main() int { var f F = 1.5; var i I = &f; return int(i.Add(2.5, 3)); }'
+assert 5 'func two() (float64, int) { return 1.5, 2; } func main() int { x, n := two(); return int(x * 2) + n; }'
+assert 12 'func main() int { s := 0.0; for i := 0; i < 4; i++ { s += 1.5; }; return int(s * 2); }'
+
+assert 2 'func main() int { var b bool; if b { return 1; }; return 2; }'
+assert 1 'func main() int { b := 1 < 2; if b { return 1; }; return 0; }'
+assert 2 'func main() int { b := true; c := !b; if c { return 1; }; return 2; }'
+assert 3 'const debug = false; func main() int { if debug { return 1; }; return 3; }'
+assert 4 'const c = 1 < 2 && !false; func main() int { if c { return 4; }; return 0; }'
+assert 2 'func f(x int) bool { return x > 2; } func main() int { n := 0; for i := 0; i < 5; i++ { if f(i) { n++; } }; return n; }'
+assert 5 'func main() int { var b bool = true; var e interface{} = b; v, ok := e.(bool); if ok && v { return 5; }; return 0; }'
+assert 5 'func main() int { var e interface{} = 1 < 2; v, ok := e.(bool); if ok && v { return 5; }; return 0; }'
+assert 2 'func main() int { a := []bool{true, false, true}; n := 0; for _, b := range a { if b { n++; } }; return n; }'
+assert 6 'func main() int { x := true; y := false; if x == !y { return 6; }; return 0; }'
+assert 7 'func main() int { m := map[string]bool{"a": true}; if m["a"] && !m["b"] { return 7; }; return 0; }'
+assert 8 'func main() int { b := false; for !b { b = true; }; if b { return 8; }; return 0; }'
+assert 9 'func main() int { x := 3; switch { case x > 2: return 9; }; return 0; }'
+assert 1 'func main() int { x := 1.5; b := x < 2 && x > 1; if b { return 1; }; return 0; }'
+assert 3 'type T struct { a bool; b bool; c int8; }; func main() int { var t T; t.b = true; t.c = 3; if !t.a && t.b { return int(t.c); }; return 0; }'
+
+assert 7 'type Node struct { val int; next *Node; }; func main() int { var a Node; var b Node; a.val = 3; b.val = 4; a.next = &b; return a.val + a.next.val; }'
+assert 6 'type List struct { head *Elem; }; type Elem struct { v int; next *Elem; }; func main() int { var l List; for i := 1; i <= 3; i++ { l.head = &Elem{i, l.head}; }; n := 0; for e := l.head; e != nil; e = e.next { n += e.v; }; return n; }'
+assert 8 'type A []B; type B int; func main() int { var a A = make(A, 3); a[1] = B(5); return int(a[1]) + len(a); }'
+assert 4 'type A struct { b B; }; type B struct { a *A; v int; }; func main() int { var a A; a.b.v = 4; a.b.a = &a; return a.b.a.b.v; }'
+assert 5 'type Expr interface { Eval() int; Neg() Expr; }; type Lit int; func (l Lit) Eval() int { return int(l); } func (l Lit) Neg() Expr { return -l; } func main() int { var e Expr = Lit(5); return e.Neg().Neg().Eval(); }'
+assert 11 'type Matrix [4][4]int; func main() int { var m Matrix; m[1][2] = 7; return m[1][2] + len(m); }'
+assert 2 'type Point struct { x int; y int; }; type P = *Point; func main() int { var p P = &Point{1, 2}; var q *Point = p; return q.y; }'
+assert 7 'type Point struct { x int; y int; }; type P = *Point; func f(p *Point) int { return p.x; } func main() int { var p P = &Point{7, 2}; return f(p); }'
+assert 6 'type MyInt = int; func main() int { var a MyInt = 3; var b int = a; return b + a; }'
+assert 3 'type ( A int; B = A; ); func main() int { var b B = A(3); return int(b); }'
+assert 9 'func main() int { type L struct { n *L; v int; }; var a L; var b L; b.v = 9; a.n = &b; return a.n.v; }'
+assert 212 'type Celsius float64; type Fahrenheit float64; func main() int { var c Celsius = 100; f := Fahrenheit(c*9/5 + 32); return int(f); }'
+assert 8 'type Celsius int; func main() int { var c Celsius = 5; x := 3; c = c + Celsius(x); return int(c); }'
+assert 6 'type Ints []int; func sum(s []int) int { n := 0; for _, v := range s { n += v; }; return n; } func main() int { var a Ints = []int{1, 2, 3}; return sum(a); }'
+
+assert 12 'func main() int { y := 3; f := func(x int) int { return x + y; }; y = 10; return f(2); }'
+assert 7 'func main() int { x := 1; f := func() { x = 7; }; f(); return x; }'
+assert 3 'func counter() func() int { c := 0; return func() int { c++; return c; }; } func main() int { f := counter(); f(); f(); return f(); }'
+assert 6 'func adder(n int) func(int) int { return func(x int) int { n += x; return n; }; } func main() int { a := adder(1); a(2); return a(3); }'
+assert 20 'func apply(f func(int) int, v int) int { return f(v); } func main() int { k := 4; return apply(func(x int) int { return x * k; }, 5); }'
+assert 6 'func each(s []int, f func(int)) { for _, v := range s { f(v); } } func main() int { sum := 0; each([]int{1, 2, 3}, func(v int) { sum += v; }); return sum; }'
+assert 2 'func main() int { var fs []func() int; for i := 0; i < 3; i++ { fs = append(fs, func() int { return i; }); }; return fs[0]() + fs[2](); }'
+assert 7 'func main() int { f := func(n int) func() int { return func() int { n++; return n; }; }(5); f(); return f(); }'
+assert 55 'func main() int { var fib func(int) int; fib = func(n int) int { if n < 2 { return n; }; return fib(n-1) + fib(n-2); }; return fib(10); }'
+assert 4 'func main() int { x := 1; f := func() func() int { return func() int { x *= 2; return x; }; }; g := f(); g(); g(); return x; }'
+assert 5 'func main() int { x := 2.5; f := func(y float64) float64 { return x * y; }; return int(f(2)); }'
+assert 5 'func main() int { s := "hi"; f := func() int { return len(s); }; s = "hello"; return f(); }'
+assert 9 'type P struct { x int; y int; }; func f(p P, k int) func() int { return func() int { p.x += k; return p.x + p.y; }; } func main() int { g := f(P{1, 2}, 3); g(); return g(); }'
+assert 42 'func f() (r int) { g := func() { r = 42; }; g(); return; } func main() int { return f(); }'
+assert 5 'func inc(p *int) { *p++; } func f(n int) int { inc(&n); return n; } func main() int { return f(4); }'
+assert 3 'func g() int { return 3; } func main() int { f := g; return f(); }'
+assert 14 'var g = func(x int) int { return x * 3; }; var h = func() int { return 2; }; func main() int { return g(4) + h(); }'
+assert 5 'type Op func(int, int) int; func main() int { var add Op = func(a, b int) int { return a + b; }; return add(2, 3); }'
+assert 4 'func main() int { var f func() int; if f == nil { f = func() int { return 4; }; }; if f != nil { return f(); }; return 0; }'
+assert 8 'func main() int { m := map[string]func(int) int{"d": func(x int) int { return x * 2; }}; return m["d"](4); }'
+
+assert 3 'func main() int {
+	a := 1
+	b := 2
+	return a + b
+}'
+assert 6 'type T struct {
+	x int
+	y int
+}
+
+func (t T) sum() int {
+	return t.x + t.y
+}
+
+func main() int {
+	t := T{
+		x: 2,
+		y: 4,
+	}
+	return t.sum()
+}'
+assert 10 'func main() int {
+	s := 0
+	for i := 0; i < 5; i++ {
+		s += i
+	}
+	return s /* a comment
+	spanning lines */
+}'
+assert 7 'func f() (int, int) { return 3, 4; }
+func main() int {
+	a, b := f()
+	if a < b {
+		return a + b
+	}
+	return 0
+}'
+assert 65 'var s int
+func push(x int) { s = s*10 + x; }
+func g() { for i := 1; i <= 3; i++ { defer push(i); }; }
+func main() int { g(); return s; }'
+assert 5 'var s int
+func set(x int) { s = x; }
+func main() int { x := 5; func() { defer set(x); x = 6; }(); return s; }'
+assert 42 'func g() (r int) { defer func() { r *= 2; }(); return 21; }
+func main() int { return g(); }'
+assert 22 'var s int
+type T struct { n int; }
+func (t T) M(k int) { s = s*10 + t.n + k; }
+type I interface { M(k int); }
+func main() int { var i I = T{1}; t := T{2}; func() { defer i.M(1); defer t.M(0); t.n = 5; }(); return s; }'
+assert 5 'var s float64
+func f(x float64) { s = x; }
+func main() int { func() { defer f(2.5); }(); return int(s * 2); }'
+assert 7 'func g() (r int) { defer func() { if recover() != nil { r = 7; }; }(); panic("x"); }
+func main() int { return g(); }'
+assert 9 'func g() (r int) { defer func() { r = recover().(int); }(); panic(9); }
+func main() int { return g(); }'
+assert 3 'func g() (s string) { defer func() { s = recover().(string); }(); panic("abc"); }
+func main() int { return len(g()); }'
+assert 3 'func g() int { defer func() { recover(); }(); panic(1); }
+func main() int { return g() + 3; }'
+assert 4 'func main() int { if recover() == nil { return 4; }; return 0; }'
+assert 21 'var n int
+func g() { defer func() { n++; }(); defer func() { recover(); n *= 10; }(); defer func() { n += 2; }(); var a []int; a[0] = 1; }
+func main() int { g(); return n; }'
+assert 2 'func main() int { panic("boom"); }'
+assert 2 'func main() int { defer func() { panic("second"); }(); panic("first"); }'
+assert 2 'func main() int { var a []int; return a[3]; }'
+
+assert 4 'type E struct{}
+
+func (e E) Error() string { return "err" }
+
+type I interface {
+	Error() string
+}
+
+func main() int {
+	var i I = E{}
+	n := 0
+	for k := 0; k < 3; k++ { n++ }
+	if len(i.Error()) == 3 { return n + 1 }
+	return 0
+}'
+
+assert 31 'func main() int { return 0x1F; }'
+assert 255 'func main() int { return 0X_ff; }'
+assert 15 'func main() int { return 0o17; }'
+assert 15 'func main() int { return 0O17; }'
+assert 15 'func main() int { return 017; }'
+assert 1 'func main() int { return 0_1; }'
+assert 10 'func main() int { return 0b1010; }'
+assert 5 'func main() int { return 0B_1_01; }'
+assert 100 'func main() int { return 1_0_0; }'
+assert 64 'func main() int { return 1_000_000 / 15_625; }'
+assert 16 'func main() int { return int(0x1p4); }'
+assert 105 'func main() int { return int(1_0.5e1); }'
+assert 130 'func main() int { return int(0129.5 + 0.5); }'
+assert 128 'func main() int { var x int8 = -0o200; return int(-x); }'
+assert 0 'func main() int { return 0x7fff_ffff_ffff_ffff % 7; }'
+
+assert 97 "func main() int { return 'a'; }"
+assert 10 "func main() int { return '\n'; }"
+assert 39 "func main() int { return '\''; }"
+assert 92 "func main() int { return '\\\\'; }"
+assert 65 "func main() int { return '\x41'; }"
+assert 65 "func main() int { return '\101'; }"
+assert 33 "func main() int { return int('é' - 200); }"
+assert 33 "func main() int { return int('é') - 200; }"
+assert 3 "func main() int { return int('\U0001F600' - 0x1F5FD); }"
+assert 97 "func main() int { x := 'a'; var y int32 = x; return int(y); }"
+assert 66 "func main() int { var b byte = 'A'; return int(b + 1); }"
+assert 98 "func main() int { c := 'a' + 1; var r rune = c; return int(r); }"
+assert 4 "func main() int { var i interface{} = 'a'; if _, ok := i.(rune); ok { return 4; }; return 0; }"
+assert 2 'func main() int { return len("é"); }'
+assert 4 'func main() int { return len("\U0001F600"); }'
+assert 5 'func main() int { return len("a\tb\"c"); }'
+assert 10 'func main() int { s := "a\nb"; return int(s[1]); }'
+assert 3 'func main() int { s := "\xff\377"; return int(s[0]) - int(s[1]) + 3; }'
+assert 7 'func main() int { s := "\a\b\f\n\r\t\v\\"; return int(s[0]); }'
+assert 6 'func main() int { return len("日本"); }'
+assert 5 'func main() int { return len(`a
+b\n`); }'
+assert 92 'func main() int { s := `\n`; return int(s[0]); }'
+
+assert 7 'func héllo(π int) int { return π * 2; }
+type Größe struct { 值 int }
+func main() int { var g Größe; g.值 = 3; x١ := 1; return héllo(g.值) + x١; }'
+assert 5 'var 变量 int = 5
+func main() int { return 变量; }'
+assert 3 'type Ω struct{}
+func (Ω) Ünicode() int { return 3 }
+type Ĩ interface { Ünicode() int }
+func main() int { var i Ĩ = Ω{}; return i.Ünicode() }'
+
+assert_error 'func f() int { return x }
+func g() int { y := 1; return y + z }
+func main() int { return 0 }' '-:1:23: undefined variable
+-:2:35: undefined variable'
+assert_error 'func main() int {
+	x := (1 + )
+	y := 2
+	return y + z
+}' '-:2:12: expected an expression
+-:4:13: undefined variable'
+assert_error 'func main() int {
+	for i := a; i < 3; i++ {
+	}
+	if j := b; j < 3 {
+	}
+	return c
+}' '-:2:11: undefined variable
+-:4:10: undefined variable
+-:6:9: undefined variable'
+assert_error 'func main() int {
+	return a + b +
+		c + d
+}
+func f() int { return e }
+func g() int { return f() }
+var h = i' '-:2:9: undefined variable
+-:5:23: undefined variable
+-:7:9: undefined variable'
+assert_error 'func main() int {
+	a := b
+	c := d
+	e := f
+	g := h
+	i := j
+	k := l
+	m := n
+	o := p
+	q := r
+	s := t
+	u := v
+	return 0
+}' '-:2:7: undefined variable
+-:3:7: undefined variable
+-:4:7: undefined variable
+-:5:7: undefined variable
+-:6:7: undefined variable
+-:7:7: undefined variable
+-:8:7: undefined variable
+-:9:7: undefined variable
+-:10:7: undefined variable
+-:11:7: undefined variable
+too many errors'
+assert_error "func f() int { return 0x }
+func g() int { return 'ab' }
+func h() string { return \"\\q\" }
+func i() int { return 08 }
+func main() int { return 0 }" '-:1:23: hexadecimal literal has no digits
+-:2:23: more than one character in rune literal
+-:3:27: unknown escape sequence
+-:4:24: invalid digit '"'"'8'"'"' in octal literal'
+assert_error 'func main() int { var x int8 = -129; return int(x) }' '-:1:32: constant -129 overflows int8'
+assert_error 'func main() int { a, b := 1; return a + b }' '-:1:24: assignment mismatch: 2 variables but 1 value'
+assert_error 'const c = x
+func main() int { return c }' '-:1:11: undefined variable'
+assert_error 'func main() int {
+	x := 1
+	return x +
+' '-:4:1: expected an expression
+-:4:1: expected }'
+assert_caret 'func main() int { ü := 1; return ü + x }' '                                             ^ undefined variable'
+
+assert_error 'func main() int { var a float64 = 3.7; var b int = a; return b }' '-:1:52: cannot use a value of type float64 as int value'
+assert_error 'func f1(x int) int { return x }
+func main() int { var a float64 = 3.7; return f1(a) }' '-:2:50: cannot use a value of type float64 as int value'
+assert_error 'func main() int { var a float64 = 3.7; return a }' '-:1:47: cannot use a value of type float64 as int value'
+assert_error 'func main() int { var f float64; i := 3; f = i; return 0 }' '-:1:46: cannot use a value of type int as float64 value'
+assert_error 'func main() int { var f float32; var i int32 = 3; f = i; return 0 }' '-:1:55: cannot use a value of type int32 as float32 value'
+
+assert 1 'type P struct { x, y int }
+func main() int { a := P{1, 2}; b := P{1, 2}; if a == b { return 1 }; return 0 }'
+assert 0 'type P struct { x, y int }
+func main() int { a := P{1, 2}; b := P{1, 3}; if a == b { return 1 }; return 0 }'
+assert 1 'type P struct { x, y int }
+func main() int { a := P{1, 2}; b := P{2, 2}; if a != b { return 1 }; return 0 }'
+assert 1 'func main() int { if [2]int{1, 2} == [2]int{1, 2} { return 1 }; return 0 }'
+assert 0 'func main() int { if [2]int{1, 2} == [2]int{1, 3} { return 1 }; return 0 }'
+assert 1 'type S struct { a int8; s string; f float64 }
+func main() int { s1 := S{1, "a" + "b", 0.5}; s2 := S{1, "ab", 0.5}; if s1 == s2 { return 1 }; return 0 }'
+assert 0 'type S struct { a int8; s string; f float64 }
+func main() int { s1 := S{1, "ab", 0.5}; s2 := S{1, "ac", 0.5}; if s1 == s2 { return 1 }; return 0 }'
+assert 1 'func main() int { if [2]string{"x", "y"} == [2]string{"x", "y"} { return 1 }; return 0 }'
+assert 0 'func main() int { if [2]string{"x", "y"} == [2]string{"x", "z"} { return 1 }; return 0 }'
+assert 3 'type P struct { x, y int }
+var n int
+func getP() P { n++; return P{n, 2} }
+func main() int { a := P{1, 2}; if getP() == a && getP() != a { return n + 1 }; return 0 }'
+assert_error 'type T struct { s []int }
+func main() int { a := T{}; b := T{}; if a == b { return 1 }; return 0 }' '-:2:44: invalid operation: T cannot be compared'
+
+assert 5 'type K struct { a int; s string }
+func main() int { m := map[K]int{}; m[K{1, "ab"}] = 5; b := "b"; return m[K{1, "a" + b}] }'
+assert 0 'type K struct { a int; s string }
+func main() int { m := map[K]int{}; m[K{1, "ab"}] = 5; return m[K{2, "ab"}] }'
+assert 7 'func main() int { m := map[[2]string]int{}; m[[2]string{"x", "yz"}] = 7; z := "z"; return m[[2]string{"x", "y" + z}] }'
+assert 2 'type K struct { s string; n int8; t string }
+func main() int { m := map[K]int{}; m[K{"a", 1, "b"}] = 1; m[K{"a" + "", 1, "b" + ""}] = 2; m[K{"a", 1, "c"}] = 3; return len(m) }'
+
+assert 5 'func main() int { m := map[interface{}]int{}; m[1] = 5; return m[1] }'
+assert 0 'type K struct { i I }
+func main() int { m := map[K]int{}; return len(m) }
+type I interface { f() }'
+assert 1 'type I interface { f() }
+type K struct { i I }
+func main() int { a := K{}; b := K{}; if a == b { return 1 }; return 0 }'
+
+assert 2 'func main() int { x := 9223372036854775807; switch x { case -9223372036854775808: return 1; case 9223372036854775807: return 2; case 0: return 3; case 1: return 4 }; return 0 }'
+assert 4 'func main() int { x := 9223372036854775806; switch x { case 9223372036854775804: return 1; case 9223372036854775805: return 2; case 9223372036854775807: return 3; case 9223372036854775806: return 4 }; return 0 }'
+assert 0 'func main() int { x := -9223372036854775808; switch x { case -9223372036854775807: return 1; case -9223372036854775806: return 2; case -9223372036854775805: return 3; case -9223372036854775804: return 4 }; return 0 }'
+
+assert 12 'var trace int
+func twice(x int) int { return x * 2 }
+func getF() func(int) int { trace = trace*10 + 1; return twice }
+func arg() int { trace = trace*10 + 2; return 3 }
+func main() int { getF()(arg()); return trace }'
+assert 6 'func getF() func(int) int { return func(x int) int { return x * 2 } }
+func main() int { return getF()(3) }'
+
+assert_error 'func main() int { var x int = 1 < 2; return x }' '-:1:33: cannot use a value of type untyped bool as int value'
+assert_error 'func main() int { b := 1 < 2; var x int = b; return x }' '-:1:43: cannot use a value of type bool as int value'
+assert_error 'func main() int { var b bool = 1; if b { return 1 }; return 0 }' '-:1:32: cannot use a constant of type untyped int as bool value'
+assert_error 'func main() int { return 1 < 2 }' '-:1:28: cannot use a value of type untyped bool as int value'
+
+assert_error 'func main() int { var a int32; var b int64; c := a + b; return int(c) }' '-:1:52: invalid operation: mismatched types int32 and int64'
+assert_error 'func main() int { a := 300; var b int8 = a; return int(b) }' '-:1:42: cannot use a value of type int as int8 value'
+assert_error 'func f8(x int8) int { return int(x) }
+func main() int { y := 300; return f8(y) }' '-:2:39: cannot use a value of type int as int8 value'
+assert_error 'func main() int { var a uint8 = 1; if a == 1 { return 1 }; var b byte = 2; var c uint = 3; return int(b) + c }' '-:1:106: invalid operation: mismatched types int and uint'
+assert 44 'func main() int { a := 300; var b int8 = int8(a); return int(b) }'
+
+assert_error 'type C int
+func main() int { s := []C{1}; var t []int = s; return len(t) }' '-:2:46: cannot use a value of type []C as []int value'
+assert_error 'type C int
+func main() int { p := &[2]C{}; var q *[2]int = p; return len(q) }' '-:2:49: cannot use a value of type *[2]C as *[2]int value'
+assert_error 'func runtime_x() int { var s string = 1; return len(s) }
+func main() int { return runtime_x() }' '-:1:39: cannot use a value of type untyped int as string value'
+assert_error 'func runtime_y(p *int) *byte { return p }
+func main() int { return 0 }' '-:1:39: cannot use a value of type *int as *uint8 value'
+
+assert 2 'func main() int { defer recover(); panic(1); return 0 }'
+assert 2 'func helper() { recover() }
+func main() int { defer func() { helper() }(); panic(1); return 0 }'
+assert 5 'func helper() interface{} { return recover() }
+func g() (r int) { defer func() { if helper() == nil && recover() != nil { r = 5 } }(); panic(1) }
+func main() int { return g() }'
+assert 6 'func h() (r int) { defer func() { if recover() == nil { r = 6 } }(); return 0 }
+func g() (r int) { defer func() { r = h(); recover() }(); panic(1) }
+func main() int { return g() }'
+
+assert 15 'func write(x int) int { return x * 2 }
+func exit(x int) int { return x + 1 }
+func calloc(a, b int) int { return a * b }
+func memset() int { return 0 }
+func memmove() int { return 0 }
+var runtime_defers int = 3
+func main() int { m := map[string]int{"a": 1}; s := []int{1}; s = append(s, 2); return write(2) + exit(calloc(2, 2)) + memset() + memmove() + runtime_defers + m["a"] + s[1] }'
+assert 2 'func runtime_makemap(x int) int { return x }
+func main() int { m := map[int]int{}; m[1] = 1; return runtime_makemap(1) + len(m) }'
+assert 2 'func write(x int) int { return x }
+func main() int { defer func() { write(1) }(); var a []int; a[1] = 0; return 0 }'
+assert_error 'var x int
+var x int
+func main() int { return x }' '-:2:5: x redeclared'
+assert_error 'func f() {}
+var f int
+func main() int { return 0 }' '-:2:5: f redeclared'
+
+assert_error 'func g() {}
+func main() int { x := g(); return x }' '-:2:24: g() (no value) used as value'
+assert_error 'func g() {}
+func main() int { var x = g(); return x }' '-:2:27: g() (no value) used as value'
+assert_error 'func main() int { a, a := 1, 2; return a }' '-:1:22: a repeated on left side of :='
+assert 3 'func main() int { _, _, b := 1, 2, 3; return b }'
+
+assert_error 'func main() int {
+	goto L
+	x := 1
+L:
+	return x
+}' '-:2:7: goto L jumps over declaration of x'
+assert 3 'func main() int { i := 0; L: x := i; i++; if x < 2 { goto L }; return i }'
+
+
+assert 32 'type I interface { M() (int, int) }; type T int; func (t T) M() (int, int) { return int(t), 2 }; func main() int { var i I = T(3); a, b := i.M(); return a*10+b }'
+assert 32 'type I interface { M() (int, int) }; type T int; func (t T) M() (int, int) { return int(t), 2 }; func main() int { var i I = T(3); var a, b int; a, b = i.M(); return a*10+b }'
+assert 32 'type I interface { M() (int, int) }; type T int; func (t T) M() (int, int) { return int(t), 2 }; func f(i I) (int, int) { return i.M() }; func main() int { a, b := f(T(3)); return a*10+b }'
+assert 7 'type I interface { M() (int, string) }; type T int; func (t T) M() (int, string) { return int(t), "abcd" }; func main() int { var i I = T(3); a, s := i.M(); return a+len(s) }'
+assert_error 'type I interface { M() (int, int) }; type T int; func (t T) M() (int, int) { return 1, 2 }; func main() int { var i I = T(3); a, b, c := i.M(); return a+b+c }' '-:1:135: assignment mismatch: 3 variables but M returns 2 values'
+
+assert 3 'const s = "ab" + "c"; func main() int { return len(s) }'
+assert 99 'const s = "ab" + "c"; func main() int { return int(s[2]) }'
+assert 1 'const b = "a" < "b"; func main() int { if b { return 1 }; return 0 }'
+assert 0 'const b = "ab" == "a" + "c"; func main() int { if b { return 1 }; return 0 }'
+assert 1 'const b = "ab" >= "a" + "b"; func main() int { if b { return 1 }; return 0 }'
+assert 1 'func main() int { const b = "b" > "a"; x := b; if x { return 1 }; return 0 }'
+assert_error 'const s = "abc"[1:]; func main() int { return len(s) }' '-:1:16: expression is not constant'
+assert 3 'func main() int { const s = "ab" + "c"; return len(s) }'
+
+assert_error 'func main() int { m := map[int][2]int{}; m[1][0] = 5; return m[7][0] }' '-:1:46: cannot assign to array element in map'
+assert_error 'func main() int { m := map[int][2]int{}; m[1][0]++; return m[7][0] }' '-:1:46: cannot assign to array element in map'
+assert_error 'func main() int { m := map[int][2]int{}; m[1][0] += 2; return m[7][0] }' '-:1:46: cannot assign to array element in map'
+assert_error 'type P struct { a [2]int }; func main() int { m := map[int]P{}; m[1].a[1] = 5; return 0 }' '-:1:71: cannot assign to array element in map'
+assert_error 'type P struct { x int }; func main() int { m := map[int][2]P{}; m[1][0].x -= 1; return 0 }' '-:1:72: cannot assign to struct field in map'
+assert_error 'type P struct { x int }; func main() int { m := map[int]P{}; m[1].x, m[2].x = 1, 2; return 0 }' '-:1:66: cannot assign to struct field in map'
+assert_error 'func main() int { m := map[int][2]int{}; p := &m[1][0]; *p = 1; return 0 }' '-:1:47: cannot take address of map index expression'
+assert 7 'func main() int { m := map[int][2]int{}; a := m[1]; a[0] = 5; m[1] = a; m[1] = [2]int{m[1][0] + 2, 0}; return m[1][0] + m[7][0] }'
+assert 5 'func main() int { m := map[int][]int{1: make([]int, 2)}; m[1][0] = 5; m[1][1]++; return m[1][0] + len(m[7]) - m[1][1] + 1 }'
+assert 3 'type P struct { x int }; func main() int { m := map[int]*P{1: &P{1}}; m[1].x += 2; return m[1].x }'
+
+assert 1 'type I interface { M() int }; type T int; func (t T) M() int { return int(t) }; func main() int { i := I(T(1)); return i.M() }'
+assert 10 'type Shape interface { Area() int }; type Rc struct { w, h int }; func (r *Rc) Area() int { return r.w * r.h }; func main() int { s := Shape(&Rc{2, 5}); return s.Area() }'
+assert 3 'func main() int { e := interface{}(3); return e.(int) }'
+assert 7 'func main() int { x := 7; e := interface{}(&x); return *e.(*int) }'
+assert 4 'type I interface { M() int }; type J interface { M() int }; type T int; func (t T) M() int { return int(t) }; func main() int { var j J = T(4); return I(j).M() }'
+assert 1 'type I interface { M() int }; func main() int { if I(nil) == nil { return 1 }; return 0 }'
+assert 2 'func main() int { s := interface{}("ab"); t := s.(string); return len(t) }'
+assert_error 'type I interface { M() int }; type T int; func main() int { i := I(T(1)); return 0 }' '-:1:69: T does not implement I (missing method M)'
+
+assert 1 'func main() int { var e interface{} = 5; if e == 5 { return 1 }; return 0 }'
+assert 0 'func main() int { var e interface{} = 5; if 6 == e { return 1 }; return 0 }'
+assert 0 'func main() int { var e interface{} = int8(5); if e == 5 { return 1 }; return 0 }'
+assert 1 'func main() int { var a, b interface{} = "ab", "a"; b = b.(string) + "b"; if a == b { return 1 }; return 0 }'
+assert 1 'func main() int { var a, b interface{}; if a == b { return 1 }; return 0 }'
+assert 0 'func main() int { var a, b interface{} = 1, nil; if a == b { return 1 }; return 0 }'
+assert 1 'func main() int { var a, b interface{} = 1.5, 1.5; if a != b { return 0 }; return 1 }'
+assert 1 'func main() int { x := 1; var a, b interface{} = &x, &x; if a == b { return 1 }; return 0 }'
+assert 0 'func main() int { x, y := 1, 1; var a, b interface{} = &x, &y; if a == b { return 1 }; return 0 }'
+assert 1 'type P struct { s string; i interface{} }; func main() int { var a, b interface{} = P{"a", 1}, P{"a", 1}; if a == b { return 1 }; return 0 }'
+assert 0 'type P struct { s string; i interface{} }; func main() int { var a, b interface{} = P{"a", 1}, P{"a", 2}; if a == b { return 1 }; return 0 }'
+assert 1 'type I interface { M() int }; type T int; func (t T) M() int { return int(t) }; func main() int { var i I = T(3); var e interface{} = T(3); if i == e && e == i && i == T(3) { return 1 }; return 0 }'
+assert 3 'func f(e interface{}) int { switch e { case 1: return 1; case "a": return 2; case 2.5: return 3 }; return 0 }; func main() int { return f(2.5) }'
+assert 2 'func f(e interface{}) int { switch e { case 1: return 1; case "a": return 2; case 2.5: return 3 }; return 0 }; func main() int { return f("a") }'
+assert 2 'func main() int { var a, b interface{} = []int{}, []int{}; if a == b { return 1 }; return 0 }'
+assert 4 'func main() int { m := map[interface{}]int{}; m[1] = 1; m["a"] = 2; m[int8(1)] = 3; m["a" + ""] = 4; return m["a"] + len(m) - 3 }'
+assert 7 'type P struct { a, b int }; func main() int { m := map[interface{}]int{}; m[P{1, 2}] = 7; return m[P{1, 2}] }'
+assert 0 'func main() int { m := map[interface{}]int{}; _, ok := m[nil]; m[nil] = 1; if !ok && m[nil] == 1 { return 0 }; return 1 }'
+assert 2 'func main() int { m := map[[2]interface{}]int{}; m[[2]interface{}{1, "x"}] = 2; return m[[2]interface{}{1, "x"}] }'
+assert_error 'func main() int { var e interface{} = 1; s := []int{}; if e == s { return 1 }; return 0 }' '-:1:61: invalid operation: []int cannot be compared'
+assert_error 'type I interface { M() }; func main() int { var i I; if i == 1 { return 1 }; return 0 }' '-:1:59: invalid operation: mismatched types I and int'
+
+assert 3 'type K struct { a [70]int; s string }; func main() int { m := map[K]int{}; k := K{}; k.s = "ab"; m[k] = 3; k.s = "a"; k.s = k.s + "b"; return m[k] }'
+
+assert 2 'func main() int { z := 0.0; m := map[float64]int{}; m[0.0] = 1; m[-z] = 2; return m[0] * len(m) }'
+assert 3 'func main() int { z := float32(0); m := map[float32]int{}; m[-z] = 3; return m[0] }'
+assert 2 'func main() int { z := 0.0; nan := z / z; m := map[float64]int{}; m[nan] = 1; m[nan] = 2; _, ok := m[nan]; if ok { return 0 }; return len(m) }'
+assert 5 'type P struct { x float64; s string }; func main() int { z := 0.0; m := map[P]int{}; m[P{0, "a"}] = 5; return m[P{-z, "a"}] }'
+assert 6 'func main() int { z := 0.0; m := map[[2]float64]int{}; m[[2]float64{0, 1}] = 6; return m[[2]float64{-z, 1}] }'
+assert 7 'func main() int { z := 0.0; m := map[interface{}]int{}; m[0.0] = 7; return m[-z] }'
+assert 1 'func main() int { z := 0.0; var a, b interface{} = 0.0, -z; if a == b { return 1 }; return 0 }'
+
+assert_error 'func main() int { var p *int; var q *float64; if p == q { return 1 }; return 0 }' '-:1:52: invalid operation: mismatched types *int and *float64'
+assert_error 'func main() int { var p *int; if p == 0 { return 1 }; return 0 }' '-:1:36: invalid operation: mismatched types *int and untyped int'
+assert_error 'func main() int { var p *int; if p < p { return 1 }; return 0 }' '-:1:36: invalid operation: operator < not defined on *int'
+assert_error 'func main() int { var p *int; p = p + 1; return 0 }' '-:1:37: invalid operation: operator + not defined on *int'
+assert_error 'func main() int { var s []int; var m map[int]int; if s == m { return 1 }; return 0 }' '-:1:56: []int can only be compared to nil'
+assert 1 'func main() int { var p *int; var q *int; if p == q && p == nil { return 1 }; return 0 }'
+assert 1 'func main() int { x := 3; p := &x; q := &x; if p == q && p != nil { return 1 }; return 0 }'
+assert 1 'type P *int; func main() int { x := 3; var p P = &x; if p == &x { return 1 }; return 0 }'
+assert 2 'func main() int { var e interface{} = 1; switch e.(type) { case nil: return 1 }; return 2 }'
+assert 1 'func main() int { var e interface{}; switch e.(type) { case nil: return 1 }; return 2 }'
+
+assert 1 'func main() int { x := -9223372036854775807 - 1; y := -1; if x / y == x { return 1 }; return 0 }'
+assert 0 'func main() int { x := -9223372036854775807 - 1; y := -1; return x % y }'
+assert 1 'func main() int { x := -9223372036854775807 - 1; y := -1; x /= y; if x == -9223372036854775807 - 1 { return 1 }; return 0 }'
+assert 1 'func main() int { var x int32 = -2147483648; var y int32 = -1; if x / y == x && x % y == 0 { return 1 }; return 0 }'
+assert 7 'func main() int { x := -7; y := -1; return x / y }'
+assert 3 'func main() int { x := 7; y := -2; return -(x / y) }'
+assert 1 'func main() int { x := 7; y := -2; return x % y }'
+
+assert_error 'const c = 1; const c = 2; func main() int { return c }' '-:1:20: c redeclared'
+assert_error 'const (c = 1; d; c); func main() int { return c }' '-:1:18: c redeclared'
+assert_error 'func main() int { const c = 1; const c = 2; return c }' '-:1:38: c redeclared'
+assert_error 'func main() int { x := 1; const x = 2; return x }' '-:1:33: x redeclared'
+assert 3 'const c = 1; func main() int { const c = 2; if true { const c = 3; return c }; return c }'
+
+assert_error 'type T int; type T string; func main() int { return 0 }' '-:1:18: T redeclared'
+assert_error 'type (T int; U int; T string); func main() int { return 0 }' '-:1:21: T redeclared'
+assert_error 'func main() int { type T int; type T string; return 0 }' '-:1:36: T redeclared'
+assert_error 'const T = 1; type T int; func main() int { return 0 }' '-:1:7: T redeclared'
+assert 8 'type T int; func main() int { type T [8]int; var t T; return len(t) }'
+assert 0 'const _ = 1; const _ = 2; type _ int; type _ string; func main() int { return 0 }'
+
+assert_error 'type P struct { x, y int }; func main() int { p := P{x: 1, x: 2}; return p.x }' '-:1:60: duplicate field name x in struct literal'
+assert_error 'type P struct { x, y int }; func main() int { p := P{y: 1, x: 2, y: 3}; return p.x }' '-:1:66: duplicate field name y in struct literal'
+assert_error 'type P struct { x, y int }; func main() int { p := P{1}; return p.x }' '-:1:55: too few values in struct literal'
+assert_error 'type P struct { x, y int }; func main() int { p := P{1, y: 2}; return p.x }' '-:1:57: mixture of field:value and value elements in struct literal'
+assert_error 'type P struct { x, y int }; func main() int { p := []P{{1, 2}, {3}}; return p[0].x }' '-:1:66: too few values in struct literal'
+assert 3 'type P struct { x, y int }; func main() int { p := P{y: 1, x: 2}; return p.x + p.y }'
+assert 0 'type P struct { x, y int }; func main() int { p := P{}; return p.x + p.y }'
+assert 3 'type P struct { x, y int }; func main() int { p := P{1, 2,}; return p.x + p.y }'
+
+assert_error 'type P struct { x int }; func (p *P) Inc() { p.x++ }; func main() int { P{1}.Inc(); return 0 }' '-:1:78: cannot call pointer method Inc on P'
+assert_error 'func main() int { s := [3]int{1, 2, 3}[:]; return len(s) }' '-:1:39: invalid operation: [3]int (slice of unaddressable value)'
+assert_error 'type P struct { x int }; type Q struct { p P }; func (p *P) Inc() { p.x++ }; func main() int { Q{}.p.Inc(); return 0 }' '-:1:102: cannot call pointer method Inc on P'
+assert 2 'type P struct { x int }; func (p *P) Inc() { p.x++ }; func main() int { p := &P{1}; p.Inc(); return p.x }'
+assert 2 'type P struct { x int }; func (p *P) Inc() { p.x++ }; func main() int { (&P{1}).Inc(); p := P{1}; p.Inc(); return p.x }'
+assert 1 'type P struct { x int }; func (p P) Get() int { return p.x }; func main() int { return P{1}.Get() }'
+assert 6 'func main() int { n := 0; for _, v := range [3]int{1, 2, 3} { n += v }; return n }'
+assert 2 'func main() int { return [3]int{1, 2, 3}[1] }'
+
+assert 42 'func main() int { ch := make(chan int, 1); ch <- 42; return <-ch }'
+assert 6 'func main() int { ch := make(chan int, 3); ch <- 1; ch <- 2; ch <- 3; close(ch); s := 0; for v := range ch { s += v }; return s }'
+assert 123 'func main() int { ch := make(chan int, 2); s := 0; for i := 1; i <= 3; i++ { ch <- i; s = s*10 + <-ch }; return s }'
+assert 234 'func main() int { ch := make(chan int, 3); ch <- 1; ch <- 2; <-ch; ch <- 3; ch <- 4; close(ch); s := 0; for v := range ch { s = s*10 + v }; return s }'
+assert 23 'func main() int { ch := make(chan int, 3); ch <- 1; ch <- 2; return len(ch)*10 + cap(ch) }'
+assert 0 'func main() int { var ch chan int; return len(ch) + cap(ch) }'
+assert 1 'func main() int { ch := make(chan int, 1); ch <- 5; close(ch); v, ok := <-ch; if v == 5 && ok { return 1 }; return 0 }'
+assert 1 'func main() int { ch := make(chan int, 1); close(ch); v, ok := <-ch; if v == 0 && !ok { return 1 }; return 0 }'
+assert 1 'func main() int { ch := make(chan string, 1); close(ch); var v string; var ok bool; v, ok = <-ch; if v == "" && !ok { return 1 }; return 0 }'
+assert 5 'func main() int { ch := make(chan string, 2); ch <- "ab"; ch <- "cde"; close(ch); n := 0; for s := range ch { n += len(s) }; return n }'
+assert 7 'type P struct { x, y int }; func main() int { ch := make(chan P, 1); ch <- P{3, 4}; p := <-ch; return p.x + p.y }'
+assert 3 'func main() int { ch := make(chan int, 3); ch <- 1; ch <- 2; ch <- 3; close(ch); n := 0; for range ch { n++ }; return n }'
+assert 2 'func main() int { ch := make(chan int); ch <- 1; return 0 }'
+assert 2 'func main() int { ch := make(chan int, 1); return <-ch }'
+assert 2 'func main() int { var ch chan int; return <-ch }'
+assert 2 'func main() int { var ch chan int; ch <- 1; return 0 }'
+assert 2 'func main() int { ch := make(chan int, 1); ch <- 1; for v := range ch { _ = v }; return 0 }'
+assert 2 'func main() int { defer func() { recover() }(); ch := make(chan int); <-ch; return 0 }'
+assert 1 'func f() (r int) { defer func() { if e, ok := recover().(interface{ Error() string }); ok && e.Error() == "send on closed channel" { r = 1 } }(); ch := make(chan int, 1); close(ch); ch <- 1; return 0 }; func main() int { return f() }'
+assert 1 'func f() (r int) { defer func() { if e, ok := recover().(interface{ Error() string }); ok && e.Error() == "close of closed channel" { r = 1 } }(); ch := make(chan int, 1); close(ch); close(ch); return 0 }; func main() int { return f() }'
+assert 1 'func f() (r int) { defer func() { if e, ok := recover().(interface{ Error() string }); ok && e.Error() == "close of nil channel" { r = 1 } }(); var ch chan int; close(ch); return 0 }; func main() int { return f() }'
+assert 1 'func f() (r int) { defer func() { if e, ok := recover().(interface{ Error() string }); ok && e.Error() == "makechan: size out of range" { r = 1 } }(); n := -1; _ = make(chan int, n); return 0 }; func main() int { return f() }'
+assert 1 'func main() int { a := make(chan int); b := a; var c chan int; if a == b && a != make(chan int) && c == nil && a != nil { return 1 }; return 0 }'
+assert 3 'func main() int { m := map[chan int]int{}; a, b := make(chan int), make(chan int); m[a] = 1; m[b] = 2; m[a] += 2; return m[a] }'
+assert 1 'func main() int { a := make(chan int); var x, y interface{} = a, a; if x == y { return 1 }; return 0 }'
+assert 9 'func send(ch chan<- int, v int) { ch <- v }; func recv(ch <-chan int) int { return <-ch }; func main() int { ch := make(chan int, 1); send(ch, 9); return recv(ch) }'
+assert 4 'func gen(n int) <-chan int { ch := make(chan int, n); for i := 0; i < n; i++ { ch <- i }; close(ch); return ch }; func main() int { n := 0; for range gen(4) { n++ }; return n }'
+assert 1 'func main() int { ch := make(chan int, 1); var r <-chan int = ch; ch <- 1; return <-r }'
+assert 2 'func main() int { var e interface{} = make(chan int); switch e.(type) { case chan string: return 1; case chan int: return 2 }; return 0 }'
+assert 3 'func main() int { chs := []chan int{make(chan int, 1), make(chan int, 1)}; chs[1] <- 3; return <-chs[1] }'
+assert 3 'func main() int { ch := make(chan chan int, 1); c := make(chan int, 1); ch <- c; c <- 3; return <-<-ch }'
+assert 1 'func main() int { ch := make(chan float64, 1); ch <- 1.5; if <-ch == 1.5 { return 1 }; return 0 }'
+assert 3 'func main() int { ch := make(chan struct{}, 3); ch <- struct{}{}; ch <- struct{}{}; ch <- struct{}{}; return len(ch) }'
+assert_error 'func main() int { ch := make(<-chan int, 1); ch <- 1; return 0 }' '-:1:49: invalid operation: cannot send to receive-only channel <-chan int'
+assert_error 'func main() int { ch := make(chan<- int, 1); return <-ch }' '-:1:53: invalid operation: cannot receive from send-only channel chan<- int'
+assert_error 'func main() int { ch := make(chan<- int, 1); for v := range ch { return v }; return 0 }' '-:1:61: invalid operation: cannot receive from send-only channel chan<- int'
+assert_error 'func main() int { ch := make(<-chan int, 1); close(ch); return 0 }' '-:1:52: invalid operation: cannot close receive-only channel <-chan int'
+assert_error 'func main() int { x := 1; x <- 1; return 0 }' '-:1:29: invalid operation: cannot send to non-channel int'
+assert_error 'func main() int { x := 1; return <-x }' '-:1:34: invalid operation: cannot receive from non-channel int'
+assert_error 'func main() int { x := 1; close(x); return 0 }' '-:1:33: invalid argument: int is not a channel'
+assert_error 'func main() int { ch := make(chan int, 1); ch <- "a"; return 0 }' '-:1:50: cannot use a value of type string as int value'
+assert_error 'func main() int { ch := make(chan int, 1); for i, v := range ch { return i + v }; return 0 }' '-:1:62: range over chan int permits only one iteration variable'
+assert_error 'func main() int { var r <-chan int; var c chan int = r; return 0 }' '-:1:54: cannot use a value of type <-chan int as chan int value'
+assert_error 'func main() int { var a chan int; var b chan string; if a == b { return 1 }; return 0 }' '-:1:59: invalid operation: mismatched types chan int and chan string'
 
+assert_asm 'func main() int { return 0 }' '.globl main'
+assert_asm 'func Foo() int { return 1 }; func main() int { return Foo() }' '.globl main.Foo'
+assert_asm 'func foo() int { return 1 }; func main() int { return foo() }' '.local main.foo'
+assert_asm 'func _foo() int { return 1 }; func main() int { return _foo() }' '.local main._foo'
+assert_asm 'func Éclair() int { return 1 }; func main() int { return Éclair() }' '.globl main.Éclair'
+assert_asm 'func éclair() int { return 1 }; func main() int { return éclair() }' '.local main.éclair'
+assert_asm 'func Δ() int { return 1 }; func main() int { return Δ() }' '.globl main.Δ'
+assert_asm 'func 世界() int { return 1 }; func main() int { return 世界() }' '.local main.世界'
+assert_asm 'type t int; func (x t) Get() int { return int(x) }; func main() int { return t(1).Get() }' '.globl main.t.Get'
+assert_asm 'type T int; func (x T) get() int { return int(x) }; func main() int { return T(1).get() }' '.local main.T.get'
+assert_asm 'var X int; func main() int { return X }' '  .globl main.X'
+assert_asm 'var x = 3; func main() int { return x }' '  .local main.x'
+assert_asm 'var Ω = 3; func main() int { return Ω }' '  .globl main.Ω'
+assert 3 'var x = 1; func f() int { return 2 }; func main() int { g := f; return x + g() }'
+assert_asm 'func main() int { return 0 }' '.globl runtime_makeslice'
 echo OK
\ No newline at end of file
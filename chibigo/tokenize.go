@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 //
@@ -26,73 +28,130 @@ const (
 )
 
 type Token struct {
-	kind TokenKind // Token kind
-	next *Token    // Next token
-	val  int       // If kind is TK_NUM, its value
-	loc  int       // Token location
-	len  int       // Token length
-	ty   *Type     // Used if TK_STR
-	str  string    // String literal contents including terminating '\0'
+	kind TokenKind  // Token kind
+	next *Token     // Next token
+	val  *big.Int   // If kind is TK_NUM, its value
+	fval *big.Float // If kind is TK_NUM and it is a floating-point literal, its value
+	imag bool       // If kind is TK_NUM, true for an imaginary literal
+	pos  Pos        // Token location
+	len  int        // Token length
+	ty   *Type      // Used if TK_NUM or TK_STR
+	str  string     // String literal contents
 }
 
-// Input filename
-var currentFilename string
-
-// Input string
+// Contents of all the source files, indexed by Pos
 var currentInput string
 
-// Reports an error and exit.
-func errorf(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, format+"\n", a...)
-	os.Exit(1)
+// An error reported at a position in the source files, or at no
+// position if `pos` is negative.
+
+type Error struct {
+	pos Pos
+	msg string
 }
 
-// Reports an error message in the following format and exit.
-//
-// foo.go:10: x = y + 1;
-//               ^ <error message here>
-func verrorAt(loc int, format string, a ...interface{}) {
-	line := loc
-	for line > 0 && currentInput[line-1] != '\n' {
-		line--
-	}
+// The errors reported so far. The compilation stops after maxErrors
+// errors.
+var errorList []*Error
 
-	end := loc
-	for end < len(currentInput) && currentInput[end] != '\n' {
-		end++
-	}
+const maxErrors = 10
 
-	// Get a line number.
-	lineNo := 1
-	for i := 0; i < line; i++ {
-		if currentInput[i] == '\n' {
-			lineNo++
-		}
+// The panic value with which reporting an error abandons the
+// statement or the declaration being compiled.
+
+type bailout struct{}
+
+// Adds an error to the list of errors. The compilation stops when
+// there are too many.
+func addError(pos Pos, format string, a ...interface{}) {
+	errorList = append(errorList, &Error{pos, fmt.Sprintf(format, a...)})
+	if len(errorList) >= maxErrors {
+		panic(bailout{})
 	}
+}
 
-	// Print out the line.
-	lineContent := currentInput[line:end]
-	content := fmt.Sprintf("%s:%d: %s\n", currentFilename, lineNo, lineContent)
+// Reports an error without a position.
+func errorf(format string, a ...interface{}) {
+	addError(-1, format, a...)
+	panic(bailout{})
+}
 
-	// Show the error message.
-	pos := len(content) - (end - loc + 1)
-	fmt.Fprintf(os.Stderr, content)
-	fmt.Fprintf(os.Stderr, "%*s^ ", pos, "")
-	fmt.Fprintf(os.Stderr, format+"\n", a...)
-	os.Exit(1)
+// Reports an error at `pos`, and abandons the statement or the
+// declaration being compiled. The parser recovers from it by skipping
+// to the next one.
+func verrorAt(pos Pos, format string, a ...interface{}) {
+	addError(pos, format, a...)
+	panic(bailout{})
 }
 
 func errorAt(loc int, format string, a ...interface{}) {
-	verrorAt(loc, format, a...)
+	verrorAt(Pos(loc), format, a...)
 }
 
 func errorTok(tok *Token, format string, a ...interface{}) {
-	verrorAt(tok.loc, format, a...)
+	verrorAt(tok.pos, format, a...)
 }
 
-// Consumes the current token if it matches "op".
+// Returns true if the compiler can recover from the panic `r`, which
+// is the case for an error reported by verrorAt until there are too
+// many errors. A crash after an error is most likely caused by the
+// error, and it is handled in the same way.
+func recoverable(r interface{}) bool {
+	if len(errorList) >= maxErrors {
+		return false
+	}
+	_, ok := r.(bailout)
+	return ok || len(errorList) > 0
+}
+
+// Prints the errors sorted by their positions in the following format,
+// leaving out duplicates.
+//
+// foo.go:10:5: x = y + 1;
+//                  ^ <error message here>
+func printErrors() {
+	sort.SliceStable(errorList, func(i, j int) bool {
+		return errorList[i].pos < errorList[j].pos
+	})
+	for i, e := range errorList {
+		if i > 0 && *e == *errorList[i-1] {
+			continue
+		}
+		if e.pos < 0 {
+			fmt.Fprintln(os.Stderr, e.msg)
+			continue
+		}
+
+		p := fset.position(e.pos)
+		lineContent := fset.file(e.pos).lineText(p.line)
+		content := fmt.Sprintf("%s: %s\n", p, lineContent)
+
+		// The caret is indented by a space for each rune before the
+		// position in the line, or by a tab for a tab, so that it is
+		// under the character at the position.
+		indent := strings.Repeat(" ", len(content)-len(lineContent)-1)
+		for _, r := range lineContent[:p.column-1] {
+			if r == '\t' {
+				indent += "\t"
+			} else {
+				indent += " "
+			}
+		}
+		fmt.Fprint(os.Stderr, content)
+		fmt.Fprintf(os.Stderr, "%s^ %s\n", indent, e.msg)
+	}
+	if len(errorList) >= maxErrors {
+		fmt.Fprintln(os.Stderr, "too many errors")
+	}
+}
+
+// Consumes the current token if it matches "op". A semicolon inserted
+// at the end of a line has no text in the input.
 func equal(tok *Token, op string) bool {
-	return bytes.Equal([]byte(currentInput[tok.loc:tok.loc+tok.len]), []byte(op))
+	if tok.kind == TK_PUNCT && tok.len == 0 {
+		return op == ";"
+	}
+	return bytes.Equal([]byte(currentInput[tok.pos:int(tok.pos)+tok.len]), []byte(op))
 }
 
 // Ensure that the current token is `s`.
@@ -103,14 +162,6 @@ func skip(tok *Token, op string) *Token {
 	return tok.next
 }
 
-// Ensure that the current token is TK_NUM.
-func getNumber(tok *Token) (int, error) {
-	if tok.kind != TK_NUM {
-		return 0, fmt.Errorf("expected a number")
-	}
-	return tok.val, nil
-}
-
 func consume(rest **Token, tok *Token, str string) bool {
 	if equal(tok, str) {
 		*rest = tok.next
@@ -124,7 +175,7 @@ func consume(rest **Token, tok *Token, str string) bool {
 func newToken(kind TokenKind, start, punctLen int) *Token {
 	tok := &Token{
 		kind: kind,
-		loc:  start,
+		pos:  Pos(start),
 		len:  punctLen,
 	}
 	return tok
@@ -138,7 +189,10 @@ func isPunct(idx int) bool {
 		string(currentInput[idx]) == ";" || string(currentInput[idx]) == "=" ||
 		string(currentInput[idx]) == "{" || string(currentInput[idx]) == "}" ||
 		string(currentInput[idx]) == "&" || string(currentInput[idx]) == "," ||
-		string(currentInput[idx]) == "[" || string(currentInput[idx]) == "]"
+		string(currentInput[idx]) == "[" || string(currentInput[idx]) == "]" ||
+		string(currentInput[idx]) == ":" || string(currentInput[idx]) == "." ||
+		string(currentInput[idx]) == "%" || string(currentInput[idx]) == "!" ||
+		string(currentInput[idx]) == "|" || string(currentInput[idx]) == "^"
 }
 
 func startswith(p, q string) bool {
@@ -146,9 +200,24 @@ func startswith(p, q string) bool {
 }
 
 func readPunct(idx int) int {
+	for _, punct := range []string{"...", "<<=", ">>=", "&^="} {
+		if startswith(currentInput[idx:], punct) {
+			return 3
+		}
+	}
+
 	p := string(currentInput[idx:min(len(currentInput), idx+2)])
 	if startswith(p, "==") || startswith(p, "!=") ||
-		startswith(p, "<=") || startswith(p, ">=") {
+		startswith(p, "<=") || startswith(p, ">=") ||
+		startswith(p, ":=") || startswith(p, "&&") ||
+		startswith(p, "||") || startswith(p, "<<") ||
+		startswith(p, ">>") || startswith(p, "&^") ||
+		startswith(p, "+=") || startswith(p, "-=") ||
+		startswith(p, "*=") || startswith(p, "/=") ||
+		startswith(p, "%=") || startswith(p, "&=") ||
+		startswith(p, "|=") || startswith(p, "^=") ||
+		startswith(p, "++") || startswith(p, "--") ||
+		startswith(p, "<-") {
 		return 2
 	}
 	if isPunct(idx) {
@@ -157,22 +226,34 @@ func readPunct(idx int) int {
 	return 0
 }
 
-// Returns true if c is valid as the first character of an identifier.
+// Returns true if the character at `idx` is valid as the first
+// character of an identifier, which is a Unicode letter or "_".
 
 func isIdent1(idx int) bool {
-	return 'a' <= currentInput[idx] && currentInput[idx] <= 'z' ||
-		'A' <= currentInput[idx] && currentInput[idx] <= 'Z' ||
-		currentInput[idx] == '_'
+	r, _ := utf8.DecodeRuneInString(currentInput[idx:])
+	return unicode.IsLetter(r) || r == '_'
 }
 
-// Returns true if c is valid as a non-first character of an identifier.
+// Returns true if the character at `idx` is valid as a non-first
+// character of an identifier, which may also be a Unicode digit.
 
 func isIdent2(idx int) bool {
-	return isIdent1(idx) || '0' <= currentInput[idx] && currentInput[idx] <= '9'
+	r, _ := utf8.DecodeRuneInString(currentInput[idx:])
+	return isIdent1(idx) || unicode.IsDigit(r)
+}
+
+// Returns true if the identifier `name` is exported, which is the case
+// if it starts with a Unicode upper case letter.
+
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
 }
 
 func isKeyword(tok *Token) bool {
-	kw := []string{"return", "if", "else", "for", "int", "char", "var", "func"}
+	kw := []string{"return", "if", "else", "for", "int", "char", "string", "var", "func",
+		"type", "struct", "break", "continue", "goto",
+		"switch", "case", "default", "fallthrough", "interface", "map", "range", "const", "defer"}
 	for _, keyword := range kw {
 		if equal(tok, keyword) {
 			return true
@@ -181,17 +262,183 @@ func isKeyword(tok *Token) bool {
 	return false
 }
 
+// Returns true if a semicolon is inserted after `tok` when it is the
+// last token in a line. That is the case for an identifier, a literal,
+// one of the keywords "break", "continue", "fallthrough" and "return",
+// and one of the punctuators "++", "--", ")", "]" and "}".
+
+func endsStatement(tok *Token) bool {
+	switch tok.kind {
+	case TK_NUM, TK_STR:
+		return true
+	case TK_IDENT:
+		for _, kw := range []string{"case", "chan", "const", "default", "defer", "else",
+			"for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+			"range", "select", "struct", "switch", "type", "var"} {
+			if equal(tok, kw) {
+				return false
+			}
+		}
+		return true
+	case TK_PUNCT:
+		return tok.len > 0 && (equal(tok, "++") || equal(tok, "--") ||
+			equal(tok, ")") || equal(tok, "]") || equal(tok, "}"))
+	}
+	return false
+}
+
+// Returns the value of the hexadecimal digit c, or 16 if c is not a
+// digit.
+
+func digitVal(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c|0x20 && c|0x20 <= 'f':
+		return int(c|0x20-'a') + 10
+	}
+	return 16
+}
+
+// Reads an escape sequence after a backslash at `idx` in a rune or
+// string literal enclosed by `quote`. Returns its value, whether the
+// value is a byte given by a "\x" or an octal escape rather than a
+// Unicode code point, and the position after the sequence. A
+// malformed sequence is reported, and ends before the offending
+// character.
+
+func readEscape(idx int, quote byte) (rune, bool, int) {
+	c := currentInput[idx]
+	if i := strings.IndexByte("abfnrtv\\", c); i >= 0 {
+		return rune("\a\b\f\n\r\t\v\\"[i]), false, idx + 1
+	}
+	if c == quote {
+		return rune(c), false, idx + 1
+	}
+
+	var n, base int
+	switch {
+	case c == 'x':
+		n, base = 2, 16
+		idx++
+	case c == 'u':
+		n, base = 4, 16
+		idx++
+	case c == 'U':
+		n, base = 8, 16
+		idx++
+	case '0' <= c && c <= '7':
+		n, base = 3, 8
+	default:
+		addError(Pos(idx-1), "unknown escape sequence")
+		return 0, false, idx
+	}
+
+	var v rune
+	for i := 0; i < n; i++ {
+		if idx+i == len(currentInput) || digitVal(currentInput[idx+i]) >= base {
+			addError(Pos(idx+i), "illegal character in escape sequence")
+			return 0, false, idx + i
+		}
+		v = v*rune(base) + rune(digitVal(currentInput[idx+i]))
+	}
+	if base == 8 && v > 255 {
+		addError(Pos(idx-1), "octal escape value %d > 255", v)
+	}
+	if (c == 'u' || c == 'U') && !utf8.ValidRune(v) {
+		addError(Pos(idx-2), "escape sequence is invalid Unicode code point")
+	}
+	return v, c == 'x' || base == 8, idx + n
+}
+
+// Reads a rune literal at `idx`, which is an untyped integer constant
+// whose value is a Unicode code point. A malformed literal is reported
+// once, and read as 0 up to the closing quote or the end of the line.
+
+func readRuneLiteral(idx int) *Token {
+	nerr := len(errorList)
+	cur := idx + 1
+	n := 0
+	var r rune
+	for cur < len(currentInput) && currentInput[cur] != '\'' && currentInput[cur] != '\n' {
+		if currentInput[cur] == '\\' {
+			r, _, cur = readEscape(cur+1, '\'')
+		} else {
+			var size int
+			r, size = utf8.DecodeRuneInString(currentInput[cur:])
+			cur += size
+		}
+		n++
+	}
+
+	terminated := cur < len(currentInput) && currentInput[cur] == '\''
+	if terminated {
+		cur++
+	}
+	tok := newToken(TK_NUM, idx, cur-idx)
+	tok.val = big.NewInt(0)
+	tok.ty = tyUntypedRune
+	switch {
+	case len(errorList) > nerr:
+	case !terminated:
+		addError(Pos(idx), "rune literal not terminated")
+	case n == 0:
+		addError(Pos(idx), "empty rune literal or unescaped ' in rune literal")
+	case n > 1:
+		addError(Pos(idx), "more than one character in rune literal")
+	default:
+		tok.val = big.NewInt(int64(r))
+	}
+	return tok
+}
+
+// Reads an interpreted string literal at `idx`. Its escape sequences
+// are replaced by the bytes they denote, and a code point is encoded
+// in UTF-8. An unclosed literal ends at the end of the line.
+
 func readStringLiteral(idx int) *Token {
-	start := idx
-	cur := start
-	for ; string(currentInput[cur]) != "\""; cur++ {
-		if string(currentInput[cur]) == "\n" {
-			errorAt(start, "unclosed string literal")
+	var buf []byte
+	cur := idx + 1
+	for cur == len(currentInput) || currentInput[cur] != '"' {
+		if cur == len(currentInput) || currentInput[cur] == '\n' {
+			addError(Pos(idx), "unclosed string literal")
+			return newStringToken(idx, cur, string(buf))
+		}
+		if currentInput[cur] == '\\' {
+			var r rune
+			var isByte bool
+			r, isByte, cur = readEscape(cur+1, '"')
+			if isByte {
+				buf = append(buf, byte(r))
+			} else {
+				buf = utf8.AppendRune(buf, r)
+			}
+			continue
 		}
+		buf = append(buf, currentInput[cur])
+		cur++
+	}
+	return newStringToken(idx, cur+1, string(buf))
+}
+
+// Reads a raw string literal at `idx`, which is enclosed by back quotes
+// and may span lines. It has no escape sequences, and carriage returns
+// in it are discarded.
+
+func readRawStringLiteral(idx int) *Token {
+	end := strings.IndexByte(currentInput[idx+1:], '`')
+	if end < 0 {
+		addError(Pos(idx), "raw string literal not terminated")
+		return newStringToken(idx, len(currentInput), "")
 	}
-	tok := newToken(TK_STR, start, cur-start)
-	tok.ty = arrayOf(tyChar, cur-start)
-	tok.str = string(currentInput[tok.loc : tok.loc+tok.len])
+	str := strings.ReplaceAll(currentInput[idx+1:idx+1+end], "\r", "")
+	return newStringToken(idx, idx+end+2, str)
+}
+
+func newStringToken(start int, end int, str string) *Token {
+	tok := newToken(TK_STR, start, end-start)
+	tok.ty = arrayOf(tyUint8, len(str))
+	tok.str = str
 	return tok
 }
 
@@ -203,94 +450,271 @@ func convertKeywords(tok *Token) {
 	}
 }
 
-func extractNum(idx int) (int, int, error) {
-	numericPart := 0
-	for cur := idx; cur < len(currentInput); cur++ {
-		nextChar := string(currentInput[cur])
-		num, err := strconv.Atoi(nextChar)
-		if err != nil {
-			return numericPart, cur, nil
+// Reads a numeric literal at `idx` into `tok`. The value is exact even
+// if it does not fit in any integer type. A literal with a fraction or
+// an exponent is a floating-point number, whose hexadecimal form has a
+// "p" exponent. An integer starting with "0" is an octal number unless
+// it has a prefix "0x", "0o" or "0b". Digits may be separated by
+// underscores, and a literal ending with "i" is imaginary. Returns the
+// position after the literal. The first error in a malformed literal
+// is reported, and the literal is read as 0.
+
+func readNumber(tok *Token, idx int) int {
+	nerr := len(errorList)
+	report := func(pos int, format string, a ...interface{}) {
+		if len(errorList) == nerr {
+			addError(Pos(pos), format, a...)
 		}
-		numericPart = numericPart*10 + num
 	}
-	return numericPart, len(currentInput), nil
-}
 
-// Tokenize `currentInput` and returns new tokens.
-
-func tokenize(filename string, input string) (*Token, error) {
-	currentFilename = filename
-	currentInput = input
-	head := Token{}
-	cur := &head
-
-	var err error
-	idx := 0
-	for idx < len(currentInput) {
-		// Skip line comments.
-		if idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) == "//" {
-			idx += 2
-			for currentInput[idx] != '\n' {
-				idx++
-			}
-			continue
+	cur := idx
+	base, prefix := 10, byte(0)
+	if currentInput[cur] == '0' && cur+1 < len(currentInput) {
+		switch currentInput[cur+1] | 0x20 {
+		case 'x':
+			base, prefix = 16, 'x'
+			cur += 2
+		case 'o':
+			base, prefix = 8, 'o'
+			cur += 2
+		case 'b':
+			base, prefix = 2, 'b'
+			cur += 2
+		default:
+			base, prefix = 8, '0'
 		}
+	}
 
-		// Skip block comments.
-		if idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) == "/*" {
-			idx += 2
-			for idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) != "*/" {
-				idx++
+	// Reads digits, remembering the first one not valid in the base.
+	invalid := -1
+	digits := func(base int) int {
+		n := 0
+		for ; cur < len(currentInput); cur++ {
+			c := currentInput[cur]
+			d := 16
+			if '0' <= c && c <= '9' {
+				d = int(c - '0')
+			} else if base == 16 && 'a' <= c|0x20 && c|0x20 <= 'f' {
+				d = int(c|0x20-'a') + 10
+			} else if c != '_' {
+				break
 			}
-			if idx+1 == len(currentInput) {
-				errorAt(idx, "unclosed block comment")
+			if c != '_' {
+				if d >= base && invalid < 0 {
+					invalid = cur
+				}
+				n++
 			}
-			idx += 2
-			continue
 		}
+		return n
+	}
 
-		if unicode.IsSpace(rune(currentInput[idx])) {
-			idx += 1
-			continue
+	name := map[byte]string{'x': "hexadecimal", 'o': "octal", 'b': "binary", '0': "octal"}[prefix]
+	n := digits(base)
+	isFloat := false
+	if cur < len(currentInput) && currentInput[cur] == '.' {
+		if prefix == 'o' || prefix == 'b' {
+			report(cur, "invalid radix point in %s literal", name)
 		}
-		if unicode.IsDigit(rune(currentInput[idx])) {
-			cur.next = newToken(TK_NUM, idx, 0)
-			cur = cur.next
-			tmp := idx
-			cur.val, idx, err = extractNum(idx)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", err)
-				return nil, err
+		cur++
+		n += digits(base)
+		isFloat = true
+	}
+	if n == 0 {
+		report(idx, "%s literal has no digits", name)
+	}
+
+	if cur < len(currentInput) && strings.IndexByte("eEpP", currentInput[cur]) >= 0 {
+		e := currentInput[cur] | 0x20
+		if e == 'e' && prefix != 0 && prefix != '0' {
+			report(cur, "'%c' exponent requires decimal mantissa", currentInput[cur])
+		}
+		if e == 'p' && prefix != 'x' {
+			report(cur, "'%c' exponent requires hexadecimal mantissa", currentInput[cur])
+		}
+		cur++
+		if cur < len(currentInput) && (currentInput[cur] == '+' || currentInput[cur] == '-') {
+			cur++
+		}
+		if digits(10) == 0 {
+			report(cur, "exponent has no digits")
+		}
+		isFloat = true
+	} else if prefix == 'x' && isFloat {
+		report(idx, "hexadecimal mantissa requires a 'p' exponent")
+	}
+
+	// A legacy octal literal may turn out to be decimal.
+	if cur < len(currentInput) && currentInput[cur] == 'i' {
+		tok.imag = true
+		cur++
+	} else if !isFloat && invalid >= 0 {
+		report(invalid, "invalid digit '%c' in %s literal", currentInput[invalid], name)
+	}
+
+	lit := currentInput[idx:cur]
+	if i := invalidSep(lit); i >= 0 {
+		report(idx+i, "'_' must separate successive digits")
+	}
+	lit = strings.TrimSuffix(lit, "i")
+
+	if len(errorList) > nerr {
+		tok.val, tok.imag = big.NewInt(0), false
+		tok.ty = tyUntypedInt
+		return cur
+	}
+	if isFloat {
+		tok.fval, _ = new(big.Float).SetPrec(floatPrec).SetString(lit)
+		tok.ty = tyUntypedFloat
+		return cur
+	}
+	tok.ty = tyUntypedInt
+	if prefix == '0' && tok.imag {
+		tok.val, _ = new(big.Int).SetString(strings.ReplaceAll(lit, "_", ""), 10)
+	} else {
+		tok.val, _ = new(big.Int).SetString(lit, 0)
+	}
+	return cur
+}
+
+// Returns the index of the first underscore in the numeric literal
+// `lit` which does not separate successive digits, or -1 if there is
+// none. The base prefix counts as a digit.
+
+func invalidSep(lit string) int {
+	hex := false
+	d := byte('.') // '_', '0' for a digit, or '.' for anything else
+	i := 0
+	if len(lit) >= 2 && lit[0] == '0' && strings.IndexByte("xXoObB", lit[1]) >= 0 {
+		hex = lit[1]|0x20 == 'x'
+		d = '0'
+		i = 2
+	}
+	for ; i < len(lit); i++ {
+		p := d
+		d = lit[i]
+		switch {
+		case d == '_':
+			if p != '0' {
+				return i
 			}
-			cur.len = idx - tmp
-			continue
+		case '0' <= d && d <= '9' || hex && 'a' <= d|0x20 && d|0x20 <= 'f':
+			d = '0'
+		default:
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
 		}
-		// String literal
-		if currentInput[idx] == '"' {
+	}
+	if d == '_' {
+		return len(lit) - 1
+	}
+	return -1
+}
+
+// Reads a token at `idx` and appends it to `cur`, unless `idx` is at a
+// comment or a space. Returns the last token and the position after
+// what is read.
+
+func readToken(cur *Token, idx int) (*Token, int) {
+	// Skip line comments.
+	if idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) == "//" {
+		idx += 2
+		for currentInput[idx] != '\n' {
 			idx++
-			cur.next = readStringLiteral(idx)
-			cur = cur.next
-			idx += cur.len + 1
-			continue
 		}
-		// Identifier or keyword
-		if isIdent1(idx) {
-			start := idx
+		return cur, idx
+	}
+
+	// Skip block comments.
+	if idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) == "/*" {
+		idx += 2
+		for idx+1 < len(currentInput) && string(currentInput[idx:idx+2]) != "*/" {
 			idx++
-			for isIdent2(idx) {
-				idx++
-			}
-			cur.next = newToken(TK_IDENT, start, idx-start)
-			cur = cur.next
-			continue
 		}
-		if punctLen := readPunct(idx); punctLen >= 1 {
-			cur.next = newToken(TK_PUNCT, idx, punctLen)
+		if idx+1 == len(currentInput) {
+			addError(Pos(idx), "unclosed block comment")
+			return cur, len(currentInput)
+		}
+		return cur, idx + 2
+	}
+
+	if unicode.IsSpace(rune(currentInput[idx])) {
+		return cur, idx + 1
+	}
+	if unicode.IsDigit(rune(currentInput[idx])) ||
+		currentInput[idx] == '.' && unicode.IsDigit(rune(currentInput[idx+1])) {
+		cur.next = newToken(TK_NUM, idx, 0)
+		cur = cur.next
+		tmp := idx
+		idx = readNumber(cur, idx)
+		cur.len = idx - tmp
+		return cur, idx
+	}
+	// String literal
+	if currentInput[idx] == '"' {
+		cur.next = readStringLiteral(idx)
+		cur = cur.next
+		return cur, idx + cur.len
+	}
+
+	// Raw string literal
+	if currentInput[idx] == '`' {
+		cur.next = readRawStringLiteral(idx)
+		cur = cur.next
+		return cur, idx + cur.len
+	}
+
+	// Rune literal
+	if currentInput[idx] == '\'' {
+		cur.next = readRuneLiteral(idx)
+		cur = cur.next
+		return cur, idx + cur.len
+	}
+	// Identifier or keyword
+	if isIdent1(idx) {
+		start := idx
+		for idx < len(currentInput) && isIdent2(idx) {
+			_, size := utf8.DecodeRuneInString(currentInput[idx:])
+			idx += size
+		}
+		cur.next = newToken(TK_IDENT, start, idx-start)
+		cur = cur.next
+		return cur, idx
+	}
+	if punctLen := readPunct(idx); punctLen >= 1 {
+		cur.next = newToken(TK_PUNCT, idx, punctLen)
+		return cur.next, idx + punctLen
+	}
+	r, size := utf8.DecodeRuneInString(currentInput[idx:])
+	addError(Pos(idx), "invalid token: %c", r)
+	return cur, idx + size
+}
+
+// Tokenize `currentInput` and returns new tokens.
+
+func tokenize(filename string, input string) (*Token, error) {
+	file := fset.addFile(filename, input)
+	head := Token{}
+	cur := &head
+
+	idx := int(file.base)
+	for idx < len(currentInput) {
+		// Insert a semicolon at the end of a line which ends a
+		// statement. A block comment spanning lines acts like a
+		// newline.
+		newline := currentInput[idx] == '\n'
+		if startswith(currentInput[idx:], "/*") {
+			end := strings.Index(currentInput[idx+2:], "*/")
+			newline = end >= 0 && strings.Contains(currentInput[idx+2:idx+2+end], "\n")
+		}
+		if newline && cur != &head && endsStatement(cur) {
+			cur.next = newToken(TK_PUNCT, idx, 0)
 			cur = cur.next
-			idx += punctLen
-			continue
 		}
-		errorAt(idx, "invalid token: %s", string(currentInput[idx]))
+
+		cur, idx = readToken(cur, idx)
 	}
 	cur.next = newToken(TK_EOF, idx, 0)
 	cur = cur.next
@@ -325,9 +749,21 @@ func tokenizeFile(path string) (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	if token, err := tokenize(path, file); err != nil {
+	tok, err := tokenize(path, file)
+	if err != nil {
 		return nil, err
-	} else {
-		return token, nil
 	}
+
+	// The runtime follows the end of the program, so that an error in
+	// the program cannot be recovered from in the runtime.
+	rt, err := tokenize("<runtime>", runtimeSource)
+	if err != nil {
+		return nil, err
+	}
+	eof := tok
+	for eof.kind != TK_EOF {
+		eof = eof.next
+	}
+	eof.next = rt
+	return tok, nil
 }
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
 //
 // Type
 //
@@ -7,48 +13,207 @@ package main
 type TypeKind int
 
 const (
-	TY_CHAR TypeKind = iota
+	TY_VOID TypeKind = iota
+	TY_BOOL
+	TY_INT8
+	TY_INT16
+	TY_INT32
+	TY_INT64
 	TY_INT
+	TY_UINT8
+	TY_UINT16
+	TY_UINT32
+	TY_UINT64
+	TY_UINT
+	TY_UINTPTR
+	TY_FLOAT32
+	TY_FLOAT64
 	TY_PTR
 	TY_FUNC
 	TY_ARRAY
+	TY_STRUCT
+	TY_TUPLE
+	TY_INTERFACE
+	TY_SLICE
+	TY_MAP
+	TY_CHAN
+	TY_STRING
+)
+
+// The direction of a channel type
+type ChanDir int
+
+const (
+	CHAN_BOTH ChanDir = iota
+	CHAN_SEND
+	CHAN_RECV
 )
 
 type Type struct {
-	kind     TypeKind
-	size     int
-	base     *Type  // Pointer
-	name     *Token // Declaration
-	arrayLen int
-	returnTy *Type
-	params   *Type
-	next     *Type
+	kind       TypeKind
+	size       int    // sizeof() value
+	align      int    // alignment
+	isUnsigned bool   // unsigned integer
+	base       *Type  // Pointer, or the element type of an array, slice, map or channel
+	key        *Type  // Map
+	name       *Token // Declaration
+	arrayLen   int
+	dir        ChanDir // Channel
+	members    *Member // Struct, tuple, interface or slice
+	returnTy   *Type
+	params     *Type
+	next       *Type
+
+	// Named type
+	typeName *Token
+	origin   *Type   // The type this one is a copy of
+	methods  *Method // Methods of a named type or an interface
+	decl     *Token  // Declaration of a named type yet to be parsed
+	copies   []*Type // Copies of a named type made while it is parsed
+}
+
+// Struct member
+type Member struct {
+	next   *Member
+	ty     *Type
+	name   *Token
+	offset int
+}
+
+// Method of a named type or an interface
+type Method struct {
+	next    *Method
+	name    string
+	ty      *Type // Function type without the receiver
+	fn      *Obj  // Method of a named type
+	ptrRecv bool  // Method with a pointer receiver
 }
 
-var tyChar = &Type{kind: TY_CHAR, size: 1}
-var tyInt = &Type{kind: TY_INT, size: 8}
+var tyVoid = &Type{kind: TY_VOID, size: 1, align: 1}
+var tyBool = &Type{kind: TY_BOOL, size: 1, align: 1}
+var tyInt8 = &Type{kind: TY_INT8, size: 1, align: 1}
+var tyInt16 = &Type{kind: TY_INT16, size: 2, align: 2}
+var tyInt32 = &Type{kind: TY_INT32, size: 4, align: 4}
+var tyInt64 = &Type{kind: TY_INT64, size: 8, align: 8}
+var tyInt = &Type{kind: TY_INT, size: 8, align: 8}
+var tyUint8 = &Type{kind: TY_UINT8, size: 1, align: 1, isUnsigned: true}
+var tyUint16 = &Type{kind: TY_UINT16, size: 2, align: 2, isUnsigned: true}
+var tyUint32 = &Type{kind: TY_UINT32, size: 4, align: 4, isUnsigned: true}
+var tyUint64 = &Type{kind: TY_UINT64, size: 8, align: 8, isUnsigned: true}
+var tyUint = &Type{kind: TY_UINT, size: 8, align: 8, isUnsigned: true}
+var tyUintptr = &Type{kind: TY_UINTPTR, size: 8, align: 8, isUnsigned: true}
+var tyFloat32 = &Type{kind: TY_FLOAT32, size: 4, align: 4}
+var tyFloat64 = &Type{kind: TY_FLOAT64, size: 8, align: 8}
+
+// The types of untyped integer, rune and floating-point constants.
+// Constant expressions are evaluated exactly, and an untyped constant
+// gets a type when it is used with a typed operand or assigned.
+var tyUntypedInt = &Type{kind: TY_INT, size: 8, align: 8}
+var tyUntypedRune = &Type{kind: TY_INT, size: 8, align: 8}
+var tyUntypedFloat = &Type{kind: TY_FLOAT64, size: 8, align: 8}
+
+// The type of comparisons and of the constants true and false, which
+// can be used as any boolean type.
+var tyUntypedBool = &Type{kind: TY_BOOL, size: 1, align: 1}
+
+// The precision in bits of floating-point constants
+const floatPrec = 512
+
+// A string is a pair of a pointer to the immutable bytes and the
+// length. It is laid out like the first two words of a slice.
+var tyString = &Type{kind: TY_STRING, size: 16, align: 8, members: &Member{
+	ty: pointerTo(tyUint8), offset: 0, next: &Member{ty: tyInt, offset: 8}}}
+
+// The type of a variable or a constant whose declaration has an error.
+// A statement using it is skipped without reporting another error.
+var tyInvalid = &Type{kind: TY_VOID, size: 1, align: 1}
+
+// The type of the untyped `nil`.
+var tyNil = &Type{kind: TY_PTR, size: 8, align: 8, base: tyVoid}
+
+// An interface value is a pair of pointers to an itab and to the
+// value. The first word of an itab points to the type descriptor
+// of the dynamic type, and the rest are the methods of the interface
+// implemented by the type. A value whose type is a pointer is stored
+// as is instead of a pointer to it.
+var ifaceData = &Member{ty: pointerTo(tyVoid), offset: 8}
+var ifaceTab = &Member{next: ifaceData, ty: pointerTo(tyInt), offset: 0}
 
 func isInteger(ty *Type) bool {
-	return ty.kind == TY_INT || ty.kind == TY_CHAR
+	return TY_INT8 <= ty.kind && ty.kind <= TY_UINTPTR
+}
+
+func isFlonum(ty *Type) bool {
+	return ty.kind == TY_FLOAT32 || ty.kind == TY_FLOAT64
+}
+
+func isNumeric(ty *Type) bool {
+	return isInteger(ty) || isFlonum(ty)
+}
+
+func isUntyped(ty *Type) bool {
+	return ty == tyUntypedInt || ty == tyUntypedRune || ty == tyUntypedFloat || ty == tyUntypedBool
 }
 
 func copyType(ty *Type) *Type {
 	ret := new(Type)
 	*ret = *ty
+	ret.origin = ty
+	if ty.origin != nil {
+		ret.origin = ty.origin
+	}
+	// A copy of a named type whose declaration is being parsed, like a
+	// parameter of a method of an interface referring to itself, is
+	// completed along with the named type.
+	if isIncomplete(ret.origin) {
+		ret.origin.copies = append(ret.origin.copies, ret)
+	}
 	return ret
 }
 
+// Returns true if `ty` is a named type whose declaration is being
+// parsed.
+
+func isIncomplete(ty *Type) bool {
+	return ty.kind == TY_VOID && ty.typeName != nil
+}
+
+// Returns true if a value of type `ty` contains a value of type
+// `target` without an indirection through a pointer, a slice, a map
+// or a function.
+
+func containsType(ty *Type, target *Type) bool {
+	switch ty.kind {
+	case TY_ARRAY:
+		return ty.base == target || ty.base.origin == target || containsType(ty.base, target)
+	case TY_STRUCT:
+		for mem := ty.members; mem != nil; mem = mem.next {
+			if mem.ty == target || mem.ty.origin == target || containsType(mem.ty, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func pointerTo(base *Type) *Type {
 	ty := new(Type)
 	ty.kind = TY_PTR
 	ty.size = 8
+	ty.align = 8
 	ty.base = base
 	return ty
 }
 
+// A function value is a pointer to a closure, whose first word is the
+// address of the code. The rest of the closure is the context, which
+// is passed to the code in r10.
+
 func funcType(returnTy *Type) *Type {
 	ty := new(Type)
 	ty.kind = TY_FUNC
+	ty.size = 8
+	ty.align = 8
 	ty.returnTy = returnTy
 	return ty
 }
@@ -57,12 +222,368 @@ func arrayOf(base *Type, len int) *Type {
 	ty := new(Type)
 	ty.kind = TY_ARRAY
 	ty.size = base.size * len
+	ty.align = base.align
 	ty.base = base
 	ty.arrayLen = len
 	return ty
 }
 
+// A slice is a triple of a pointer to the array, the length and the
+// capacity.
+
+func sliceOf(base *Type) *Type {
+	cap := &Member{ty: tyInt, offset: 16}
+	len := &Member{next: cap, ty: tyInt, offset: 8}
+	ptr := &Member{next: len, ty: pointerTo(base), offset: 0}
+
+	ty := new(Type)
+	ty.kind = TY_SLICE
+	ty.size = 24
+	ty.align = 8
+	ty.base = base
+	ty.members = ptr
+	return ty
+}
+
+// A map is a pointer to a runtime hash table.
+
+func mapOf(key *Type, base *Type) *Type {
+	ty := new(Type)
+	ty.kind = TY_MAP
+	ty.size = 8
+	ty.align = 8
+	ty.key = key
+	ty.base = base
+	return ty
+}
+
+// A channel is a pointer to a runtime ring buffer.
+
+func chanOf(base *Type, dir ChanDir) *Type {
+	ty := new(Type)
+	ty.kind = TY_CHAN
+	ty.size = 8
+	ty.align = 8
+	ty.base = base
+	ty.dir = dir
+	return ty
+}
+
+func structType() *Type {
+	ty := new(Type)
+	ty.kind = TY_STRUCT
+	ty.align = 1
+	return ty
+}
+
+func interfaceType() *Type {
+	ty := new(Type)
+	ty.kind = TY_INTERFACE
+	ty.size = 16
+	ty.align = 8
+	ty.members = ifaceTab
+	return ty
+}
+
+// A tuple is the type of a call of a function with multiple results.
+// It is laid out like a struct whose members are the results.
+
+func tupleType(results *Type) *Type {
+	head := new(Member)
+	cur := head
+	for t := results; t != nil; t = t.next {
+		cur.next = &Member{ty: t, name: t.name}
+		cur = cur.next
+	}
+
+	ty := new(Type)
+	ty.kind = TY_TUPLE
+	ty.align = 1
+	ty.members = head.next
+	layoutMembers(ty)
+	return ty
+}
+
+// Assign offsets within the struct or tuple to members.
+
+func layoutMembers(ty *Type) {
+	offset := 0
+	for mem := ty.members; mem != nil; mem = mem.next {
+		offset = alignTo(offset, mem.ty.align)
+		mem.offset = offset
+		offset += mem.ty.size
+
+		if ty.align < mem.ty.align {
+			ty.align = mem.ty.align
+		}
+	}
+	ty.size = alignTo(offset, ty.align)
+}
+
+// Returns true if a value of the given type doesn't fit in a register
+// and is handled by its address instead.
+
+func isAggregate(ty *Type) bool {
+	return ty.kind == TY_ARRAY || ty.kind == TY_STRUCT || ty.kind == TY_TUPLE ||
+		ty.kind == TY_INTERFACE || ty.kind == TY_SLICE || ty.kind == TY_STRING
+}
+
+// A function returns a pair of non-floating-point scalars in rax and
+// rdx. Any other aggregate is returned through a hidden pointer passed
+// in rdi, which the function also returns in rax. A floating-point
+// number is returned in xmm0.
+
+func returnsInRegs(ty *Type) bool {
+	return ty.kind == TY_TUPLE && ty.members.next != nil && ty.members.next.next == nil &&
+		!isAggregate(ty.members.ty) && !isFlonum(ty.members.ty) &&
+		!isAggregate(ty.members.next.ty) && !isFlonum(ty.members.next.ty)
+}
+
+func returnsViaPointer(ty *Type) bool {
+	return isAggregate(ty) && !returnsInRegs(ty)
+}
+
+// Returns true if a value of the type is an aggregate which can be
+// nil.
+
+func isNillable(ty *Type) bool {
+	return ty.kind == TY_INTERFACE || ty.kind == TY_SLICE
+}
+
+// Returns true if values of the type can be compared with ==, which
+// is required for map keys.
+
+func isComparable(ty *Type) bool {
+	switch ty.kind {
+	case TY_SLICE, TY_MAP, TY_FUNC:
+		return false
+	case TY_ARRAY:
+		return isComparable(ty.base)
+	case TY_STRUCT:
+		for mem := ty.members; mem != nil; mem = mem.next {
+			if !isComparable(mem.ty) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns true if a value of type `from` can be assigned to a variable
+// of type `to` without a conversion. Values of different named types,
+// such as a named integer type and int, are not assignable to each
+// other even if their underlying types are identical.
+
+func assignable(from *Type, to *Type) bool {
+	if identical(from, to) {
+		return true
+	}
+	if from == tyNil {
+		switch to.kind {
+		case TY_PTR, TY_SLICE, TY_MAP, TY_CHAN, TY_FUNC, TY_INTERFACE:
+			return true
+		}
+		return false
+	}
+	if isNamed(from) && isNamed(to) {
+		return false
+	}
+
+	// A bidirectional channel can be used as a channel in either
+	// direction.
+	if from.kind == TY_CHAN && to.kind == TY_CHAN && from.dir == CHAN_BOTH {
+		return identical(from.base, to.base)
+	}
+	t1, t2 := *from, *to
+	t1.typeName, t2.typeName = nil, nil
+	return identical(&t1, &t2)
+}
+
+// Returns true if `ty` is a named type. The predeclared types such as
+// int and string are named types.
+
+func isNamed(ty *Type) bool {
+	return ty.typeName != nil || ty.kind <= TY_FLOAT64 || ty.kind == TY_STRING
+}
+
+// Returns true if two types are identical. Named types are identical
+// only if they come from the same declaration.
+
+func identical(t1 *Type, t2 *Type) bool {
+	if t1 == t2 {
+		return true
+	}
+	if t1.typeName != nil || t2.typeName != nil {
+		return t1.typeName == t2.typeName
+	}
+	if t1.kind != t2.kind {
+		return false
+	}
+
+	switch t1.kind {
+	case TY_PTR, TY_SLICE:
+		return identical(t1.base, t2.base)
+	case TY_ARRAY:
+		return t1.arrayLen == t2.arrayLen && identical(t1.base, t2.base)
+	case TY_MAP:
+		return identical(t1.key, t2.key) && identical(t1.base, t2.base)
+	case TY_CHAN:
+		return t1.dir == t2.dir && identical(t1.base, t2.base)
+	case TY_STRUCT, TY_TUPLE:
+		m1, m2 := t1.members, t2.members
+		for ; m1 != nil && m2 != nil; m1, m2 = m1.next, m2.next {
+			if memberName(m1) != memberName(m2) || !identical(m1.ty, m2.ty) {
+				return false
+			}
+		}
+		return m1 == nil && m2 == nil
+	case TY_FUNC:
+		p1, p2 := t1.params, t2.params
+		for ; p1 != nil && p2 != nil; p1, p2 = p1.next, p2.next {
+			if !identical(p1, p2) {
+				return false
+			}
+		}
+		return p1 == nil && p2 == nil && identical(t1.returnTy, t2.returnTy)
+	case TY_INTERFACE:
+		m1, m2 := t1.methods, t2.methods
+		for ; m1 != nil && m2 != nil; m1, m2 = m1.next, m2.next {
+			if m1.name != m2.name || !identical(m1.ty, m2.ty) {
+				return false
+			}
+		}
+		return m1 == nil && m2 == nil
+	}
+	return true
+}
+
+func memberName(mem *Member) string {
+	if mem.name == nil {
+		return ""
+	}
+	return getIdent(mem.name)
+}
+
+// Returns the name of a type for diagnostics.
+
+func typeString(ty *Type) string {
+	if ty.typeName != nil {
+		return getIdent(ty.typeName)
+	}
+	switch ty.kind {
+	case TY_VOID:
+		return "void"
+	case TY_BOOL:
+		if ty == tyUntypedBool {
+			return "untyped bool"
+		}
+		return "bool"
+	case TY_INT8:
+		return "int8"
+	case TY_INT16:
+		return "int16"
+	case TY_INT32:
+		return "int32"
+	case TY_INT64:
+		return "int64"
+	case TY_INT:
+		if ty == tyUntypedInt {
+			return "untyped int"
+		}
+		if ty == tyUntypedRune {
+			return "untyped rune"
+		}
+		return "int"
+	case TY_UINT8:
+		return "uint8"
+	case TY_UINT16:
+		return "uint16"
+	case TY_UINT32:
+		return "uint32"
+	case TY_UINT64:
+		return "uint64"
+	case TY_UINT:
+		return "uint"
+	case TY_UINTPTR:
+		return "uintptr"
+	case TY_FLOAT32:
+		return "float32"
+	case TY_FLOAT64:
+		if ty == tyUntypedFloat {
+			return "untyped float"
+		}
+		return "float64"
+	case TY_STRING:
+		return "string"
+	case TY_PTR:
+		if ty == tyNil {
+			return "nil"
+		}
+		return "*" + typeString(ty.base)
+	case TY_ARRAY:
+		return fmt.Sprintf("[%d]%s", ty.arrayLen, typeString(ty.base))
+	case TY_SLICE:
+		return "[]" + typeString(ty.base)
+	case TY_MAP:
+		return "map[" + typeString(ty.key) + "]" + typeString(ty.base)
+	case TY_CHAN:
+		switch ty.dir {
+		case CHAN_SEND:
+			return "chan<- " + typeString(ty.base)
+		case CHAN_RECV:
+			return "<-chan " + typeString(ty.base)
+		}
+		return "chan " + typeString(ty.base)
+	case TY_STRUCT:
+		return "struct"
+	case TY_FUNC:
+		return "func"
+	case TY_INTERFACE:
+		return "interface"
+	}
+	return "tuple"
+}
+
+// Returns the method `name` of a named type, a pointer to a named
+// type or an interface.
+
+func findMethod(ty *Type, name string) *Method {
+	if ty.kind == TY_PTR && ty.typeName == nil && ty.base.kind != TY_INTERFACE {
+		ty = ty.base
+	}
+	if ty.kind == TY_PTR {
+		return nil
+	}
+	if ty.origin != nil {
+		ty = ty.origin
+	}
+	for m := ty.methods; m != nil; m = m.next {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Returns nil if the method set of `ty` includes all the methods of
+// the interface `iface`. Otherwise returns the missing method. The
+// method set of a named type consists of the methods with a value
+// receiver, and that of a pointer to it has all the methods.
+
+func missingMethod(ty *Type, iface *Type) *Method {
+	for im := iface.methods; im != nil; im = im.next {
+		m := findMethod(ty, im.name)
+		if m == nil || !identical(m.ty, im.ty) || m.ptrRecv && ty.kind != TY_PTR {
+			return im
+		}
+	}
+	return nil
+}
+
 func addType(node *Node) {
+	if node != nil && node.kind == ND_COMPLIT {
+		lowerComplit(node)
+	}
 	if node == nil || node.ty != nil {
 		return
 	}
@@ -83,33 +604,719 @@ func addType(node *Node) {
 	}
 
 	switch node.kind {
-	case ND_ADD, ND_SUB, ND_MUL, ND_DIV, ND_NEG:
+	case ND_ADD, ND_SUB, ND_MUL, ND_DIV, ND_MOD, ND_BITAND, ND_BITOR, ND_BITXOR, ND_ANDNOT:
+		convertOperands(node)
+		node.ty = node.lhs.ty
+		if isUntyped(node.ty) {
+			node.ty = node.rhs.ty
+		}
+		checkOperator(node)
+		foldConst(node)
+		return
+	case ND_SHL, ND_SHR:
+		if isUntyped(node.lhs.ty) && !isConst(node.rhs) {
+			convertConst(node.lhs, tyInt)
+		}
+		node.ty = node.lhs.ty
+		checkOperator(node)
+		foldConst(node)
+		return
+	case ND_NEG, ND_BITNOT:
 		node.ty = node.lhs.ty
+		checkOperator(node)
+		foldConst(node)
 		return
 	case ND_ASSIGN:
-		if node.lhs.ty.kind == TY_ARRAY {
-			errorTok(node.lhs.tok, "not an lvalue")
+		if isStringIndex(node.lhs) {
+			errorTok(node.lhs.tok, "cannot assign to string element")
 		}
+		checkMapElement(node.lhs)
+		if isMapIndex(node.lhs) {
+			mapAssign(node.lhs)
+		}
+		node.rhs = convertValue(node.rhs, node.lhs.ty)
+		node.ty = node.lhs.ty
+		return
+	case ND_COMMA:
+		node.ty = node.rhs.ty
+		return
+	case ND_MEMBER:
+		node.ty = node.member.ty
+		return
+	case ND_MEMZERO:
+		node.ty = node.lhs.ty
+		return
+	case ND_DECL:
+		node.ty = tyVoid
+		return
+	case ND_EQ, ND_NE:
+		if (node.lhs.ty.kind == TY_INTERFACE || node.rhs.ty.kind == TY_INTERFACE) &&
+			node.lhs.ty != tyNil && node.rhs.ty != tyNil {
+			compareInterfaces(node)
+			return
+		}
+		if node.lhs.ty.kind == TY_STRING || node.rhs.ty.kind == TY_STRING {
+			compareStrings(node)
+			return
+		}
+		if node.lhs.ty.kind == TY_STRUCT || node.lhs.ty.kind == TY_ARRAY {
+			compareAggregates(node)
+		}
+
+		// An interface value is nil if it has no itab, and a slice
+		// is nil if it has no array.
+		if node.lhs.ty == tyNil {
+			node.lhs, node.rhs = node.rhs, node.lhs
+		}
+		if isNillable(node.lhs.ty) && node.rhs.ty == tyNil {
+			node.lhs = newUnary(ND_MEMBER, node.lhs, node.tok)
+			node.lhs.member = node.lhs.lhs.ty.members
+			addType(node.lhs)
+		} else if isNillable(node.lhs.ty) || isNillable(node.rhs.ty) ||
+			node.lhs.ty.kind == TY_MAP && node.rhs.ty != tyNil || node.rhs.ty.kind == TY_MAP {
+			errorTok(node.tok, "%s can only be compared to nil", typeString(node.lhs.ty))
+		}
+		convertOperands(node)
+		node.ty = tyUntypedBool
+		foldConst(node)
+		return
+	case ND_LT, ND_LE:
+		if node.lhs.ty.kind == TY_STRING || node.rhs.ty.kind == TY_STRING {
+			compareStrings(node)
+			return
+		}
+		convertOperands(node)
+		if !isNumeric(node.lhs.ty) && !inRuntime(node.tok) {
+			errorTok(node.tok, "invalid operation: operator %s not defined on %s",
+				getPunct(node.tok), typeString(node.lhs.ty))
+		}
+		node.ty = tyUntypedBool
+		foldConst(node)
+		return
+	case ND_NUM:
+		node.ty = tyUntypedInt
+		return
+	case ND_NOT:
+		checkBool(node.lhs, node.tok)
+		node.ty = node.lhs.ty
+		foldConst(node)
+		return
+	case ND_LOGAND, ND_LOGOR:
+		checkBool(node.lhs, node.tok)
+		checkBool(node.rhs, node.tok)
+		convertOperands(node)
 		node.ty = node.lhs.ty
+		if isUntyped(node.ty) {
+			node.ty = node.rhs.ty
+		}
+		foldConst(node)
+		return
+	case ND_TYPEASSERT:
+		node.ty = node.target
 		return
-	case ND_EQ, ND_NE, ND_LT, ND_LE, ND_NUM, ND_FUNCALL:
-		node.ty = tyInt
+	case ND_FUNCALL:
+		if node.funcTy != nil {
+			node.ty = node.funcTy.returnTy
+		} else {
+			node.ty = tyInt
+		}
 		return
 	case ND_VAR:
 		node.ty = node.vr.ty
 		return
 	case ND_ADDR:
-		if node.lhs.ty.kind == TY_ARRAY {
-			node.ty = pointerTo(node.lhs.ty.base)
-		} else {
-			node.ty = pointerTo(node.lhs.ty)
+		if isMapIndex(node.lhs) {
+			mapAssign(node.lhs)
 		}
+		node.ty = pointerTo(node.lhs.ty)
 		return
 	case ND_DEREF:
-		if node.lhs.ty.base == nil {
+		if node.lhs.ty.base == nil || node.lhs.ty.kind == TY_SLICE || node.lhs.ty.kind == TY_MAP ||
+			node.lhs.ty.kind == TY_CHAN {
 			errorTok(node.tok, "invalid pointer dereference")
 		}
 		node.ty = node.lhs.ty.base
 		return
 	}
 }
+
+// Reports an error if the operator of `node` is not defined on the
+// type of the operands. Floating-point numbers only have arithmetic
+// operators, and booleans have none.
+
+func checkOperator(node *Node) {
+	switch {
+	case isFlonum(node.ty):
+		switch node.kind {
+		case ND_ADD, ND_SUB, ND_MUL, ND_DIV, ND_NEG:
+			return
+		}
+	case node.ty.kind != TY_BOOL:
+		return
+	}
+	errorTok(node.tok, "invalid operation: operator %s not defined on %s",
+		getPunct(node.tok), typeString(node.ty))
+}
+
+// Reports an error if the operand of the logical operator at `tok`
+// is not a boolean.
+
+func checkBool(node *Node, tok *Token) {
+	if node.ty.kind != TY_BOOL {
+		errorTok(tok, "invalid operation: operator %s not defined on %s",
+			getPunct(tok), typeString(node.ty))
+	}
+}
+
+// Rewrites a comparison of strings `a op b` into
+// `runtime_cmpstring(a, b) op 0`. A comparison of string constants
+// is replaced with its result.
+
+func compareStrings(node *Node) {
+	if !identical(node.lhs.ty, node.rhs.ty) {
+		errorTok(node.tok, "invalid operation: mismatched types %s and %s",
+			typeString(node.lhs.ty), typeString(node.rhs.ty))
+	}
+	if isStringLiteral(node.lhs) && isStringLiteral(node.rhs) {
+		x, y := node.lhs.tok.str, node.rhs.tok.str
+		v := false
+		switch node.kind {
+		case ND_EQ:
+			v = x == y
+		case ND_NE:
+			v = x != y
+		case ND_LT:
+			v = x < y
+		case ND_LE:
+			v = x <= y
+		}
+		c := newBool(v, node.tok)
+		c.next = node.next
+		*node = *c
+		return
+	}
+	node.lhs = runtimeCall("runtime_cmpstring", nil, node.tok, node.lhs, node.rhs)
+	node.rhs = newNum(0, node.tok)
+	node.ty = tyUntypedBool
+}
+
+// Rewrites a comparison of interface values `a op b` into
+// `runtime_ifaceeq(a, b) op true`, which compares the dynamic types
+// and then the values. A value of a non-interface type is converted to
+// the interface type of the other operand, which it must implement.
+
+func compareInterfaces(node *Node) {
+	l, r := node.lhs.ty, node.rhs.ty
+	switch {
+	case l.kind != TY_INTERFACE:
+		node.lhs = interfaceOperand(node.lhs, r, node)
+	case r.kind != TY_INTERFACE:
+		node.rhs = interfaceOperand(node.rhs, l, node)
+	case missingMethod(l, r) != nil && missingMethod(r, l) != nil:
+		errorTok(node.tok, "invalid operation: mismatched types %s and %s",
+			typeString(l), typeString(r))
+	}
+	node.lhs = runtimeCall("runtime_ifaceeq", nil, node.tok, node.lhs, node.rhs)
+	node.rhs = newBool(true, node.tok)
+	addType(node.rhs)
+	node.ty = tyUntypedBool
+}
+
+// Converts the operand `x` of the comparison `node` to the interface
+// type `ty` of the other operand. An untyped constant gets its default
+// type.
+
+func interfaceOperand(x *Node, ty *Type, node *Node) *Node {
+	convertConst(x, ty)
+	if !isComparable(x.ty) {
+		errorTok(node.tok, "invalid operation: %s cannot be compared", typeString(x.ty))
+	}
+	if missingMethod(x.ty, ty) != nil {
+		errorTok(node.tok, "invalid operation: mismatched types %s and %s",
+			typeString(node.lhs.ty), typeString(node.rhs.ty))
+	}
+	return toInterface(x, ty)
+}
+
+// Rewrites a comparison of structs or arrays `a op b` into
+// `(x = a, y = b, x.f == y.f && ...) op true`, so that the fields and
+// the elements are compared by their values, such as strings by their
+// contents. Values whose bytes are equal only if the values are equal
+// are compared by runtime_memequal.
+
+func compareAggregates(node *Node) {
+	ty := node.lhs.ty
+	tok := node.tok
+	if !assignable(node.rhs.ty, ty) && !assignable(ty, node.rhs.ty) {
+		errorTok(tok, "invalid operation: mismatched types %s and %s",
+			typeString(ty), typeString(node.rhs.ty))
+	}
+	if !isComparable(ty) {
+		errorTok(tok, "invalid operation: %s cannot be compared", typeString(ty))
+	}
+
+	var init *Node
+	operand := func(n *Node) func() *Node {
+		vr := n.vr
+		if n.kind != ND_VAR {
+			vr = newLvar("", ty)
+			assign := newBinary(ND_ASSIGN, newVarNode(vr, tok), n, tok)
+			if init == nil {
+				init = assign
+			} else {
+				init = newBinary(ND_COMMA, init, assign, tok)
+			}
+		}
+		return func() *Node { return newVarNode(vr, tok) }
+	}
+	x, y := operand(node.lhs), operand(node.rhs)
+
+	eq := equalValues(x, y, ty, tok)
+	if init != nil {
+		eq = newBinary(ND_COMMA, init, eq, tok)
+	}
+	addType(eq)
+	node.lhs = eq
+	node.rhs = newBool(true, tok)
+	addType(node.rhs)
+}
+
+// Returns an expression which is true if the values of the type `ty`
+// returned by `x` and `y` are equal.
+
+func equalValues(x func() *Node, y func() *Node, ty *Type, tok *Token) *Node {
+	if isMemComparable(ty) {
+		return runtimeCall("runtime_memequal", nil, tok, newUnary(ND_ADDR, x(), tok),
+			newUnary(ND_ADDR, y(), tok), newNum(ty.size, tok))
+	}
+
+	var eq *Node
+	and := func(cmp *Node) {
+		if eq == nil {
+			eq = cmp
+		} else {
+			eq = newBinary(ND_LOGAND, eq, cmp, tok)
+		}
+	}
+	switch ty.kind {
+	case TY_STRUCT:
+		for mem := ty.members; mem != nil; mem = mem.next {
+			and(equalValues(memberOf(x, mem, tok), memberOf(y, mem, tok), mem.ty, tok))
+		}
+	case TY_ARRAY:
+		for i := 0; i < ty.arrayLen; i++ {
+			and(equalValues(elemOf(x, i, tok), elemOf(y, i, tok), ty.base, tok))
+		}
+	default:
+		return newBinary(ND_EQ, x(), y(), tok)
+	}
+	if eq == nil {
+		return newBool(true, tok)
+	}
+	return eq
+}
+
+// Returns an expression continuing the hash `h` with the value of the
+// type `ty` returned by `x`. Values which are equal have the same hash,
+// so a string is hashed by its contents, an interface value by its
+// dynamic value, and a floating-point number by its value.
+
+func hashValue(x func() *Node, ty *Type, h *Node, tok *Token) *Node {
+	switch {
+	case isFlonum(ty):
+		return runtimeCall("runtime_f64hash", nil, tok, h, newConversion(x(), tyFloat64, tok))
+	case ty.kind == TY_STRING:
+		return runtimeCall("runtime_strhash", nil, tok, h, x())
+	case ty.kind == TY_INTERFACE:
+		return runtimeCall("runtime_ifacehash", nil, tok, h, x())
+	case ty.kind == TY_STRUCT && !isMemComparable(ty):
+		for mem := ty.members; mem != nil; mem = mem.next {
+			h = hashValue(memberOf(x, mem, tok), mem.ty, h, tok)
+		}
+		return h
+	case ty.kind == TY_ARRAY && !isMemComparable(ty):
+		for i := 0; i < ty.arrayLen; i++ {
+			h = hashValue(elemOf(x, i, tok), ty.base, h, tok)
+		}
+		return h
+	}
+	return runtimeCall("runtime_hash", nil, tok, h, newUnary(ND_ADDR, x(), tok), newNum(ty.size, tok))
+}
+
+// Returns a function returning the member `mem` of the value returned
+// by `v`.
+
+func memberOf(v func() *Node, mem *Member, tok *Token) func() *Node {
+	return func() *Node {
+		node := newUnary(ND_MEMBER, v(), tok)
+		node.member = mem
+		return node
+	}
+}
+
+// Returns a function returning the element `i` of the array returned
+// by `v`.
+
+func elemOf(v func() *Node, i int, tok *Token) func() *Node {
+	return func() *Node {
+		return newUnary(ND_DEREF, newPtrAdd(v(), newNum(i, tok), tok), tok)
+	}
+}
+
+// Returns true if values of the type are equal if and only if their
+// bytes are, i.e. it has no strings, interfaces, floating-point
+// numbers or padding.
+
+func isMemComparable(ty *Type) bool {
+	switch ty.kind {
+	case TY_STRING, TY_INTERFACE, TY_FLOAT32, TY_FLOAT64:
+		return false
+	case TY_ARRAY:
+		return isMemComparable(ty.base)
+	case TY_STRUCT:
+		offset := 0
+		for mem := ty.members; mem != nil; mem = mem.next {
+			if mem.offset != offset || !isMemComparable(mem.ty) {
+				return false
+			}
+			offset += mem.ty.size
+		}
+		return offset == ty.size
+	}
+	return true
+}
+
+// Returns true if `node` is a constant, which has been evaluated.
+
+func isConst(node *Node) bool {
+	return node.kind == ND_NUM && node.ty != tyNil
+}
+
+// Returns the exact value of a constant. A floating-point constant
+// is truncated to an integer.
+
+func constOf(node *Node) *big.Int {
+	if node.fval != nil {
+		v, _ := node.fval.Int(nil)
+		return v
+	}
+	if node.cval != nil {
+		return node.cval
+	}
+	return big.NewInt(int64(node.val))
+}
+
+// Returns the value of a constant as a floating-point number.
+
+func floatOf(node *Node) *big.Float {
+	if node.fval != nil {
+		return node.fval
+	}
+	return new(big.Float).SetPrec(floatPrec).SetInt(constOf(node))
+}
+
+// Returns the value of a constant for diagnostics.
+
+func constString(node *Node) string {
+	if node.fval != nil {
+		return node.fval.String()
+	}
+	return constOf(node).String()
+}
+
+// Returns true if the integer `v` is a value of the type `ty`.
+
+func representable(v *big.Int, ty *Type) bool {
+	bits := uint(ty.size * 8)
+	if ty.isUnsigned {
+		return v.Sign() >= 0 && v.BitLen() <= int(bits)
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), bits-1)
+	min := new(big.Int).Neg(max)
+	return v.Cmp(min) >= 0 && v.Cmp(max) < 0
+}
+
+// Rounds the floating-point number `v` to the precision of the type
+// `ty`. An untyped constant is kept exact. The second result is false
+// if the value overflows the type.
+
+func roundFloat(v *big.Float, ty *Type) (*big.Float, bool) {
+	if isUntyped(ty) {
+		return v, true
+	}
+	var f float64
+	if ty.kind == TY_FLOAT32 {
+		f32, _ := v.Float32()
+		f = float64(f32)
+	} else {
+		f, _ = v.Float64()
+	}
+	if math.IsInf(f, 0) {
+		return v, false
+	}
+	return new(big.Float).SetPrec(floatPrec).SetFloat64(f), true
+}
+
+// Returns the type an untyped constant gets if the context does not
+// give it one.
+
+func defaultType(ty *Type) *Type {
+	switch ty {
+	case tyUntypedRune:
+		return tyInt32
+	case tyUntypedFloat:
+		return tyFloat64
+	case tyUntypedBool:
+		return tyBool
+	}
+	return tyInt
+}
+
+// Gives the constant `node` the type `ty`. An untyped constant used
+// as an interface value gets its default type. A floating-point
+// constant given an integer type must be an integer. A boolean value
+// and a number are not converted to each other.
+
+func convertConst(node *Node, ty *Type) {
+	// A comparison is an untyped boolean value even if it is not a
+	// constant.
+	if node.ty == tyUntypedBool {
+		if ty.kind == TY_INTERFACE {
+			ty = tyBool
+		}
+		if ty.kind != TY_BOOL {
+			errorTok(node.tok, "cannot use a value of type untyped bool as %s value", typeString(ty))
+		}
+		node.ty = ty
+		return
+	}
+	if !isConst(node) {
+		return
+	}
+	if ty.kind == TY_BOOL && isUntyped(node.ty) {
+		errorTok(node.tok, "cannot use a constant of type %s as %s value",
+			typeString(node.ty), typeString(ty))
+	}
+	if ty.kind == TY_INTERFACE && isUntyped(node.ty) {
+		ty = defaultType(node.ty)
+	}
+	if isFlonum(ty) {
+		v, ok := roundFloat(floatOf(node), ty)
+		if !ok {
+			errorTok(node.tok, "constant %s overflows %s", constString(node), typeString(ty))
+		}
+		c := newFloatConst(v, ty, node.tok)
+		c.next = node.next
+		*node = *c
+		return
+	}
+	if !isInteger(ty) {
+		return
+	}
+	if node.fval != nil {
+		if !node.fval.IsInt() {
+			errorTok(node.tok, "constant %s truncated to integer", constString(node))
+		}
+		c := newConst(constOf(node), node.ty, node.tok)
+		c.next = node.next
+		*node = *c
+	}
+	if !representable(constOf(node), ty) {
+		errorTok(node.tok, "constant %s overflows %s", constOf(node), typeString(ty))
+	}
+	node.ty = ty
+}
+
+// Converts an untyped constant operand of a binary operator to the
+// type of the other operand. Of two untyped constants, one is
+// converted to the kind which appears later in the list integer,
+// rune and floating-point number. Operands of a floating-point
+// operation must have the same type.
+
+func convertOperands(node *Node) {
+	l, r := node.lhs.ty, node.rhs.ty
+	switch {
+	case isUntyped(l) && !isUntyped(r):
+		convertConst(node.lhs, r)
+	case isUntyped(r) && !isUntyped(l):
+		convertConst(node.rhs, l)
+	case l == tyUntypedInt && r == tyUntypedRune:
+		node.lhs.ty = r
+	case l == tyUntypedRune && r == tyUntypedInt:
+		node.rhs.ty = l
+	case (l == tyUntypedInt || l == tyUntypedRune) && r == tyUntypedFloat:
+		convertConst(node.lhs, r)
+	case l == tyUntypedFloat && (r == tyUntypedInt || r == tyUntypedRune):
+		convertConst(node.rhs, l)
+	}
+
+	// Either operand must be assignable to the type of the other, so
+	// that numbers must have identical types. Anything may be compared
+	// to nil, which is checked by the caller, and the runtime adds
+	// integers to pointers.
+	l, r = node.lhs.ty, node.rhs.ty
+	if l == tyNil || r == tyNil || inRuntime(node.tok) {
+		return
+	}
+	if !assignable(l, r) && !assignable(r, l) {
+		errorTok(node.tok, "invalid operation: mismatched types %s and %s",
+			typeString(l), typeString(r))
+	}
+}
+
+// Replaces an operation on constants with its result. The operation
+// is evaluated exactly, and the result must be representable by its
+// type unless it is untyped.
+
+func foldConst(node *Node) {
+	if node.ty.kind == TY_BOOL {
+		foldBool(node)
+		return
+	}
+	if isFlonum(node.ty) {
+		foldFloat(node)
+		return
+	}
+	if !isInteger(node.ty) || !isConst(node.lhs) || node.rhs != nil && !isConst(node.rhs) {
+		return
+	}
+
+	x := constOf(node.lhs)
+	v := new(big.Int)
+	switch node.kind {
+	case ND_NEG:
+		v.Neg(x)
+	case ND_BITNOT:
+		if node.ty.isUnsigned {
+			mask := new(big.Int).Lsh(big.NewInt(1), uint(node.ty.size*8))
+			v.Xor(x, mask.Sub(mask, big.NewInt(1)))
+		} else {
+			v.Not(x)
+		}
+	default:
+		y := constOf(node.rhs)
+		switch node.kind {
+		case ND_ADD:
+			v.Add(x, y)
+		case ND_SUB:
+			v.Sub(x, y)
+		case ND_MUL:
+			v.Mul(x, y)
+		case ND_DIV, ND_MOD:
+			if y.Sign() == 0 {
+				errorTok(node.rhs.tok, "invalid operation: division by zero")
+			}
+			if node.kind == ND_DIV {
+				v.Quo(x, y)
+			} else {
+				v.Rem(x, y)
+			}
+		case ND_BITAND:
+			v.And(x, y)
+		case ND_BITOR:
+			v.Or(x, y)
+		case ND_BITXOR:
+			v.Xor(x, y)
+		case ND_ANDNOT:
+			v.AndNot(x, y)
+		case ND_SHL, ND_SHR:
+			if y.Sign() < 0 || y.Cmp(big.NewInt(10000)) > 0 {
+				errorTok(node.rhs.tok, "invalid shift count %s", y)
+			}
+			if node.kind == ND_SHL {
+				v.Lsh(x, uint(y.Int64()))
+			} else {
+				v.Rsh(x, uint(y.Int64()))
+			}
+		default:
+			return
+		}
+	}
+
+	if !isUntyped(node.ty) && !representable(v, node.ty) {
+		errorTok(node.tok, "constant %s overflows %s", v, typeString(node.ty))
+	}
+	c := newConst(v, node.ty, node.tok)
+	c.next = node.next
+	*node = *c
+}
+
+// Replaces an operation on floating-point constants with its result.
+// A typed result is rounded to the precision of its type.
+
+func foldFloat(node *Node) {
+	if !isConst(node.lhs) || node.rhs != nil && !isConst(node.rhs) {
+		return
+	}
+
+	x := floatOf(node.lhs)
+	v := new(big.Float).SetPrec(floatPrec)
+	switch node.kind {
+	case ND_NEG:
+		v.Neg(x)
+	case ND_ADD:
+		v.Add(x, floatOf(node.rhs))
+	case ND_SUB:
+		v.Sub(x, floatOf(node.rhs))
+	case ND_MUL:
+		v.Mul(x, floatOf(node.rhs))
+	case ND_DIV:
+		y := floatOf(node.rhs)
+		if y.Sign() == 0 {
+			errorTok(node.rhs.tok, "invalid operation: division by zero")
+		}
+		v.Quo(x, y)
+	default:
+		return
+	}
+
+	v, ok := roundFloat(v, node.ty)
+	if !ok {
+		errorTok(node.tok, "constant %s overflows %s", v.String(), typeString(node.ty))
+	}
+	c := newFloatConst(v, node.ty, node.tok)
+	c.next = node.next
+	*node = *c
+}
+
+// Replaces a logical operation on boolean constants or a comparison of
+// numeric constants with its result.
+
+func foldBool(node *Node) {
+	if !isConst(node.lhs) || node.rhs != nil && !isConst(node.rhs) {
+		return
+	}
+
+	x := node.lhs.val != 0
+	var v bool
+	switch node.kind {
+	case ND_NOT:
+		v = !x
+	case ND_LOGAND:
+		v = x && node.rhs.val != 0
+	case ND_LOGOR:
+		v = x || node.rhs.val != 0
+	default:
+		var cmp int
+		if node.lhs.fval != nil || node.rhs.fval != nil {
+			cmp = floatOf(node.lhs).Cmp(floatOf(node.rhs))
+		} else {
+			cmp = constOf(node.lhs).Cmp(constOf(node.rhs))
+		}
+		switch node.kind {
+		case ND_EQ:
+			v = cmp == 0
+		case ND_NE:
+			v = cmp != 0
+		case ND_LT:
+			v = cmp < 0
+		case ND_LE:
+			v = cmp <= 0
+		}
+	}
+
+	c := newBool(v, node.tok)
+	c.ty = node.ty
+	c.next = node.next
+	*node = *c
+}